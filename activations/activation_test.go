@@ -130,3 +130,45 @@ func TestSoftmax(t *testing.T) {
 	}
 
 }
+
+func TestPolynomial(t *testing.T) {
+
+	// Fitted sigmoid and its derivative s(x)(1 - s(x)) over [-5, 5]
+
+	sigmoid := func(x float64) float64 { return 1 / (1 + math.Exp(-x)) }
+
+	inputArray := make([]float64, 100)
+	forwardExpected := make([]float64, utils.Params.Slots())
+	backwardExpected := make([]float64, utils.Params.Slots())
+
+	for i := range inputArray {
+		inputArray[i] = -4 + 8*float64(i)/float64(len(inputArray)-1)
+		forwardExpected[i] = sigmoid(inputArray[i])
+		backwardExpected[i] = sigmoid(inputArray[i]) * (1 - sigmoid(inputArray[i]))
+	}
+
+	polynomial := NewPolynomialSigmoid(utils, 5, 15)
+
+	input := utils.EncryptToLevel(inputArray, 9)
+	startingLevel := input.Level()
+
+	fwdResult := polynomial.Forward([]*rlwe.Ciphertext{input}, 100)[0]
+	backwardResult := polynomial.Backward([]*rlwe.Ciphertext{input}, 100)[0]
+
+	if startingLevel-fwdResult.Level() != polynomial.GetForwardLevelConsumption() {
+		t.Errorf("Polynomial forward used %d levels but reported %d", startingLevel-fwdResult.Level(), polynomial.GetForwardLevelConsumption())
+	}
+
+	if startingLevel-backwardResult.Level() != polynomial.GetBackwardLevelConsumption() {
+		t.Errorf("Polynomial backward used %d levels but reported %d", startingLevel-backwardResult.Level(), polynomial.GetBackwardLevelConsumption())
+	}
+
+	if !utility.ValidateResult(utils.Decrypt(fwdResult)[:100], forwardExpected[:100], false, 2, log) {
+		t.Error("Polynomial forward wasn't evaluated properly")
+	}
+
+	if !utility.ValidateResult(utils.Decrypt(backwardResult)[:100], backwardExpected[:100], false, 2, log) {
+		t.Error("Polynomial backward wasn't evaluated properly")
+	}
+
+}
@@ -0,0 +1,106 @@
+package activations
+
+import (
+	"math"
+
+	"github.com/perm-ai/go-cerebrum/utility"
+	"github.com/tuneinsight/lattigo/v4/rlwe"
+)
+
+//=================================================
+//					POLYNOMIAL
+//=================================================
+
+// Polynomial approximates any activation function with a Chebyshev interpolant fitted in plaintext over [Lower, Upper].
+// Backward evaluates the exact derivative of the fitted polynomial. Inputs outside the interval aren't approximated correctly
+type Polynomial struct {
+	U          utility.Utils
+	Lower      float64
+	Upper      float64
+	forward    utility.Polynomial
+	derivative utility.Polynomial
+}
+
+// Fit function with a Chebyshev interpolant of degree over [lower, upper]. Degree must be at least 3 so the derivative isn't linear
+func NewPolynomial(utils utility.Utils, function func(float64) float64, lower float64, upper float64, degree int) Polynomial {
+
+	if degree < 3 {
+		panic("Degree of polynomial activation must be at least 3")
+	}
+
+	forward := utility.FitChebyshev(function, lower, upper, degree, utils)
+
+	return Polynomial{U: utils, Lower: lower, Upper: upper, forward: forward, derivative: forward.Derivative()}
+
+}
+
+// Polynomial approximation of ReLU over [-bound, bound]
+func NewPolynomialRelu(utils utility.Utils, bound float64, degree int) Polynomial {
+	return NewPolynomial(utils, func(x float64) float64 { return math.Max(0, x) }, -bound, bound, degree)
+}
+
+// Polynomial approximation of sigmoid over [-bound, bound]
+func NewPolynomialSigmoid(utils utility.Utils, bound float64, degree int) Polynomial {
+	return NewPolynomial(utils, func(x float64) float64 { return 1 / (1 + math.Exp(-x)) }, -bound, bound, degree)
+}
+
+// Polynomial approximation of tanh over [-bound, bound]
+func NewPolynomialTanh(utils utility.Utils, bound float64, degree int) Polynomial {
+	return NewPolynomial(utils, math.Tanh, -bound, bound, degree)
+}
+
+// Coefficients of the fitted polynomial in Chebyshev basis over [Lower, Upper]
+func (p Polynomial) Coefficients() []float64 {
+
+	coefficients := make([]float64, len(p.forward.Coeff))
+	for i := range coefficients {
+		coefficients[i] = p.forward.Coeff[i].Value
+	}
+
+	return coefficients
+
+}
+
+func (p Polynomial) Forward(input []*rlwe.Ciphertext, inputLength int) []*rlwe.Ciphertext {
+	return evaluatePolynomial(input, inputLength, p.forward)
+}
+
+func (p Polynomial) Backward(input []*rlwe.Ciphertext, inputLength int) []*rlwe.Ciphertext {
+	return evaluatePolynomial(input, inputLength, p.derivative)
+}
+
+// Evaluate poly on every input concurrently
+func evaluatePolynomial(input []*rlwe.Ciphertext, inputLength int, poly utility.Polynomial) []*rlwe.Ciphertext {
+
+	output := make([]*rlwe.Ciphertext, len(input))
+	outputChannels := make([]chan *rlwe.Ciphertext, len(input))
+
+	for i := range input {
+
+		outputChannels[i] = make(chan *rlwe.Ciphertext)
+
+		go func(inputEach *rlwe.Ciphertext, c chan *rlwe.Ciphertext) {
+			c <- poly.Evaluate(inputEach, inputLength)
+		}(input[i], outputChannels[i])
+
+	}
+
+	for i := range outputChannels {
+		output[i] = <-outputChannels[i]
+	}
+
+	return output
+
+}
+
+func (p Polynomial) GetForwardLevelConsumption() int {
+	return p.forward.GetLevelConsumption()
+}
+
+func (p Polynomial) GetBackwardLevelConsumption() int {
+	return p.derivative.GetLevelConsumption()
+}
+
+func (p Polynomial) GetType() string {
+	return "polynomial"
+}
@@ -81,6 +81,20 @@ func (m MnistLoader) Load1D(start int, batchSize int) ([]*rlwe.Ciphertext, []*rl
 
 }
 
+// Load batch of images as [28][28][1] with the same packing as Load1D
 func (m MnistLoader) Load2D(start int, batchSize int) ([][][]*rlwe.Ciphertext, []*rlwe.Ciphertext) {
-	return [][][]*rlwe.Ciphertext{}, []*rlwe.Ciphertext{}
+
+	flatX, y := m.Load1D(start, batchSize)
+
+	x := make([][][]*rlwe.Ciphertext, 28)
+
+	for r := range x {
+		x[r] = make([][]*rlwe.Ciphertext, 28)
+		for c := range x[r] {
+			x[r][c] = []*rlwe.Ciphertext{flatX[(r*28)+c]}
+		}
+	}
+
+	return x, y
+
 }
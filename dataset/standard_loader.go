@@ -10,6 +10,7 @@ import (
 type StandardLoader struct {
 	u      utility.Utils
 	X      []*rlwe.Ciphertext
+	X2D    [][][]*rlwe.Ciphertext // Image data packed as [row][column][channel] used by Load2D
 	Y      []*rlwe.Ciphertext
 	Length int
 }
@@ -28,10 +29,27 @@ func NewStandardLoader(dataX map[string]*rlwe.Ciphertext, order []string, dataY
 
 }
 
+// Create loader for 2D layers. Each ciphertext of dataX holds the value of a pixel in the same [row][column][channel]
+// across all training data, packed in the same order as dataY
+func NewStandardLoader2D(dataX [][][]*rlwe.Ciphertext, dataY []*rlwe.Ciphertext, utils utility.Utils, length int) StandardLoader {
+
+	return StandardLoader{X2D: dataX, Y: dataY, Length: length, u: utils}
+
+}
+
 func (s StandardLoader) GetLength() int {
 	return s.Length
 }
 
+// Rotations performed by Load1D and Load2D when loading every batch of batchSize data
+func (s StandardLoader) PlanRotations(planner *utility.RotationPlanner, batchSize int) {
+	for start := batchSize; start < s.Length; start += batchSize {
+		planner.AddRotation(start)
+	}
+}
+
+// Load batch of 1D data. Data outside of the batch is masked out and the batch is rotated to the first batchSize slots
+// like Load2D and MnistLoader, so it lines up with layer weights and batch averagers
 func (s StandardLoader) Load1D(start int, batchSize int) ([]*rlwe.Ciphertext, []*rlwe.Ciphertext) {
 
 	filter := make([]float64, s.Length)
@@ -58,6 +76,7 @@ func (s StandardLoader) Load1D(start int, batchSize int) ([]*rlwe.Ciphertext, []
 		go func(x *rlwe.Ciphertext, index int, utils utility.Utils) {
 			defer xWg.Done()
 			batchedX[index] = utils.MultiplyPlainNew(x, filterPlain, true, false)
+			utils.Rotate(batchedX[index], start)
 		}(s.X[xi].CopyNew(), xi, s.u.CopyWithClonedEval())
 
 	}
@@ -69,6 +88,7 @@ func (s StandardLoader) Load1D(start int, batchSize int) ([]*rlwe.Ciphertext, []
 		go func(y *rlwe.Ciphertext, index int, utils utility.Utils) {
 			defer yWg.Done()
 			batchedY[index] = utils.MultiplyPlainNew(y, filterPlain, true, false)
+			utils.Rotate(batchedY[index], start)
 		}(s.Y[yi].CopyNew(), yi, s.u.CopyWithClonedEval())
 
 	}
@@ -80,9 +100,52 @@ func (s StandardLoader) Load1D(start int, batchSize int) ([]*rlwe.Ciphertext, []
 
 }
 
+// Load batch of 2D data. Data outside of the batch is masked out and the batch is rotated to the first batchSize slots
+// so it lines up with layer weights and batch averagers. Keys of the rotations are planned by PlanRotations
 func (s StandardLoader) Load2D(start int, batchSize int) ([][][]*rlwe.Ciphertext, []*rlwe.Ciphertext) {
 
-	// TODO: implement later
-	return [][][]*rlwe.Ciphertext{}, []*rlwe.Ciphertext{}
+	filter := make([]float64, s.Length)
+	for i := start; i < start+batchSize && i < s.Length; i++ {
+		filter[i] = 1
+	}
+
+	filterPlain := s.u.EncodePlaintextFromArray(filter)
+
+	batchedX := make([][][]*rlwe.Ciphertext, len(s.X2D))
+	batchedY := make([]*rlwe.Ciphertext, len(s.Y))
+
+	var wg sync.WaitGroup
+
+	batch := func(ct *rlwe.Ciphertext, destination **rlwe.Ciphertext, utils utility.Utils) {
+		defer wg.Done()
+		batched := utils.MultiplyPlainNew(ct, filterPlain, true, false)
+		utils.Rotate(batched, start)
+		*destination = batched
+	}
+
+	for r := range s.X2D {
+
+		batchedX[r] = make([][]*rlwe.Ciphertext, len(s.X2D[r]))
+
+		for c := range s.X2D[r] {
+
+			batchedX[r][c] = make([]*rlwe.Ciphertext, len(s.X2D[r][c]))
+
+			for d := range s.X2D[r][c] {
+				wg.Add(1)
+				go batch(s.X2D[r][c][d], &batchedX[r][c][d], s.u.CopyWithClonedEval())
+			}
+
+		}
+	}
+
+	for yi := range batchedY {
+		wg.Add(1)
+		go batch(s.Y[yi], &batchedY[yi], s.u.CopyWithClonedEval())
+	}
+
+	wg.Wait()
+
+	return batchedX, batchedY
 
 }
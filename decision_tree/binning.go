@@ -0,0 +1,146 @@
+package decisiontree
+
+import (
+	"math"
+	"sort"
+
+	"github.com/perm-ai/go-cerebrum/utility"
+	"github.com/tuneinsight/lattigo/v4/rlwe"
+)
+
+// Binner splits each feature into bins holding about the same number of rows so the data owner can encrypt
+// features as one-hot bins for EncryptedCARTTrainer. Bin b of feature f holds values in [Edges[f][b-1], Edges[f][b])
+type Binner struct {
+	Edges [][]float64
+	Lower []float64 // Lowest value of each feature seen by NewBinner
+	Upper []float64 // Highest value of each feature seen by NewBinner
+}
+
+// Find edges of at most bins quantile bins for every feature of rows. Features with fewer unique values get fewer bins
+func NewBinner(rows [][]float64, bins int) Binner {
+
+	binner := Binner{
+		Edges: make([][]float64, len(rows[0])),
+		Lower: make([]float64, len(rows[0])),
+		Upper: make([]float64, len(rows[0])),
+	}
+
+	for feature := range rows[0] {
+
+		values := make([]float64, len(rows))
+		for i := range rows {
+			values[i] = rows[i][feature]
+		}
+		sort.Float64s(values)
+
+		binner.Lower[feature] = values[0]
+		binner.Upper[feature] = values[len(values)-1]
+		binner.Edges[feature] = []float64{}
+
+		for b := 1; b < bins; b++ {
+
+			edge := values[b*len(values)/bins]
+			edges := binner.Edges[feature]
+
+			if edge > values[0] && (len(edges) == 0 || edge > edges[len(edges)-1]) {
+				binner.Edges[feature] = append(edges, edge)
+			}
+
+		}
+
+	}
+
+	return binner
+
+}
+
+func (b Binner) NumBins(feature int) int {
+	return len(b.Edges[feature]) + 1
+}
+
+// Index of the bin that value of feature falls in
+func (b Binner) Bin(value float64, feature int) int {
+	return sort.Search(len(b.Edges[feature]), func(i int) bool { return b.Edges[feature][i] > value })
+}
+
+// Question that matches rows whose feature falls in bin or any bin after it
+func (b Binner) Question(feature int, bin int) Question {
+	return Question{Feature: feature, Threshold: b.Edges[feature][bin-1]}
+}
+
+// Widest range of a feature, used as comparison bound when classifying encrypted values
+func (b Binner) Bound() float64 {
+
+	bound := 0.0
+	for feature := range b.Lower {
+		bound = math.Max(bound, b.Upper[feature]-b.Lower[feature])
+	}
+
+	if bound == 0 {
+		bound = 1
+	}
+
+	return bound
+
+}
+
+// One-hot bins of rows as [feature][bin][row]
+func (b Binner) Transform(rows [][]float64) [][][]float64 {
+
+	oneHot := make([][][]float64, len(b.Edges))
+
+	for feature := range oneHot {
+
+		oneHot[feature] = make([][]float64, b.NumBins(feature))
+		for bin := range oneHot[feature] {
+			oneHot[feature][bin] = make([]float64, len(rows))
+		}
+
+		for i := range rows {
+			oneHot[feature][b.Bin(rows[i][feature], feature)][i] = 1
+		}
+
+	}
+
+	return oneHot
+
+}
+
+// Encrypt one-hot bins of rows as [feature][bin] where each row is in a slot. Number of rows must not exceed slots
+func (b Binner) Encrypt(utils utility.Utils, rows [][]float64) [][]*rlwe.Ciphertext {
+
+	oneHot := b.Transform(rows)
+	encrypted := make([][]*rlwe.Ciphertext, len(oneHot))
+
+	for feature := range oneHot {
+		encrypted[feature] = make([]*rlwe.Ciphertext, len(oneHot[feature]))
+		for bin := range oneHot[feature] {
+			encrypted[feature][bin] = utils.EncryptToPointer(oneHot[feature][bin])
+		}
+	}
+
+	return encrypted
+
+}
+
+// Encrypt one-hot labels as one ciphertext per class where each row is in a slot
+func EncryptLabels(utils utility.Utils, labels []int, numClasses int) []*rlwe.Ciphertext {
+
+	encrypted := make([]*rlwe.Ciphertext, numClasses)
+
+	for class := range encrypted {
+
+		oneHot := make([]float64, len(labels))
+		for i, label := range labels {
+			if label == class {
+				oneHot[i] = 1
+			}
+		}
+
+		encrypted[class] = utils.EncryptToPointer(oneHot)
+
+	}
+
+	return encrypted
+
+}
@@ -0,0 +1,167 @@
+package decisiontree
+
+import (
+	"sync"
+
+	"github.com/perm-ai/go-cerebrum/utility"
+	"github.com/tuneinsight/lattigo/v4/rlwe"
+)
+
+// Levels consumed by Predict. Every question is answered by a polynomial comparison, then the answers along the path
+// to each leaf are multiplied and weighted by the leaf prediction
+func (t CARTTree) GetLevelConsumption() int {
+
+	if t.Root.IsLeaf() {
+		return 0
+	}
+
+	return t.Comparison.GetLevelConsumption() + t.Depth()
+
+}
+
+// Classify encrypted data where x[i] holds feature i of every data in its slots. Returns encrypted score of each class
+// which is the share of the class in the leaf the data reaches. Features must lie within Comparison.Bound of every threshold
+func (t CARTTree) Predict(x []*rlwe.Ciphertext) []*rlwe.Ciphertext {
+
+	if t.Root == nil {
+		panic("CARTTree must be fitted before Predict")
+	}
+
+	scores := make([]*rlwe.Ciphertext, t.NumClasses)
+
+	if t.Root.IsLeaf() {
+		for class := range scores {
+			scores[class] = t.utils.EncryptToPointer(t.utils.GenerateFilledArray(t.Root.Prediction[class]))
+		}
+		return scores
+	}
+
+	answers := t.answerQuestions(x)
+
+	// Answers are multiplied Depth - 1 times then weighted once
+	answerList := make([]*rlwe.Ciphertext, 0, len(answers))
+	for _, answer := range answers {
+		answerList = append(answerList, answer)
+	}
+	t.utils.Bootstrap1dIfBelow(answerList, t.Depth())
+
+	t.aggregate(t.Root, nil, answers, scores)
+
+	// Class that no leaf predicts has zero score
+	level := t.utils.Params.MaxLevel()
+	for class := range scores {
+		if scores[class] != nil && scores[class].Level() < level {
+			level = scores[class].Level()
+		}
+	}
+
+	for class := range scores {
+		if scores[class] == nil {
+			scores[class] = t.utils.EncryptToLevel(t.utils.GenerateFilledArray(0), level)
+		}
+	}
+
+	return scores
+
+}
+
+// Approximate whether data matches the question of every internal node concurrently
+func (t CARTTree) answerQuestions(x []*rlwe.Ciphertext) map[*Node]*rlwe.Ciphertext {
+
+	nodes := []*Node{}
+
+	var collect func(node *Node)
+	collect = func(node *Node) {
+		if !node.IsLeaf() {
+			nodes = append(nodes, node)
+			collect(node.True)
+			collect(node.False)
+		}
+	}
+	collect(t.Root)
+
+	answers := make([]*rlwe.Ciphertext, len(nodes))
+
+	var wg sync.WaitGroup
+
+	for i := range nodes {
+
+		wg.Add(1)
+
+		go func(i int, utils utility.Utils) {
+
+			defer wg.Done()
+
+			shifted := x[nodes[i].Question.Feature].CopyNew()
+			utils.Evaluator.AddConst(shifted, -nodes[i].Question.Threshold, shifted)
+
+			answers[i] = utils.Step(shifted, t.Comparison)
+
+		}(i, t.utils.CopyWithClonedEval())
+
+	}
+
+	wg.Wait()
+
+	result := make(map[*Node]*rlwe.Ciphertext, len(nodes))
+	for i := range nodes {
+		result[nodes[i]] = answers[i]
+	}
+
+	return result
+
+}
+
+// Multiply answers along the path to each leaf and add weighted leaf prediction to scores.
+// Weight of node is nil when it's a child of the root and doesn't need to be multiplied
+func (t CARTTree) aggregate(node *Node, weight *rlwe.Ciphertext, answers map[*Node]*rlwe.Ciphertext, scores []*rlwe.Ciphertext) {
+
+	if node.IsLeaf() {
+
+		for class := range scores {
+
+			if node.Prediction[class] == 0 {
+				continue
+			}
+
+			weighted := t.weigh(weight, node.Prediction[class])
+
+			if scores[class] == nil {
+				scores[class] = weighted
+			} else {
+				t.utils.Add(scores[class], weighted, scores[class])
+			}
+
+		}
+
+		return
+
+	}
+
+	trueWeight := answers[node]
+	falseWeight := t.utils.Evaluator.NegNew(trueWeight)
+	t.utils.Evaluator.AddConst(falseWeight, 1, falseWeight)
+
+	if weight != nil {
+		trueWeight = t.utils.MultiplyNew(weight, trueWeight, true, false)
+		falseWeight = t.utils.MultiplyNew(weight, falseWeight, true, false)
+	}
+
+	t.aggregate(node.True, trueWeight, answers, scores)
+	t.aggregate(node.False, falseWeight, answers, scores)
+
+}
+
+// Multiply weight by constant and set its scale to the default scale, consuming 1 level. Weights of leaves at different
+// depths have slightly different scales after polynomial evaluation and rescaling, so they can only be added without error this way
+func (t CARTTree) weigh(weight *rlwe.Ciphertext, constant float64) *rlwe.Ciphertext {
+
+	scale := rlwe.NewScale(t.utils.Scale)
+
+	weighted := t.utils.MultiplyConstNew(weight, constant*scale.Float64()/weight.Scale.Float64(), false, false)
+	t.utils.Evaluator.Rescale(weighted, scale, weighted)
+	weighted.Scale = scale
+
+	return weighted
+
+}
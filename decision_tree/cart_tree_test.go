@@ -0,0 +1,98 @@
+package decisiontree
+
+import (
+	"math"
+	"testing"
+
+	"github.com/perm-ai/go-cerebrum/internal/utilitytest"
+	"github.com/perm-ai/go-cerebrum/key"
+	"github.com/perm-ai/go-cerebrum/utility"
+	"github.com/tuneinsight/lattigo/v4/ckks"
+	"github.com/tuneinsight/lattigo/v4/rlwe"
+)
+
+// Class 0 where both features are low, class 1 where only the second is high and class 2 where the first is high
+var trainRows = [][]float64{
+	{0.1, 0.2}, {0.2, 0.1}, {0.3, 0.3}, {0.2, 0.35},
+	{0.1, 0.7}, {0.3, 0.8}, {0.2, 0.9}, {0.35, 0.65},
+	{0.7, 0.2}, {0.8, 0.8}, {0.9, 0.5}, {0.65, 0.1},
+}
+var trainLabels = []int{0, 0, 0, 0, 1, 1, 1, 1, 2, 2, 2, 2}
+
+func TestCARTFit(t *testing.T) {
+
+	tree := NewCARTTree(utility.Utils{}, 3, 3, 2)
+
+	if err := tree.Fit(trainRows, trainLabels); err != nil {
+		t.Fatal(err)
+	}
+
+	if tree.Depth() != 2 {
+		t.Errorf("Expected tree of depth 2 but got %d", tree.Depth())
+	}
+
+	for i, scores := range tree.ClassifyPlain(trainRows) {
+		if scores[trainLabels[i]] != 1 {
+			t.Errorf("Row %d expected class %d but got scores %v", i, trainLabels[i], scores)
+		}
+	}
+
+	if err := tree.Fit(trainRows, []int{0, 1}); err == nil {
+		t.Error("Rows and labels of different length should return an error")
+	}
+
+	if err := tree.Fit([][]float64{{0}}, []int{3}); err == nil {
+		t.Error("Label that isn't a class should return an error")
+	}
+
+}
+
+func TestCARTPredict(t *testing.T) {
+
+	utils := utilitytest.NewUtils(key.NewParameters(ckks.PN15QP880, nil), math.Pow(2, 40))
+
+	tree := NewCARTTree(utils, 3, 2, 2)
+	if err := tree.Fit(trainRows, trainLabels); err != nil {
+		t.Fatal(err)
+	}
+
+	// Leaves are only partly pure when depth is limited to 1
+	shallow := NewCARTTree(utils, 3, 1, 2)
+	if err := shallow.Fit(trainRows, trainLabels); err != nil {
+		t.Fatal(err)
+	}
+
+	testRows := [][]float64{{0.15, 0.15}, {0.25, 0.75}, {0.85, 0.3}, {0.75, 0.9}, {0.4, 0.4}}
+
+	x := make([]*rlwe.Ciphertext, 2)
+	for feature := range x {
+		values := make([]float64, len(testRows))
+		for i := range testRows {
+			values[i] = testRows[i][feature]
+		}
+		x[feature] = utils.EncryptToLevel(values, utils.Params.MaxLevel())
+	}
+
+	for name, model := range map[string]CARTTree{"depth 2": tree, "depth 1": shallow} {
+
+		scores := model.Predict(x)
+		expected := model.ClassifyPlain(testRows)
+
+		for class := range scores {
+
+			if consumed := utils.Params.MaxLevel() - scores[class].Level(); consumed > model.GetLevelConsumption() {
+				t.Errorf("%s: score of class %d consumed %d levels but %d was reported", name, class, consumed, model.GetLevelConsumption())
+			}
+
+			decrypted := utils.Decrypt(scores[class])
+			for i := range testRows {
+				if math.Abs(decrypted[i]-expected[i][class]) > 0.02 {
+					t.Errorf("%s: score of class %d for row %d expected %f but got %f", name, class, i, expected[i][class], decrypted[i])
+				}
+			}
+
+		}
+
+	}
+
+}
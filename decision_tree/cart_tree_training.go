@@ -0,0 +1,368 @@
+package decisiontree
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+
+	"github.com/perm-ai/go-cerebrum/utility"
+	"github.com/tuneinsight/lattigo/v4/rlwe"
+)
+
+// Share of rows added to the denominator of approximated gini so it stays bounded when a side of a split is empty
+const giniEpsilon = 0.1
+
+// Degree of Chebyshev approximation of 1 / (share + giniEpsilon) over [0, 1]
+const giniInverseDegree = 15
+
+// EncryptedCARTTrainer grows a complete tree of depth MaxDepth on encrypted one-hot bins produced by Binner.
+// The split of each node is selected by comparing approximated gini scores homomorphically and rows are
+// routed to children with encrypted masks, so only the key holder can learn the structure of the tree
+type EncryptedCARTTrainer struct {
+	utils      utility.Utils
+	NumClasses int
+	MaxDepth   int
+	Comparison utility.ComparisonParams // Scores lie in [0, 1] so Bound must be at least 1
+	inverse    utility.Polynomial
+}
+
+// Split candidate asking whether feature falls in bin or any bin after it
+type Candidate struct {
+	Feature int
+	Bin     int
+}
+
+// Node of a tree trained on encrypted data. Internal node holds encrypted index of its split in candidates
+// and leaf holds encrypted share of each class among all training rows, both in every slot
+type EncryptedNode struct {
+	Split  *rlwe.Ciphertext
+	True   *EncryptedNode
+	False  *EncryptedNode
+	Shares []*rlwe.Ciphertext
+}
+
+type EncryptedTree struct {
+	Root       *EncryptedNode
+	Candidates []Candidate
+	NumClasses int
+	Rows       int
+}
+
+// Split candidate of a node being compared with the others
+type split struct {
+	score     *rlwe.Ciphertext
+	index     *rlwe.Ciphertext
+	indicator *rlwe.Ciphertext // 1 in slots of rows that match the candidate
+}
+
+func NewEncryptedCARTTrainer(u utility.Utils, numClasses int, maxDepth int) EncryptedCARTTrainer {
+
+	inverse := utility.FitChebyshev(func(x float64) float64 { return 1 / (x + giniEpsilon) }, 0, 1, giniInverseDegree, u)
+
+	return EncryptedCARTTrainer{utils: u, NumClasses: numClasses, MaxDepth: maxDepth, Comparison: utility.DefaultComparisonParams(1), inverse: inverse}
+
+}
+
+// Rotations performed by Fit
+func (t EncryptedCARTTrainer) PlanRotations(planner *utility.RotationPlanner) {
+	planner.AddSumElements()
+}
+
+// Grow tree from x[feature][bin] and y[class] encrypted with Binner.Encrypt and EncryptLabels where the first rows
+// slots hold the training data. Ciphertexts are bootstrapped whenever their level is too low for the next step
+func (t EncryptedCARTTrainer) Fit(x [][]*rlwe.Ciphertext, y []*rlwe.Ciphertext, rows int) (EncryptedTree, error) {
+
+	if len(y) != t.NumClasses {
+		return EncryptedTree{}, fmt.Errorf("expected labels of %d classes but got %d", t.NumClasses, len(y))
+	}
+
+	if rows <= 0 || rows > t.utils.Params.Slots() {
+		return EncryptedTree{}, fmt.Errorf("number of rows must be in [1, %d] but got %d", t.utils.Params.Slots(), rows)
+	}
+
+	candidates := []Candidate{}
+	for feature := range x {
+		for bin := 1; bin < len(x[feature]); bin++ {
+			candidates = append(candidates, Candidate{Feature: feature, Bin: bin})
+		}
+	}
+
+	if len(candidates) == 0 && t.MaxDepth > 0 {
+		return EncryptedTree{}, errors.New("every feature has a single bin so there is nothing to split on")
+	}
+
+	// Labels weighted by 1 / rows so counts are shares in [0, 1]
+	weightedLabels := make([]*rlwe.Ciphertext, len(y))
+	for class := range y {
+		weightedLabels[class] = t.utils.MultiplyConstNew(y[class], 1/float64(rows), true, false)
+	}
+
+	root := t.grow(x, weightedLabels, candidates, nil, 0)
+
+	return EncryptedTree{Root: root, Candidates: candidates, NumClasses: t.NumClasses, Rows: rows}, nil
+
+}
+
+// Grow node for rows in mask, nil mask meaning every row
+func (t EncryptedCARTTrainer) grow(x [][]*rlwe.Ciphertext, labels []*rlwe.Ciphertext, candidates []Candidate, mask *rlwe.Ciphertext, depth int) *EncryptedNode {
+
+	// Labels of rows that reach this node
+	nodeLabels := labels
+	if mask != nil {
+		t.utils.Bootstrap1dIfBelow([]*rlwe.Ciphertext{mask}, 1)
+		nodeLabels = make([]*rlwe.Ciphertext, len(labels))
+		for class := range labels {
+			nodeLabels[class] = t.utils.MultiplyNew(mask, labels[class], true, false)
+		}
+	}
+
+	t.utils.Bootstrap1dIfBelow(nodeLabels, 1)
+
+	if depth == t.MaxDepth {
+
+		shares := make([]*rlwe.Ciphertext, len(nodeLabels))
+		for class := range nodeLabels {
+			shares[class] = t.utils.SumElementsNew(*nodeLabels[class])
+		}
+
+		return &EncryptedNode{Shares: shares}
+
+	}
+
+	best := t.selectSplit(t.scoreSplits(x, nodeLabels, candidates))
+
+	// Rows that reach each child
+	notIndicator := t.utils.Evaluator.NegNew(best.indicator)
+	t.utils.Evaluator.AddConst(notIndicator, 1, notIndicator)
+
+	trueMask, falseMask := best.indicator, notIndicator
+	if mask != nil {
+		t.utils.Bootstrap1dIfBelow([]*rlwe.Ciphertext{mask, best.indicator, notIndicator}, 1)
+		trueMask = t.utils.MultiplyNew(mask, best.indicator, true, false)
+		falseMask = t.utils.MultiplyNew(mask, notIndicator, true, false)
+	}
+
+	return &EncryptedNode{
+		Split: best.index,
+		True:  t.grow(x, labels, candidates, trueMask, depth+1),
+		False: t.grow(x, labels, candidates, falseMask, depth+1),
+	}
+
+}
+
+// Compute approximated gini score of every candidate concurrently
+func (t EncryptedCARTTrainer) scoreSplits(x [][]*rlwe.Ciphertext, nodeLabels []*rlwe.Ciphertext, candidates []Candidate) []split {
+
+	splits := make([]split, len(candidates))
+
+	var wg sync.WaitGroup
+
+	for i := range candidates {
+
+		wg.Add(1)
+
+		go func(i int, utils utility.Utils) {
+
+			defer wg.Done()
+
+			// Bins of one-hot feature are mutually exclusive so their sum is exactly 0 or 1
+			bins := x[candidates[i].Feature]
+			indicator := bins[candidates[i].Bin].CopyNew()
+			for bin := candidates[i].Bin + 1; bin < len(bins); bin++ {
+				utils.Add(indicator, bins[bin], indicator)
+			}
+
+			notIndicator := utils.Evaluator.NegNew(indicator)
+			utils.Evaluator.AddConst(notIndicator, 1, notIndicator)
+
+			score := t.sideScore(nodeLabels, indicator, utils)
+			utils.Add(score, t.sideScore(nodeLabels, notIndicator, utils), score)
+
+			index := utils.MultiplyConstNew(score, 0, false, false)
+			utils.Evaluator.AddConst(index, float64(i), index)
+
+			splits[i] = split{score: score, index: index, indicator: indicator}
+
+		}(i, t.utils.CopyWithClonedEval())
+
+	}
+
+	wg.Wait()
+
+	return splits
+
+}
+
+// Sum of squared class shares divided by the share of rows on a side of split. Weighted gini impurity of a split
+// is the share of rows at node minus the sum of scores of both sides, so the split with the highest score is the purest
+func (t EncryptedCARTTrainer) sideScore(nodeLabels []*rlwe.Ciphertext, side *rlwe.Ciphertext, utils utility.Utils) *rlwe.Ciphertext {
+
+	var share *rlwe.Ciphertext
+	var squared *rlwe.Ciphertext
+
+	for class := range nodeLabels {
+
+		classShare := utils.MultiplyNew(nodeLabels[class], side, true, false)
+		utils.SumElementsInPlace(classShare)
+
+		utils.Bootstrap1dIfBelow([]*rlwe.Ciphertext{classShare}, 1)
+		classSquared := utils.MultiplyNew(classShare, classShare, true, false)
+
+		if share == nil {
+			share, squared = classShare, classSquared
+		} else {
+			utils.Add(share, classShare, share)
+			utils.Add(squared, classSquared, squared)
+		}
+
+	}
+
+	utils.Bootstrap1dIfBelow([]*rlwe.Ciphertext{share}, t.inverse.GetLevelConsumption())
+	inverse := t.inverse.Evaluate(share, utils.Params.Slots())
+
+	utils.Bootstrap1dIfBelow([]*rlwe.Ciphertext{squared, inverse}, 1)
+
+	return utils.MultiplyNew(squared, inverse, true, false)
+
+}
+
+// Keep the split with the highest score with a tournament. Candidates are padded to a power of two with copies of the
+// last one so every winner is computed the same way and has the same scale. Tied splits are averaged
+func (t EncryptedCARTTrainer) selectSplit(splits []split) split {
+
+	length := 1
+	for length < len(splits) {
+		length *= 2
+	}
+
+	for len(splits) < length {
+		last := splits[len(splits)-1]
+		splits = append(splits, split{score: last.score.CopyNew(), index: last.index.CopyNew(), indicator: last.indicator.CopyNew()})
+	}
+
+	for len(splits) > 1 {
+
+		winners := make([]split, len(splits)/2)
+
+		var wg sync.WaitGroup
+
+		for i := range winners {
+
+			wg.Add(1)
+
+			go func(i int, utils utility.Utils) {
+
+				defer wg.Done()
+
+				a, b := splits[2*i], splits[2*i+1]
+				c := utils.Compare(a.score, b.score, t.Comparison)
+
+				winners[i] = split{
+					score:     utils.SelectNew(c, a.score, b.score),
+					index:     utils.SelectNew(c, a.index, b.index),
+					indicator: utils.SelectNew(c, a.indicator, b.indicator),
+				}
+
+			}(i, t.utils.CopyWithClonedEval())
+
+		}
+
+		wg.Wait()
+
+		splits = winners
+
+	}
+
+	return splits[0]
+
+}
+
+// Decrypt tree into CARTTree that can classify plaintext or encrypted values. Only available to the key holder.
+// Leaf that no training row reached predicts the classes of its parent
+func (t EncryptedTree) Decrypt(utils utility.Utils, binner Binner) CARTTree {
+
+	shares := make(map[*EncryptedNode][]float64)
+
+	var decryptShares func(node *EncryptedNode) []float64
+	decryptShares = func(node *EncryptedNode) []float64 {
+
+		result := make([]float64, t.NumClasses)
+
+		if node.True == nil {
+			for class := range result {
+				result[class] = math.Max(utils.Decrypt(node.Shares[class])[0], 0)
+			}
+		} else {
+			trueShares, falseShares := decryptShares(node.True), decryptShares(node.False)
+			for class := range result {
+				result[class] = trueShares[class] + falseShares[class]
+			}
+		}
+
+		shares[node] = result
+
+		return result
+
+	}
+
+	// Predict parent's classes when less than half a row reached the node
+	var build func(node *EncryptedNode, fallback []float64) *Node
+	build = func(node *EncryptedNode, fallback []float64) *Node {
+
+		prediction := normalize(shares[node])
+		if total(shares[node]) < 0.5/float64(t.Rows) && fallback != nil {
+			prediction = fallback
+		}
+
+		if node.True == nil {
+			return &Node{Prediction: prediction}
+		}
+
+		index := int(math.Round(utils.Decrypt(node.Split)[0]))
+		index = int(math.Min(math.Max(float64(index), 0), float64(len(t.Candidates)-1)))
+
+		return &Node{
+			Question: binner.Question(t.Candidates[index].Feature, t.Candidates[index].Bin),
+			True:     build(node.True, prediction),
+			False:    build(node.False, prediction),
+		}
+
+	}
+
+	decryptShares(t.Root)
+
+	tree := CARTTree{utils: utils, NumClasses: t.NumClasses, Comparison: utility.DefaultComparisonParams(binner.Bound())}
+	tree.Root = build(t.Root, nil)
+	tree.MaxDepth = tree.Depth()
+
+	return tree
+
+}
+
+func total(values []float64) float64 {
+
+	sum := 0.0
+	for _, value := range values {
+		sum += value
+	}
+
+	return sum
+
+}
+
+// Scale values so they sum to 1
+func normalize(values []float64) []float64 {
+
+	sum := total(values)
+	result := make([]float64, len(values))
+
+	for i := range values {
+		if sum != 0 {
+			result[i] = values[i] / sum
+		}
+	}
+
+	return result
+
+}
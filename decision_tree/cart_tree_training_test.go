@@ -0,0 +1,94 @@
+package decisiontree
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/perm-ai/go-cerebrum/internal/utilitytest"
+	"github.com/perm-ai/go-cerebrum/utility"
+)
+
+// Median of each feature separates class 2 from the others and class 0 from class 1
+var binnedRows = [][]float64{
+	{0.1, 0.1}, {0.2, 0.3},
+	{0.3, 0.7}, {0.1, 0.9},
+	{0.7, 0.2}, {0.8, 0.8}, {0.9, 0.6}, {0.6, 0.4},
+}
+var binnedLabels = []int{0, 0, 1, 1, 2, 2, 2, 2}
+
+func TestBinner(t *testing.T) {
+
+	binner := NewBinner(binnedRows, 2)
+
+	if !reflect.DeepEqual(binner.Edges, [][]float64{{0.6}, {0.6}}) {
+		t.Errorf("Expected median edges but got %v", binner.Edges)
+	}
+
+	oneHot := binner.Transform([][]float64{{0.59, 0.6}})
+	if oneHot[0][0][0] != 1 || oneHot[0][1][0] != 0 || oneHot[1][0][0] != 0 || oneHot[1][1][0] != 1 {
+		t.Errorf("Value below edge should be in bin 0 and value at edge in bin 1 but got %v", oneHot)
+	}
+
+	if question := binner.Question(1, 1); !question.Match([]float64{0, 0.6}) || question.Match([]float64{0, 0.59}) {
+		t.Errorf("Question %v should match values in bin 1 only", question)
+	}
+
+	if bins := NewBinner([][]float64{{1}, {1}, {2}}, 4).NumBins(0); bins != 2 {
+		t.Errorf("Feature with 2 unique values should have 2 bins but got %d", bins)
+	}
+
+}
+
+func TestEncryptedCARTFit(t *testing.T) {
+
+	// Insecure parameters with enough levels to train without bootstrapping
+	utils := utilitytest.NewUtils(utilitytest.InsecureParameters(), math.Pow(2, 40), EncryptedCARTTrainer{}.PlanRotations)
+
+	// Data owner bins and encrypts the data
+	binner := NewBinner(binnedRows, 2)
+	x := binner.Encrypt(utils, binnedRows)
+	y := EncryptLabels(utils, binnedLabels, 3)
+
+	// Key holder decrypts the structure and checks that no class scores clearly higher than the label of each row, which scores at least minScore
+	validate := func(name string, trainer EncryptedCARTTrainer, minScore float64) {
+
+		encryptedTree, err := trainer.Fit(x, y, len(binnedRows))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		tree := encryptedTree.Decrypt(utils, binner)
+
+		if tree.Depth() != trainer.MaxDepth {
+			t.Errorf("%s: expected tree of depth %d but got %d", name, trainer.MaxDepth, tree.Depth())
+		}
+
+		if tree.Root.Question != binner.Question(0, 1) {
+			t.Errorf("%s: root should split on feature 0 but got %v", name, tree.Root.Question)
+		}
+
+		for i, scores := range tree.ClassifyPlain(binnedRows) {
+			for class := range scores {
+				if scores[class] > scores[binnedLabels[i]]+1e-3 || scores[binnedLabels[i]] < minScore {
+					t.Errorf("%s: row %d expected class %d but got scores %v", name, i, binnedLabels[i], scores)
+					break
+				}
+			}
+		}
+
+	}
+
+	// Leaf of depth 1 holds class 0 and 1 equally
+	validate("depth 1", NewEncryptedCARTTrainer(utils, 3, 1), 0.45)
+
+	// Fewer sign iterations so depth 2 fits in the levels available, splits are selected less sharply
+	shallowComparison := NewEncryptedCARTTrainer(utils, 3, 2)
+	shallowComparison.Comparison = utility.ComparisonParams{Bound: 1, GIteration: 1, FIteration: 1}
+	validate("depth 2", shallowComparison, 0.7)
+
+	if _, err := NewEncryptedCARTTrainer(utils, 3, 1).Fit(x, y[:2], len(binnedRows)); err == nil {
+		t.Error("Labels of fewer classes should return an error")
+	}
+
+}
@@ -1,87 +1,259 @@
 package decisiontree
 
 import (
-	// "github.com/ldsec/lattigo/v2/ckks"
-	// "github.com/perm-ai/go-cerebrum/utility"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/perm-ai/go-cerebrum/utility"
 )
 
-// methods to create:
+// Question asks whether a feature of a row is greater than or equal to the threshold
+type Question struct {
+	Feature   int     `json:"feature"`
+	Threshold float64 `json:"threshold"`
+}
+
+// Check whether the row matches the question
+func (q Question) Match(row []float64) bool {
+	return row[q.Feature] >= q.Threshold
+}
+
+// Node of a decision tree. Leaf has no children and stores the share of each class among training rows that reached it
+type Node struct {
+	Question   Question  `json:"question"`
+	True       *Node     `json:"true,omitempty"`
+	False      *Node     `json:"false,omitempty"`
+	Prediction []float64 `json:"prediction,omitempty"`
+}
+
+func (n *Node) IsLeaf() bool {
+	return n.True == nil
+}
+
+// Number of questions asked from this node to the deepest leaf
+func (n *Node) Depth() int {
+
+	if n.IsLeaf() {
+		return 0
+	}
+
+	return int(math.Max(float64(n.True.Depth()), float64(n.False.Depth()))) + 1
+
+}
+
+// Share of each class predicted for a plaintext row
+func (n *Node) Classify(row []float64) []float64 {
+
+	if n.IsLeaf() {
+		return n.Prediction
+	}
+
+	if n.Question.Match(row) {
+		return n.True.Classify(row)
+	}
+
+	return n.False.Classify(row)
+
+}
+
+// CART classification tree trained on plaintext data that can classify encrypted data
+type CARTTree struct {
+	utils           utility.Utils
+	Root            *Node
+	NumClasses      int
+	MaxDepth        int
+	MinSamplesSplit int                      // Node with fewer rows than this becomes a leaf
+	Comparison      utility.ComparisonParams // Distance between feature and threshold must lie in [-Bound, Bound], set by Fit
+}
+
+func NewCARTTree(u utility.Utils, numClasses int, maxDepth int, minSamplesSplit int) CARTTree {
+	return CARTTree{utils: u, NumClasses: numClasses, MaxDepth: maxDepth, MinSamplesSplit: minSamplesSplit}
+}
+
+// Grow tree from rows of features and their labels from 0 to NumClasses - 1. Comparison bound is set to
+// the widest range of a feature in rows so thresholds can be compared with any value in that range
+func (t *CARTTree) Fit(rows [][]float64, labels []int) error {
+
+	if len(rows) == 0 {
+		return errors.New("no training data")
+	}
+
+	if len(rows) != len(labels) {
+		return fmt.Errorf("got %d rows but %d labels", len(rows), len(labels))
+	}
+
+	for i := range rows {
+		if len(rows[i]) != len(rows[0]) {
+			return fmt.Errorf("row %d has %d features, expected %d", i, len(rows[i]), len(rows[0]))
+		}
+		if labels[i] < 0 || labels[i] >= t.NumClasses {
+			return fmt.Errorf("label %d of row %d isn't in [0, %d)", labels[i], i, t.NumClasses)
+		}
+	}
+
+	bound := 0.0
+	for feature := range rows[0] {
+		values := uniqueValues(rows, feature)
+		bound = math.Max(bound, values[len(values)-1]-values[0])
+	}
+
+	if bound == 0 {
+		bound = 1
+	}
+
+	t.Comparison = utility.DefaultComparisonParams(bound)
+	t.Root = t.buildTree(rows, labels, 0)
+
+	return nil
+
+}
+
+// Split rows with the question that reduces gini impurity the most until MaxDepth is reached or no question helps
+func (t CARTTree) buildTree(rows [][]float64, labels []int, depth int) *Node {
+
+	counts := countClass(labels, t.NumClasses)
+
+	if depth < t.MaxDepth && len(rows) >= t.MinSamplesSplit {
+
+		gain, question := findBestSplit(rows, labels, t.NumClasses)
+
+		if gain > 0 {
+			trueRows, trueLabels, falseRows, falseLabels := partition(rows, labels, question)
+			return &Node{
+				Question: question,
+				True:     t.buildTree(trueRows, trueLabels, depth+1),
+				False:    t.buildTree(falseRows, falseLabels, depth+1),
+			}
+		}
+
+	}
+
+	prediction := make([]float64, t.NumClasses)
+	for class := range counts {
+		prediction[class] = counts[class] / float64(len(labels))
+	}
+
+	return &Node{Prediction: prediction}
+
+}
+
+// Maximum depth of the fitted tree
+func (t CARTTree) Depth() int {
+	return t.Root.Depth()
+}
+
+// Share of each class predicted for every plaintext row
+func (t CARTTree) ClassifyPlain(rows [][]float64) [][]float64 {
+
+	result := make([][]float64, len(rows))
+	for i := range rows {
+		result[i] = t.Root.Classify(rows[i])
+	}
+
+	return result
+
+}
+
+// Sorted unique values of a feature
+func uniqueValues(rows [][]float64, feature int) []float64 {
+
+	seen := make(map[float64]bool)
+	values := []float64{}
 
-// unique values- find a unique value for a column in a dataset
-// count values- count the number of classes input (column -1 or last column)
-// partition- for each row, check if the example belongs to the "true" row or the "false row"
-// gini purity- find gini impurity
-// information gain- find information gain from gini
-// find the best split-
+	for i := range rows {
+		if !seen[rows[i][feature]] {
+			seen[rows[i][feature]] = true
+			values = append(values, rows[i][feature])
+		}
+	}
 
-// final method to put everything together (build_tree)
+	sort.Float64s(values)
 
-// classify method for testing
+	return values
 
-// class to create:
+}
 
-// question class- store the questions asked
-// 				has a method that checks whether the input example match the value stored in the question struct
+// Number of rows of each class
+func countClass(labels []int, numClasses int) []float64 {
 
-// leaf class- 
+	counts := make([]float64, numClasses)
+	for _, label := range labels {
+		counts[label]++
+	}
 
-// decision node class-
+	return counts
 
-// Example Data- 
+}
 
+// Gini impurity of class counts, 1 - sum of squared class probability
+func gini(counts []float64) float64 {
 
-// need to tell number of class
+	total := 0.0
+	for _, count := range counts {
+		total += count
+	}
 
+	if total == 0 {
+		return 0
+	}
 
+	impurity := 1.0
+	for _, count := range counts {
+		impurity -= math.Pow(count/total, 2)
+	}
 
-// type question struct {
-	
-// }
+	return impurity
 
-// type leaf struct {
+}
 
-// }
+// Split rows into those that match the question and those that don't
+func partition(rows [][]float64, labels []int, question Question) (trueRows [][]float64, trueLabels []int, falseRows [][]float64, falseLabels []int) {
 
-// type decisionNode struct {
+	for i := range rows {
+		if question.Match(rows[i]) {
+			trueRows = append(trueRows, rows[i])
+			trueLabels = append(trueLabels, labels[i])
+		} else {
+			falseRows = append(falseRows, rows[i])
+			falseLabels = append(falseLabels, labels[i])
+		}
+	}
 
-// }
+	return
 
-// func findUniqueValue() {
-// 	// find every unique value in an array
-	
-// }
+}
 
+// Find the question with the highest gini gain. Thresholds are midpoints between consecutive unique values
+// so encrypted values are as far from them as the training data allows
+func findBestSplit(rows [][]float64, labels []int, numClasses int) (float64, Question) {
 
+	bestGain := 0.0
+	bestQuestion := Question{}
+	currentImpurity := gini(countClass(labels, numClasses))
 
-// func countClass(labelColumn []float64) {
-	
-// 	//find how many class is in a dataset
-// 	// ***might not need if number of classes are specified by data owner***
-	
-// }
+	for feature := range rows[0] {
 
-// func contains(s []int, e int) bool {
-//     for a := range s {
-//         if a == e {
-//             return true
-//         }
-//     }
-//     return false
-// }
+		values := uniqueValues(rows, feature)
 
-// testSlice := []float64{1,0,0,1,2}
+		for i := 1; i < len(values); i++ {
 
-// func partition() {
-// 	// Partitions a dataset.
-//     // For each row in the dataset, check if it matches the question. If
-//     // so, add it to 'true rows', otherwise, add it to 'false rows'.
-    
+			question := Question{Feature: feature, Threshold: (values[i-1] + values[i]) / 2}
+			_, trueLabels, _, falseLabels := partition(rows, labels, question)
 
-// }
+			trueWeight := float64(len(trueLabels)) / float64(len(labels))
+			impurity := trueWeight*gini(countClass(trueLabels, numClasses)) + (1-trueWeight)*gini(countClass(falseLabels, numClasses))
 
-// func gini() {
+			if gain := currentImpurity - impurity; gain > bestGain {
+				bestGain = gain
+				bestQuestion = question
+			}
 
-// }
+		}
 
+	}
 
+	return bestGain, bestQuestion
 
+}
@@ -5,6 +5,7 @@ import (
 	"math"
 	"os"
 
+	"github.com/perm-ai/go-cerebrum/dataset"
 	"github.com/perm-ai/go-cerebrum/importer"
 	"github.com/perm-ai/go-cerebrum/key"
 	"github.com/perm-ai/go-cerebrum/logger"
@@ -19,7 +20,12 @@ func check(err error) {
 	}
 }
 
-func LinearRegression(keyPath string, csv string, x int, y int, lr float64, epoch int, dest string) {
+// Fraction of rows of the csv kept out of training to evaluate the model
+const holdoutFraction = 0.1
+
+// Train linear regression on feature columns x of csv to predict column y. Batch size of 0 trains on the whole
+// training data at once, l2 is the strength of L2 penalty on weights
+func LinearRegression(keyPath string, csv string, x []int, y int, lr float64, batchSize int, l2 float64, epoch int, dest string) {
 
 	// Initialize logger
 	log := logger.NewLogger(true)
@@ -40,62 +46,103 @@ func LinearRegression(keyPath string, csv string, x int, y int, lr float64, epoc
 		panic("No csv filepath provided")
 	}
 
-	// Import csv data
+	// Import csv data, label is the last column
 	log.Log("Importing data from csv")
-	data := importer.GetCSV(csv, x, y)
+	data := importer.GetCSVNData(csv, append(append([]int{}, x...), y), false)
+	labels := data[len(x)]
+
+	length := len(labels) - int(float64(len(labels))*holdoutFraction)
+	if length > utils.Params.Slots() {
+		length = utils.Params.Slots()
+	}
+	holdoutLength := len(labels) - length
+	if holdoutLength > utils.Params.Slots() {
+		holdoutLength = utils.Params.Slots()
+	}
 
-	// Encrypt feature 1
+	// Encrypt features
 	log.Log("Encrypting X")
-	feature1 := utils.Encrypt(data.FirstData)
-	encX := []*rlwe.Ciphertext{&feature1}
-	encXbin, _ := encX[0].MarshalBinary()
+	names := make([]string, len(x))
+	encX := make(map[string]*rlwe.Ciphertext)
+	holdoutX := make([]*rlwe.Ciphertext, len(x))
+
+	for i := range x {
+		names[i] = fmt.Sprintf("x%d", i)
+		encX[names[i]] = utils.EncryptToPointer(data[i][:length])
+		holdoutX[i] = utils.EncryptToPointer(data[i][length : length+holdoutLength])
+	}
+
+	encXbin, _ := encX[names[0]].MarshalBinary()
 
 	// Log data
 	log.Log(fmt.Sprintf("Encrypted X [%f %f . . . %f %f] => [%b %b . . . %b %b]",
-		data.FirstData[0], data.FirstData[1], data.FirstData[len(data.FirstData)-2], data.FirstData[len(data.FirstData)-1],
+		data[0][0], data[0][1], data[0][length-2], data[0][length-1],
 		encXbin[0], encXbin[1], encXbin[len(encXbin)-2], encXbin[len(encXbin)-2]))
 
 	// Encrypt label
 	log.Log("Encrypting Y")
-	encY := utils.Encrypt(data.SecondData)
+	encY := utils.EncryptToPointer(labels[:length])
+	holdoutY := utils.EncryptToPointer(labels[length : length+holdoutLength])
 	encYbin, _ := encY.MarshalBinary()
 	log.Log(fmt.Sprintf("Encrypted Y [%f %f . . . %f %f] => [%b %b . . . %b %b]",
-		data.SecondData[0], data.SecondData[1], data.SecondData[len(data.FirstData)-2], data.SecondData[len(data.FirstData)-1],
+		labels[0], labels[1], labels[length-2], labels[length-1],
 		encYbin[0], encYbin[1], encYbin[len(encYbin)-2], encYbin[len(encYbin)-2]))
 
 	// Initialize linear regression model
 	log.Log("Initializing model")
-	model := regression.NewLinearRegression(utils, 1)
+	model := regression.NewLinearRegression(utils, len(x))
+	model.L2 = l2
 
 	// Begin training the model
 	log.Log("Begin training")
-	model.Train(encX, &encY, lr, len(data.FirstData), epoch)
+	if batchSize <= 0 {
+		trainX := make([]*rlwe.Ciphertext, len(x))
+		for i := range names {
+			trainX[i] = encX[names[i]]
+		}
+		model.Train(trainX, encY, lr, length, epoch)
+	} else {
+		loader := dataset.NewStandardLoader(encX, names, []*rlwe.Ciphertext{encY}, utils, length)
+		model.TrainMiniBatch(loader, batchSize, lr, epoch)
+	}
+
+	// Evaluate on holdout data, only the key holder can decrypt the result
+	if holdoutLength > 0 {
+		mse, r2, err := model.Evaluate(holdoutX, holdoutY, holdoutLength).Decrypt(utils)
+		if err != nil {
+			log.Log(fmt.Sprintf("Holdout MSE: %f (%s)", mse, err))
+		} else {
+			log.Log(fmt.Sprintf("Holdout MSE: %f R²: %f", mse, r2))
+		}
+	}
 
 	log.Log("Training complete, saving gradient")
 	os.Mkdir(dest, 0777)
 
 	// Print the trained weight
-	fmt.Println(utils.Decrypt(model.Weight[0])[0])
+	for i := range model.Weight {
+		fmt.Println(utils.Decrypt(model.Weight[i])[0])
+	}
 	fmt.Println(utils.Decrypt(model.Bias)[0])
 
 	// Save training results
-	mBytes, mByteErr := model.Weight[0].MarshalBinary()
-	check(mByteErr)
+	for i := range model.Weight {
+		save(model.Weight[i], fmt.Sprintf("%s/m%d", dest, i))
+	}
+	save(model.Bias, dest+"/b")
 
-	mFile, mFileErr := os.Create(dest + "/m")
-	check(mFileErr)
+	log.Log("Training result saved")
+}
 
-	_, mWriteErr := mFile.Write(mBytes)
-	check(mWriteErr)
+func save(ct *rlwe.Ciphertext, path string) {
 
-	bBytes, bByteErr := model.Bias.MarshalBinary()
-	check(bByteErr)
+	bytes, byteErr := ct.MarshalBinary()
+	check(byteErr)
 
-	bFile, bFileErr := os.Create(dest + "/b")
-	check(bFileErr)
+	file, fileErr := os.Create(path)
+	check(fileErr)
 
-	_, bWriteErr := bFile.Write(bBytes)
-	check(bWriteErr)
+	_, writeErr := file.Write(bytes)
+	check(writeErr)
 
-	log.Log("Training result saved")
 }
@@ -3,10 +3,12 @@ module github.com/perm-ai/go-cerebrum
 go 1.16
 
 require (
+	github.com/aws/aws-sdk-go-v2 v1.7.1
 	github.com/aws/aws-sdk-go-v2/service/s3 v1.11.1
 	// github.com/ldsec/lattigo/v2 v2.3.0
 	github.com/stretchr/testify v1.8.0 // indirect
+	github.com/tuneinsight/lattigo/v4 v4.1.0
 	golang.org/x/crypto v0.0.0-20220926161630-eccd6366d1be // indirect
 	golang.org/x/sys v0.0.0-20220928140112-f11e5e49a4ec // indirect
-	github.com/tuneinsight/lattigo/v4 v4.1.0
+	gopkg.in/yaml.v3 v3.0.1
 )
@@ -0,0 +1,42 @@
+// Package utilitytest creates utils with fresh keys for tests of the other packages
+package utilitytest
+
+import (
+	"github.com/perm-ai/go-cerebrum/key"
+	"github.com/perm-ai/go-cerebrum/utility"
+	"github.com/tuneinsight/lattigo/v4/ckks"
+)
+
+// Get insecure parameter descriptor of ring degree 2^12 with 32 levels of 40 bits, deep enough to train small models
+// without bootstrapping
+func InsecureParameters() key.Parameters {
+
+	schemeParams := ckks.ParametersLiteral{LogN: 12, LogQ: []int{55}, LogP: []int{55, 55, 55, 55}, LogSlots: 11, DefaultScale: 1 << 40}
+	for i := 0; i < 32; i++ {
+		schemeParams.LogQ = append(schemeParams.LogQ, 40)
+	}
+
+	return key.NewParameters(schemeParams, nil)
+
+}
+
+// Create utils of paramSet encoding at scale with a new key pair and keys for the rotations added by plans.
+// Bootstrapping is disabled
+func NewUtils(paramSet key.Parameters, scale float64, plans ...func(planner *utility.RotationPlanner)) utility.Utils {
+
+	params, err := paramSet.CKKSParameters()
+	if err != nil {
+		panic(err)
+	}
+
+	planner := utility.NewRotationPlanner(params)
+	for _, plan := range plans {
+		plan(planner)
+	}
+
+	keyPair := key.GenerateKeyPairWithParameters(paramSet)
+	keyChain := key.GenerateKeysForRotationsWithParameters(paramSet, keyPair.SecretKey, keyPair.PublicKey, planner.Rotations(), false, false)
+
+	return utility.NewUtils(keyChain, scale, 0, false)
+
+}
@@ -0,0 +1,480 @@
+package key
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/tuneinsight/lattigo/v4/rlwe"
+)
+
+//=================================================
+//				  KEYCHAIN BUNDLE
+//=================================================
+
+// Bundle file layout
+// [ magic (8 bytes) | format version (uint32) | header offset (uint64) | sections ... | header (json) ]
+// The header is written after every section so keys can be streamed to disk one at a time.
+
+const BundleFormatVersion = 1
+const BundleFileName = "keychain.bundle"
+
+const (
+	SecretKeySection            = "secret_key"
+	PublicKeySection            = "public_key"
+	RelinKeySection             = "relin_key"
+	RotationKeySection          = "rotation_key"
+	BootstrapRotationKeySection = "bootstrap_rotation_key"
+)
+
+var bundleMagic = []byte("CRBMKEYS")
+
+const bundlePreludeSize = 8 + 4 + 8
+
+type BundleSection struct {
+	Type          string
+	GaloisElement uint64 `json:",omitempty"`
+	Offset        int64
+	Length        int64
+	Checksum      string
+}
+
+type BundleHeader struct {
+	Version     int
+	ParamsIndex int
+	Parameters  Parameters
+	Sections    []BundleSection
+}
+
+// Check if bundle contains at least one section of sectionType
+func (h BundleHeader) Has(sectionType string) bool {
+
+	for _, section := range h.Sections {
+		if section.Type == sectionType {
+			return true
+		}
+	}
+
+	return false
+
+}
+
+// Get the galois elements of every section with sectionType sorted in ascending order
+func (h BundleHeader) GaloisElements(sectionType string) []uint64 {
+
+	galEls := []uint64{}
+
+	for _, section := range h.Sections {
+		if section.Type == sectionType {
+			galEls = append(galEls, section.GaloisElement)
+		}
+	}
+
+	sort.Slice(galEls, func(i, j int) bool { return galEls[i] < galEls[j] })
+
+	return galEls
+
+}
+
+// Find the section of sectionType with galois element galEl (galEl is ignored for non rotation key sections)
+func (h BundleHeader) Section(sectionType string, galEl uint64) (BundleSection, bool) {
+
+	for _, section := range h.Sections {
+		if section.Type == sectionType && (section.GaloisElement == galEl || (sectionType != RotationKeySection && sectionType != BootstrapRotationKeySection)) {
+			return section, true
+		}
+	}
+
+	return BundleSection{}, false
+
+}
+
+type bundleWriter struct {
+	w       io.WriteSeeker
+	pointer int64
+	header  BundleHeader
+}
+
+func (b *bundleWriter) writeSection(sectionType string, galEl uint64, data []byte) error {
+
+	n, err := b.w.Write(data)
+	if err != nil {
+		return err
+	}
+
+	checksum := sha256.Sum256(data)
+
+	b.header.Sections = append(b.header.Sections, BundleSection{
+		Type:          sectionType,
+		GaloisElement: galEl,
+		Offset:        b.pointer,
+		Length:        int64(n),
+		Checksum:      hex.EncodeToString(checksum[:]),
+	})
+
+	b.pointer += int64(n)
+
+	return nil
+
+}
+
+func (b *bundleWriter) writeRotationKeys(sectionType string, rtks *rlwe.RotationKeySet) error {
+
+	galEls := make([]uint64, 0, len(rtks.Keys))
+	for galEl := range rtks.Keys {
+		galEls = append(galEls, galEl)
+	}
+
+	sort.Slice(galEls, func(i, j int) bool { return galEls[i] < galEls[j] })
+
+	for _, galEl := range galEls {
+
+		data, err := rtks.Keys[galEl].MarshalBinary()
+		if err != nil {
+			return err
+		}
+
+		if err = b.writeSection(sectionType, galEl, data); err != nil {
+			return err
+		}
+
+	}
+
+	return nil
+
+}
+
+// Write selected keys of the keychain into a single bundle starting at the current position of w.
+// Offsets in the bundle are relative to that position
+func (k KeyChain) WriteBundle(w io.WriteSeeker, sk bool, pk bool, rlk bool, galk bool, btpGalK bool) error {
+
+	toWrite := []bool{sk, pk, rlk, galk, btpGalK}
+	present := []bool{k.SecretKey != nil, k.PublicKey != nil, k.RelinKey != nil, k.GaloisKey != nil, k.BtspGalKey != nil}
+	sectionTypes := []string{SecretKeySection, PublicKeySection, RelinKeySection, RotationKeySection, BootstrapRotationKeySection}
+
+	for i := range toWrite {
+		if toWrite[i] && !present[i] {
+			return fmt.Errorf("keychain doesn't have %s", sectionTypes[i])
+		}
+	}
+
+	start, err := w.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+
+	// Write prelude with placeholder header offset
+	prelude := make([]byte, bundlePreludeSize)
+	copy(prelude, bundleMagic)
+	binary.BigEndian.PutUint32(prelude[8:], BundleFormatVersion)
+
+	if _, err = w.Write(prelude); err != nil {
+		return err
+	}
+
+	writer := bundleWriter{w: w, pointer: bundlePreludeSize, header: BundleHeader{
+		Version:     BundleFormatVersion,
+		ParamsIndex: k.ParamsIndex,
+		Parameters:  k.GetParameters(),
+	}}
+
+	for i := range toWrite {
+
+		if !toWrite[i] {
+			continue
+		}
+
+		var data []byte
+		var err error
+
+		switch i {
+		case 0:
+			data, err = k.SecretKey.MarshalBinary()
+		case 1:
+			data, err = k.PublicKey.MarshalBinary()
+		case 2:
+			data, err = k.RelinKey.MarshalBinary()
+		case 3:
+			err = writer.writeRotationKeys(sectionTypes[i], k.GaloisKey)
+		case 4:
+			err = writer.writeRotationKeys(sectionTypes[i], k.BtspGalKey)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if i < 3 {
+			if err = writer.writeSection(sectionTypes[i], 0, data); err != nil {
+				return err
+			}
+		}
+
+	}
+
+	// Write header after sections then patch header offset in prelude
+	headerBytes, err := json.Marshal(writer.header)
+	if err != nil {
+		return err
+	}
+
+	if _, err = w.Write(headerBytes); err != nil {
+		return err
+	}
+
+	offset := make([]byte, 8)
+	binary.BigEndian.PutUint64(offset, uint64(writer.pointer))
+
+	if _, err = w.Seek(start+12, io.SeekStart); err != nil {
+		return err
+	}
+
+	if _, err = w.Write(offset); err != nil {
+		return err
+	}
+
+	_, err = w.Seek(0, io.SeekEnd)
+
+	return err
+
+}
+
+// Save selected keys of the keychain into a single bundle file
+func (k KeyChain) DumpBundle(filePath string, sk bool, pk bool, rlk bool, galk bool, btpGalK bool) {
+	check(k.TryDumpBundle(filePath, sk, pk, rlk, galk, btpGalK))
+}
+
+// Error returning variant of DumpBundle
+func (k KeyChain) TryDumpBundle(filePath string, sk bool, pk bool, rlk bool, galk bool, btpGalK bool) error {
+
+	f, err := os.Create(filePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return k.WriteBundle(f, sk, pk, rlk, galk, btpGalK)
+
+}
+
+// Read and validate the header of a bundle. size is the total size of the bundle in bytes.
+// Every section must lie between the prelude and the header
+func ReadBundleHeader(r io.ReaderAt, size int64) (BundleHeader, error) {
+
+	if size < bundlePreludeSize {
+		return BundleHeader{}, &CorruptFileError{Location: "bundle", Err: errors.New("file is too short to be a keychain bundle")}
+	}
+
+	prelude := make([]byte, bundlePreludeSize)
+	if _, err := r.ReadAt(prelude, 0); err == io.EOF || err == io.ErrUnexpectedEOF {
+		return BundleHeader{}, &CorruptFileError{Location: "bundle", Err: errors.New("prelude is truncated")}
+	} else if err != nil {
+		return BundleHeader{}, err
+	}
+
+	if !bytes.Equal(prelude[:8], bundleMagic) {
+		return BundleHeader{}, &CorruptFileError{Location: "bundle", Err: errors.New("file isn't a keychain bundle")}
+	}
+
+	version := binary.BigEndian.Uint32(prelude[8:])
+	if version == 0 {
+		return BundleHeader{}, &CorruptFileError{Location: "bundle", Err: errors.New("format version 0 is invalid")}
+	}
+	if version > BundleFormatVersion {
+		return BundleHeader{}, fmt.Errorf("unsupported bundle format version %d", version)
+	}
+
+	headerOffset := int64(binary.BigEndian.Uint64(prelude[12:]))
+	if headerOffset < bundlePreludeSize || headerOffset >= size {
+		return BundleHeader{}, &CorruptFileError{Location: "bundle", Err: errors.New("header offset is out of range")}
+	}
+
+	headerBytes := make([]byte, size-headerOffset)
+	if _, err := r.ReadAt(headerBytes, headerOffset); err != nil && err != io.EOF {
+		return BundleHeader{}, err
+	}
+
+	header := BundleHeader{}
+	if err := json.Unmarshal(headerBytes, &header); err != nil {
+		return BundleHeader{}, &CorruptFileError{Location: "bundle header", Err: err}
+	}
+
+	for _, section := range header.Sections {
+		if section.Offset < bundlePreludeSize || section.Length < 0 || section.Length > headerOffset-section.Offset {
+			return BundleHeader{}, &CorruptFileError{Location: fmt.Sprintf("bundle section %s (galois element %d)", section.Type, section.GaloisElement), Err: errors.New("section is out of range")}
+		}
+	}
+
+	return header, nil
+
+}
+
+// Read a section's data and verify its checksum. Section should come from ReadBundleHeader, which checks that it's
+// within the bundle. Returns *CorruptFileError when the section is out of range, truncated or its checksum mismatches
+func ReadBundleSection(r io.ReaderAt, section BundleSection) ([]byte, error) {
+
+	location := fmt.Sprintf("bundle section %s (galois element %d)", section.Type, section.GaloisElement)
+
+	if section.Offset < bundlePreludeSize || section.Length < 0 {
+		return nil, &CorruptFileError{Location: location, Err: errors.New("section is out of range")}
+	}
+
+	data := make([]byte, section.Length)
+	if n, err := r.ReadAt(data, section.Offset); n < len(data) {
+		if err == nil || err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, &CorruptFileError{Location: location, Err: errors.New("section is truncated")}
+		}
+		return nil, err
+	}
+
+	checksum := sha256.Sum256(data)
+	if hex.EncodeToString(checksum[:]) != section.Checksum {
+		return nil, &CorruptFileError{Location: location, Err: errors.New("checksum mismatch")}
+	}
+
+	return data, nil
+
+}
+
+func readBundleRotationKeys(r io.ReaderAt, header BundleHeader, sectionType string) (*rlwe.RotationKeySet, error) {
+
+	params, err := header.Parameters.CKKSParameters()
+	if err != nil {
+		return nil, err
+	}
+
+	galEls := header.GaloisElements(sectionType)
+	rotKeys := rlwe.NewRotationKeySet(params.Parameters, galEls)
+
+	for _, galEl := range galEls {
+
+		section, _ := header.Section(sectionType, galEl)
+		data, err := ReadBundleSection(r, section)
+		if err != nil {
+			return nil, err
+		}
+
+		swk := &rlwe.SwitchingKey{}
+		if err = safeUnmarshal(swk, data); err != nil {
+			return nil, &CorruptFileError{Location: "bundle section " + sectionType, Err: err}
+		}
+
+		rotKeys.Keys[galEl] = swk
+
+	}
+
+	return rotKeys, nil
+
+}
+
+// Read selected keys from a bundle. Requesting a key that isn't in the bundle returns *MissingKeyError
+func ReadBundle(r io.ReaderAt, header BundleHeader, sk bool, pk bool, rlk bool, rotk bool) (KeyChain, error) {
+
+	keyChain := KeyChain{ParamsIndex: header.ParamsIndex, Params: header.Parameters}
+
+	toLoad := []bool{sk, pk, rlk}
+	sectionTypes := []string{SecretKeySection, PublicKeySection, RelinKeySection}
+
+	for i := range toLoad {
+
+		if !toLoad[i] {
+			continue
+		}
+
+		section, ok := header.Section(sectionTypes[i], 0)
+		if !ok {
+			return KeyChain{}, &MissingKeyError{Key: sectionTypes[i], Location: "bundle"}
+		}
+
+		data, err := ReadBundleSection(r, section)
+		if err != nil {
+			return KeyChain{}, err
+		}
+
+		switch i {
+		case 0:
+			keyChain.SecretKey = &rlwe.SecretKey{}
+			err = safeUnmarshal(keyChain.SecretKey, data)
+		case 1:
+			keyChain.PublicKey = &rlwe.PublicKey{}
+			err = safeUnmarshal(keyChain.PublicKey, data)
+		case 2:
+			keyChain.RelinKey = &rlwe.RelinearizationKey{}
+			err = safeUnmarshal(keyChain.RelinKey, data)
+		}
+
+		if err != nil {
+			return KeyChain{}, &CorruptFileError{Location: "bundle section " + sectionTypes[i], Err: err}
+		}
+
+	}
+
+	if rotk {
+
+		if !header.Has(RotationKeySection) && !header.Has(BootstrapRotationKeySection) {
+			return KeyChain{}, &MissingKeyError{Key: "rotation keys", Location: "bundle"}
+		}
+
+		var err error
+
+		if header.Has(RotationKeySection) {
+			if keyChain.GaloisKey, err = readBundleRotationKeys(r, header, RotationKeySection); err != nil {
+				return KeyChain{}, err
+			}
+		}
+
+		if header.Has(BootstrapRotationKeySection) {
+			if keyChain.BtspGalKey, err = readBundleRotationKeys(r, header, BootstrapRotationKeySection); err != nil {
+				return KeyChain{}, err
+			}
+		}
+
+	}
+
+	return keyChain, nil
+
+}
+
+// Load every key present in a bundle file
+func LoadBundle(filePath string) KeyChain {
+
+	keyChain, err := TryLoadBundle(filePath)
+	check(err)
+
+	return keyChain
+
+}
+
+// Error returning variant of LoadBundle. Returns *MissingKeyError when the file doesn't exist and *CorruptFileError
+// when it isn't a valid bundle
+func TryLoadBundle(filePath string) (KeyChain, error) {
+
+	f, err := os.Open(filePath)
+	if os.IsNotExist(err) {
+		return KeyChain{}, &MissingKeyError{Key: filepath.Base(filePath), Location: filepath.Dir(filePath)}
+	} else if err != nil {
+		return KeyChain{}, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return KeyChain{}, err
+	}
+
+	header, err := ReadBundleHeader(f, info.Size())
+	if err != nil {
+		return KeyChain{}, err
+	}
+
+	return ReadBundle(f, header, header.Has(SecretKeySection), header.Has(PublicKeySection), header.Has(RelinKeySection), header.Has(RotationKeySection) || header.Has(BootstrapRotationKeySection))
+
+}
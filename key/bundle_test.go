@@ -0,0 +1,163 @@
+package key
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/tuneinsight/lattigo/v4/ckks"
+)
+
+func TestBundle(t *testing.T) {
+
+	keyChain := GenerateKeysWithParameters(NewParameters(ckks.PN12QP109, nil), false, false)
+	dir := t.TempDir()
+	bundlePath := path.Join(dir, BundleFileName)
+
+	keyChain.DumpBundle(bundlePath, true, true, true, true, false)
+
+	f, err := os.Open(bundlePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	info, _ := f.Stat()
+	header, err := ReadBundleHeader(f, info.Size())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if header.Version != BundleFormatVersion || !header.Parameters.Equals(keyChain.Params) {
+		t.Error("Bundle header wasn't written correctly")
+	}
+
+	if !header.Has(SecretKeySection) || !header.Has(RelinKeySection) || header.Has(BootstrapRotationKeySection) {
+		t.Error("Bundle inventory doesn't match the keys written")
+	}
+
+	if len(header.GaloisElements(RotationKeySection)) != len(keyChain.GaloisKey.Keys) {
+		t.Error("Bundle doesn't contain every rotation key")
+	}
+
+	loaded := LoadKeysWithParameters(dir, keyChain.Params, true, true, true, true)
+
+	if !loaded.PublicKey.Equals(keyChain.PublicKey) || !loaded.RelinKey.Equals(keyChain.RelinKey) || !loaded.GaloisKey.Equals(keyChain.GaloisKey) {
+		t.Error("Keys loaded from bundle doesn't match original keys")
+	}
+
+	// Parameters and keys are taken from the bundle header instead of the index and flags
+	inventory, err := TryLoadKeys(dir, 3, false, false, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !inventory.GetParameters().Equals(keyChain.Params) || inventory.SecretKey == nil || !inventory.PublicKey.Equals(keyChain.PublicKey) || !inventory.GaloisKey.Equals(keyChain.GaloisKey) {
+		t.Error("Keys loaded from bundle directory should follow the bundle header")
+	}
+
+}
+
+func TestBundleCorruption(t *testing.T) {
+
+	keyChain := GenerateKeyPairWithParameters(NewParameters(ckks.PN12QP109, nil))
+	bundlePath := path.Join(t.TempDir(), BundleFileName)
+
+	keyChain.DumpBundle(bundlePath, true, true, false, false, false)
+
+	data, _ := os.ReadFile(bundlePath)
+	data[bundlePreludeSize+10] ^= 0xff
+	os.WriteFile(bundlePath, data, 0644)
+
+	f, _ := os.Open(bundlePath)
+	defer f.Close()
+
+	header, err := ReadBundleHeader(f, int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = ReadBundle(f, header, true, false, false, false); err == nil {
+		t.Error("Corrupted section wasn't detected")
+	}
+
+	if _, err = ReadBundle(f, header, false, false, true, false); err == nil {
+		t.Error("Missing relinearization key wasn't reported")
+	}
+
+	var missing *MissingKeyError
+	if _, err = TryLoadBundle(path.Join(t.TempDir(), BundleFileName)); !errors.As(err, &missing) {
+		t.Errorf("Loading bundle that doesn't exist should return MissingKeyError but got %v", err)
+	}
+
+}
+
+func TestBundleMalformed(t *testing.T) {
+
+	keyChain := GenerateKeyPairWithParameters(NewParameters(ckks.PN12QP109, nil))
+
+	// Bundle written after other data has offsets relative to its own start
+	f, err := os.Create(path.Join(t.TempDir(), BundleFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	f.Write([]byte("leading data"))
+	if err = keyChain.WriteBundle(f, true, true, false, false, false); err != nil {
+		t.Fatal(err)
+	}
+
+	info, _ := f.Stat()
+	bundle := io.NewSectionReader(f, 12, info.Size()-12)
+
+	header, err := ReadBundleHeader(bundle, bundle.Size())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = ReadBundle(bundle, header, true, true, false, false); err != nil {
+		t.Errorf("Bundle written at an offset couldn't be read: %v", err)
+	}
+
+	data := make([]byte, bundle.Size())
+	bundle.ReadAt(data, 0)
+
+	var corrupt *CorruptFileError
+
+	if _, err = ReadBundleHeader(bytes.NewReader(data[:10]), 10); !errors.As(err, &corrupt) {
+		t.Errorf("Truncated prelude should return CorruptFileError but got %v", err)
+	}
+
+	versionZero := append([]byte{}, data...)
+	binary.BigEndian.PutUint32(versionZero[8:], 0)
+	if _, err = ReadBundleHeader(bytes.NewReader(versionZero), int64(len(versionZero))); !errors.As(err, &corrupt) {
+		t.Errorf("Version 0 should return CorruptFileError but got %v", err)
+	}
+
+	// Section lengths from the header are checked before they are allocated
+	section := header.Sections[0]
+	for _, invalid := range []BundleSection{
+		{Type: section.Type, Offset: section.Offset, Length: -1},
+		{Type: section.Type, Offset: 0, Length: section.Length},
+		{Type: section.Type, Offset: section.Offset, Length: int64(len(data))},
+	} {
+		if _, err = ReadBundleSection(bytes.NewReader(data), invalid); !errors.As(err, &corrupt) {
+			t.Errorf("Section %+v should return CorruptFileError but got %v", invalid, err)
+		}
+	}
+
+	header.Sections[0].Length = 1 << 40
+	headerBytes, _ := json.Marshal(header)
+	headerOffset := binary.BigEndian.Uint64(data[12:])
+	oversized := append(append([]byte{}, data[:headerOffset]...), headerBytes...)
+	if _, err = ReadBundleHeader(bytes.NewReader(oversized), int64(len(oversized))); !errors.As(err, &corrupt) {
+		t.Errorf("Section longer than the file should return CorruptFileError but got %v", err)
+	}
+
+}
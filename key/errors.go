@@ -0,0 +1,36 @@
+package key
+
+import "fmt"
+
+// MissingKeyError is returned when a requested key isn't present at the given location
+type MissingKeyError struct {
+	Key      string
+	Location string
+}
+
+func (e *MissingKeyError) Error() string {
+	return fmt.Sprintf("%s is missing from '%s'", e.Key, e.Location)
+}
+
+// ParameterMismatchError is returned when keys were generated with different parameters than the ones requested
+type ParameterMismatchError struct {
+	Location string
+}
+
+func (e *ParameterMismatchError) Error() string {
+	return fmt.Sprintf("keys in '%s' were generated with different parameters", e.Location)
+}
+
+// CorruptFileError is returned when a file exists but its content can't be decoded
+type CorruptFileError struct {
+	Location string
+	Err      error
+}
+
+func (e *CorruptFileError) Error() string {
+	return fmt.Sprintf("'%s' is corrupted: %v", e.Location, e.Err)
+}
+
+func (e *CorruptFileError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,50 @@
+package key
+
+import (
+	"errors"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/tuneinsight/lattigo/v4/ckks"
+)
+
+func TestLoadKeysErrors(t *testing.T) {
+
+	paramSet := NewParameters(ckks.PN12QP109, nil)
+	keyChain := GenerateKeyPairWithParameters(paramSet)
+	dirName := path.Join(t.TempDir(), "keys")
+
+	if err := keyChain.TryDumpKeys(dirName, true, true, false, false, false); err != nil {
+		t.Fatal(err)
+	}
+
+	var missingErr *MissingKeyError
+	if _, err := TryLoadKeysWithParameters(dirName, paramSet, true, true, true, false); !errors.As(err, &missingErr) {
+		t.Errorf("Loading missing relin key should return MissingKeyError but got %v", err)
+	}
+
+	var mismatchErr *ParameterMismatchError
+	if _, err := TryLoadKeys(dirName, 3, true, true, false, false); !errors.As(err, &mismatchErr) {
+		t.Errorf("Loading keys with different parameters should return ParameterMismatchError but got %v", err)
+	}
+
+	if err := os.WriteFile(path.Join(dirName, "secret_key"), []byte{1, 2, 3}, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var corruptErr *CorruptFileError
+	if _, err := TryLoadKeysWithParameters(dirName, paramSet, true, false, false, false); !errors.As(err, &corruptErr) {
+		t.Errorf("Loading corrupted secret key should return CorruptFileError but got %v", err)
+	}
+
+	loaded, err := TryLoadKeysWithParameters(dirName, paramSet, false, true, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !loaded.PublicKey.Equals(keyChain.PublicKey) {
+		t.Error("Loaded public key doesn't match original public key")
+	}
+
+}
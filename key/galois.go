@@ -1,11 +1,11 @@
 package key
 
 import (
-	"context"
 	"encoding/binary"
+	"errors"
 	"fmt"
-	"io/ioutil"
 	"os"
+	"sort"
 
 	"bufio"
 	"unsafe"
@@ -149,37 +149,84 @@ func MarshalBinary(rtks *rlwe.RotationKeySet) (data []byte, err error) {
 // 	return nil
 // }
 
-func UnmarshalGaloisFromS3(rtks *rlwe.RotationKeySet, keyS3key string, s3Client *s3.Client, bucketName string) (err error) {
+// Encode rotation keys in the format read by UnmarshalGaloisFromStore
+// [ galois element (uint32) | switching key ] repeated for every key in ascending galois element order
+func EncodeGaloisKeys(rtks *rlwe.RotationKeySet) ([]byte, error) {
+
+	galEls := make([]uint64, 0, len(rtks.Keys))
+	size := 0
+
+	for galEl, swk := range rtks.Keys {
+		galEls = append(galEls, galEl)
+		size += 4 + 2
+		for i := range swk.Value {
+			for j := range swk.Value[i] {
+				size += swk.Value[i][j].MarshalBinarySize()
+			}
+		}
+	}
+
+	sort.Slice(galEls, func(i, j int) bool { return galEls[i] < galEls[j] })
+
+	data := make([]byte, size)
+	pointer := 0
+
+	for _, galEl := range galEls {
+
+		binary.BigEndian.PutUint32(data[pointer:], uint32(galEl))
+		pointer += 4
+
+		var err error
+		if pointer, err = EncodeSwitchingKey(rtks.Keys[galEl], pointer, data); err != nil {
+			return nil, err
+		}
 
-	headParams := &s3.HeadObjectInput{
-		Bucket: &bucketName,
-		Key: &keyS3key,
 	}
 
-	headObject, s3HeadErr := s3Client.HeadObject(context.TODO(), headParams)
-	if s3HeadErr != nil {
-		return s3HeadErr
+	return data, nil
+
+}
+
+// Chunk size used when range reading encoded galois keys
+const galoisChunkSize = 1073741824
+
+// Decode rotation keys encoded by EncodeGaloisKeys from an object in store. The object is range read in 1GiB chunks
+func UnmarshalGaloisFromStore(rtks *rlwe.RotationKeySet, store KeyStore, name string) (err error) {
+
+	fileLen, err := store.Size(name)
+	if err != nil {
+		return err
 	}
 
-	fileLen := headObject.ContentLength
+	readChunk := func(start int64) ([]byte, error) {
+		length := int64(galoisChunkSize)
+		if start+length > fileLen {
+			length = fileLen - start
+		}
+		return store.ReadRange(name, start, length)
+	}
 
-	data, reqByteErr := requestBytesFromS3(s3Client, bucketName, keyS3key, 0, 1073741824, int(fileLen))
-	if reqByteErr != nil {
-		return reqByteErr
+	data, err := readChunk(0)
+	if err != nil {
+		return err
 	}
 
 	keyLen := 0
-	pointer := 0
+	pointer := int64(0)
 
 	for len(data) > 0 {
 
+		if len(data) < 4 {
+			return &CorruptFileError{Location: name, Err: errors.New("truncated galois element")}
+		}
+
 		galEl := uint64(binary.BigEndian.Uint32(data))
 
 		data = data[4:]
 		swk := new(rlwe.SwitchingKey)
 		var inc int
-		if inc, err = DecodeSwitchingKey(swk, data); err != nil {
-			return err
+		if inc, err = safeDecodeSwitchingKey(swk, data); err != nil {
+			return &CorruptFileError{Location: name, Err: err}
 		}
 
 		if keyLen == 0 {
@@ -188,12 +235,11 @@ func UnmarshalGaloisFromS3(rtks *rlwe.RotationKeySet, keyS3key string, s3Client
 
 		data = data[inc:]
 		rtks.Keys[galEl] = swk
-		pointer += 4 + inc
+		pointer += int64(4 + inc)
 
-		if len(data) < keyLen {
-			data, reqByteErr = requestBytesFromS3(s3Client, bucketName, keyS3key, pointer, 1073741824, int(fileLen))
-			if reqByteErr != nil {
-				return reqByteErr
+		if len(data) < keyLen && pointer < fileLen {
+			if data, err = readChunk(pointer); err != nil {
+				return err
 			}
 		}
 	}
@@ -201,39 +247,26 @@ func UnmarshalGaloisFromS3(rtks *rlwe.RotationKeySet, keyS3key string, s3Client
 	return nil
 }
 
+// Decode switching key, recovering from panics raised by lattigo on truncated data
+func safeDecodeSwitchingKey(swk *rlwe.SwitchingKey, data []byte) (pointer int, err error) {
 
-func requestBytesFromS3(s3Client *s3.Client, bucketName string, key string, start int, getLen int, fileLength int) ([]byte, error) {
-
-	var byteRange string
-
-	if start + 1073741824 < fileLength{
-		byteRange = fmt.Sprintf("%d-%d", start, start + getLen)
-	} else {
-		byteRange = fmt.Sprintf("%d-%d", start, fileLength)
-	}
-
-	getParams := &s3.GetObjectInput{
-		Bucket: &bucketName,
-		Key: &key,
-		Range: &byteRange,
-	}
-
-	s3Object, err:= s3Client.GetObject(context.TODO(), getParams)
-	if err != nil {
-		return nil, err
-	}
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("invalid switching key data: %v", r)
+		}
+	}()
 
-	data, readErr := ioutil.ReadAll(s3Object.Body)
-	if readErr != nil {
-		return nil, readErr
+	if len(data) < 2 {
+		return 0, errors.New("truncated switching key")
 	}
 
-	s3Object.Body.Close()
-
-	return data, nil
+	return DecodeSwitchingKey(swk, data)
 
 }
 
+func UnmarshalGaloisFromS3(rtks *rlwe.RotationKeySet, keyS3key string, s3Client *s3.Client, bucketName string) (err error) {
+	return UnmarshalGaloisFromStore(rtks, NewS3KeyStore(s3Client, bucketName, ""), keyS3key)
+}
 
 func ReadFromDesinatedPointer(pointer int, size int, keyFile *os.File) []byte {
 
@@ -1,7 +1,9 @@
 package key
 
 import (
-	"io/ioutil"
+	"encoding"
+	"errors"
+	"fmt"
 	"math"
 	"os"
 	"path"
@@ -10,18 +12,33 @@ import (
 	"strings"
 
 	"github.com/tuneinsight/lattigo/v4/ckks"
-	"github.com/tuneinsight/lattigo/v4/ckks/bootstrapping"
 	"github.com/tuneinsight/lattigo/v4/rlwe"
 	"github.com/perm-ai/go-cerebrum/logger"
 )
 
+// ParamsIndex is the index in bootstrapping.DefaultParametersSparse the keys were generated with,
+// or -1 when Params describe a custom parameter set.
+// RotationKeyLoader can be set instead of GaloisKey to load rotation keys on first use.
 type KeyChain struct {
-	ParamsIndex int
-	SecretKey   *rlwe.SecretKey
-	PublicKey   *rlwe.PublicKey
-	RelinKey    *rlwe.RelinearizationKey
-	GaloisKey   *rlwe.RotationKeySet
-	BtspGalKey  *rlwe.RotationKeySet
+	ParamsIndex       int
+	Params            Parameters
+	SecretKey         *rlwe.SecretKey
+	PublicKey         *rlwe.PublicKey
+	RelinKey          *rlwe.RelinearizationKey
+	GaloisKey         *rlwe.RotationKeySet
+	BtspGalKey        *rlwe.RotationKeySet
+	RotationKeyLoader *RotationKeyLoader
+}
+
+// Get parameter descriptor of the keychain. Fall back to default parameters at ParamsIndex when no descriptor is set
+func (k KeyChain) GetParameters() Parameters {
+
+	if k.Params.IsEmpty() {
+		return DefaultParameters(k.ParamsIndex)
+	}
+
+	return k.Params
+
 }
 
 func GetPow2K(logSlots int) []int {
@@ -42,30 +59,95 @@ func GetPow2K(logSlots int) []int {
 
 func GenerateKeys(paramsIndex int, btspEnabled bool, logEnabled bool) KeyChain {
 
+	keyChain := GenerateKeysWithParameters(DefaultParameters(paramsIndex), btspEnabled, logEnabled)
+	keyChain.ParamsIndex = paramsIndex
+
+	return keyChain
+
+}
+
+func GenerateKeysWithParameters(paramSet Parameters, btspEnabled bool, logEnabled bool) KeyChain {
+
 	log := logger.NewLogger(logEnabled)
 
-	paramSet := bootstrapping.DefaultParametersSparse[paramsIndex]
-	ckksParams := paramSet.SchemeParams
+	Params := mustCKKSParameters(paramSet)
 
-	Params, _ := ckks.NewParametersFromLiteral(ckksParams)
-	
 	log.Log("Util Initialization: Generating key generator")
 	keyGenerator := ckks.NewKeyGenerator(Params)
 
 	log.Log("Util Initialization: Generating private / public key pair")
 	secretKey, publicKey := keyGenerator.GenKeyPair()
 
-	return GenerateKeysFromKeyPair(paramsIndex, secretKey, publicKey, btspEnabled, logEnabled)
+	return GenerateKeysFromKeyPairWithParameters(paramSet, secretKey, publicKey, btspEnabled, logEnabled)
 
 }
 
 func GenerateKeysFromKeyPair(paramsIndex int, sk *rlwe.SecretKey, pk *rlwe.PublicKey, btspEnabled bool, logEnabled bool) KeyChain {
 
+	keyChain := GenerateKeysFromKeyPairWithParameters(DefaultParameters(paramsIndex), sk, pk, btspEnabled, logEnabled)
+	keyChain.ParamsIndex = paramsIndex
+
+	return keyChain
+
+}
+
+// Generate relin key, galois keys for every power of 2 rotation and optionally bootstrapping rotation keys of sk.
+// pk is generated when nil. Panics when btspEnabled is set but paramSet has no bootstrapping parameters
+func GenerateKeysFromKeyPairWithParameters(paramSet Parameters, sk *rlwe.SecretKey, pk *rlwe.PublicKey, btspEnabled bool, logEnabled bool) KeyChain {
+
+	return generateKeyChain(paramSet, sk, pk, btspEnabled, logEnabled, func(Params ckks.Parameters, keyGenerator rlwe.KeyGenerator, log logger.Logger) *rlwe.RotationKeySet {
+
+		log.Log("Util Initialization: Generating galois keys")
+
+		return keyGenerator.GenRotationKeysForRotations(GetPow2K(Params.LogSlots()), true, sk)
+
+	})
+
+}
+
+// Generate keychain with galois keys for the given rotations only (eg. from utility.RotationPlanner) instead of every power of 2 rotation
+func GenerateKeysForRotations(paramsIndex int, sk *rlwe.SecretKey, pk *rlwe.PublicKey, rotations []int, btspEnabled bool, logEnabled bool) KeyChain {
+
+	keyChain := GenerateKeysForRotationsWithParameters(DefaultParameters(paramsIndex), sk, pk, rotations, btspEnabled, logEnabled)
+	keyChain.ParamsIndex = paramsIndex
+
+	return keyChain
+
+}
+
+// Generate keychain like GenerateKeysFromKeyPairWithParameters with galois keys for the given rotations only.
+// Panics when btspEnabled is set but paramSet has no bootstrapping parameters
+func GenerateKeysForRotationsWithParameters(paramSet Parameters, sk *rlwe.SecretKey, pk *rlwe.PublicKey, rotations []int, btspEnabled bool, logEnabled bool) KeyChain {
+
+	return generateKeyChain(paramSet, sk, pk, btspEnabled, logEnabled, func(Params ckks.Parameters, keyGenerator rlwe.KeyGenerator, log logger.Logger) *rlwe.RotationKeySet {
+
+		log.Log("Util Initialization: Generating " + strconv.Itoa(len(rotations)) + " galois keys")
+		customKeyGenerator := NewKeyGenerator(Params, keyGenerator)
+		galEls := customKeyGenerator.GetGalEl(rotations, false)
+		galoisKey := rlwe.NewRotationKeySet(Params.Parameters, []uint64{})
+
+		customKeyGenerator.GenRotationKeys(galEls, sk, func(galEl uint64, swk *rlwe.SwitchingKey) error {
+			galoisKey.Keys[galEl] = swk
+			return nil
+		})
+
+		return galoisKey
+
+	})
+
+}
+
+// Generate keychain of sk with relin key, galois keys from generateGalois and bootstrapping rotation keys when btspEnabled.
+// Panics when btspEnabled is set but paramSet has no bootstrapping parameters
+func generateKeyChain(paramSet Parameters, sk *rlwe.SecretKey, pk *rlwe.PublicKey, btspEnabled bool, logEnabled bool, generateGalois func(Params ckks.Parameters, keyGenerator rlwe.KeyGenerator, log logger.Logger) *rlwe.RotationKeySet) KeyChain {
+
 	log := logger.NewLogger(logEnabled)
 
-	paramSet := bootstrapping.DefaultParametersSparse[paramsIndex]
-	bootstrappingParams := paramSet.BootstrappingParams
-	Params, _ := ckks.NewParametersFromLiteral(paramSet.SchemeParams)
+	if btspEnabled && !paramSet.HasBootstrapping() {
+		panic("Parameters doesn't contain bootstrapping parameters")
+	}
+
+	Params := mustCKKSParameters(paramSet)
 
 	log.Log("Util Initialization: Generating key generator")
 	keyGenerator := ckks.NewKeyGenerator(Params)
@@ -79,37 +161,42 @@ func GenerateKeysFromKeyPair(paramsIndex int, sk *rlwe.SecretKey, pk *rlwe.Publi
 	log.Log("Util Initialization: Generating relin key")
 	relinKey := keyGenerator.GenRelinearizationKey(sk, 2)
 
-	log.Log("Util Initialization: Generating galois keys")
-	galoisKey := keyGenerator.GenRotationKeysForRotations(GetPow2K(Params.LogSlots()), true, sk)
+	galoisKey := generateGalois(Params, keyGenerator, log)
 
 	var btpRotationKeys *rlwe.RotationKeySet
 
 	if btspEnabled {
-		rotations := bootstrappingParams.RotationsForBootstrapping(Params)
+		rotations := paramSet.BootstrappingParams.RotationsForBootstrapping(Params)
 		btpRotationKeys = keyGenerator.GenRotationKeysForRotations(rotations, true, sk)
 	}
 
-	return KeyChain{paramsIndex, sk, publicKey, relinKey, galoisKey, btpRotationKeys}
+	return KeyChain{ParamsIndex: -1, Params: paramSet, SecretKey: sk, PublicKey: publicKey, RelinKey: relinKey, GaloisKey: galoisKey, BtspGalKey: btpRotationKeys}
 
 }
 
 func GenerateRelinKey(paramsIndex int, sk *rlwe.SecretKey) *rlwe.RelinearizationKey {
+	return GenerateRelinKeyWithParameters(DefaultParameters(paramsIndex), sk)
+}
+
+func GenerateRelinKeyWithParameters(paramSet Parameters, sk *rlwe.SecretKey) *rlwe.RelinearizationKey {
 
-	paramSet := bootstrapping.DefaultParametersSparse[paramsIndex]
-	Params, _ := ckks.NewParametersFromLiteral(paramSet.SchemeParams)
+	Params := mustCKKSParameters(paramSet)
 	keyGenerator := ckks.NewKeyGenerator(Params)
 
 	return keyGenerator.GenRelinearizationKey(sk, 2)
 
 }
 
-func GenerateRotationKeys(paramsIndex int, sk *rlwe.SecretKey, galEl []uint64, concurrent bool, callback func(galEl uint64, swk *rlwe.SwitchingKey) error) []error{
+func GenerateRotationKeys(paramsIndex int, sk *rlwe.SecretKey, galEl []uint64, concurrent bool, callback func(galEl uint64, swk *rlwe.SwitchingKey) error) []error {
+	return GenerateRotationKeysWithParameters(DefaultParameters(paramsIndex), sk, galEl, concurrent, callback)
+}
+
+func GenerateRotationKeysWithParameters(paramSet Parameters, sk *rlwe.SecretKey, galEl []uint64, concurrent bool, callback func(galEl uint64, swk *rlwe.SwitchingKey) error) []error {
 
-	paramSet := bootstrapping.DefaultParametersSparse[paramsIndex]
-	Params, _ := ckks.NewParametersFromLiteral(paramSet.SchemeParams)
+	Params := mustCKKSParameters(paramSet)
 	keyGenerator := NewKeyGenerator(Params, ckks.NewKeyGenerator(Params))
 
-	if concurrent{
+	if concurrent {
 		return keyGenerator.GenRotationKeysConcurrent(galEl, sk, callback)
 	} else {
 		return keyGenerator.GenRotationKeys(galEl, sk, callback)
@@ -119,28 +206,133 @@ func GenerateRotationKeys(paramsIndex int, sk *rlwe.SecretKey, galEl []uint64, c
 
 func GenerateKeyPair(paramsIndex int) KeyChain {
 
-	paramSet := bootstrapping.DefaultParametersSparse[paramsIndex]
-	Params, _ := ckks.NewParametersFromLiteral(paramSet.SchemeParams)
+	keyChain := GenerateKeyPairWithParameters(DefaultParameters(paramsIndex))
+	keyChain.ParamsIndex = paramsIndex
+
+	return keyChain
+
+}
+
+func GenerateKeyPairWithParameters(paramSet Parameters) KeyChain {
+
+	Params := mustCKKSParameters(paramSet)
 
 	keyGenerator := ckks.NewKeyGenerator(Params)
 
 	secretKey, publicKey := keyGenerator.GenKeyPair()
 
-	return KeyChain{SecretKey: secretKey, PublicKey: publicKey}
+	return KeyChain{ParamsIndex: -1, Params: paramSet, SecretKey: secretKey, PublicKey: publicKey}
 
 }
 
+// Load keys saved in dirName. When dirName holds a bundle (BundleFileName), parameters and the keys to load are taken
+// from its header and paramsIndex and the flags are ignored. Otherwise keys selected by the flags are loaded with the
+// parameters at paramsIndex
 func LoadKeys(dirName string, paramsIndex int, sk bool, pk bool, rlk bool, rotk bool) KeyChain {
 
-	toLoad := [5]bool{sk, pk, rlk, rotk}
-	fileNames := [5]string{"secret_key", "public_key", "relin_key", "rotation_key_"}
+	keyChain, err := TryLoadKeys(dirName, paramsIndex, sk, pk, rlk, rotk)
+	check(err)
 
-	for i := range toLoad {
-		if toLoad[i] && !fileExist(dirName+"/"+fileNames[i]) {
-			panic("File '" + dirName + "/" + fileNames[i] + "' does not exist")
+	return keyChain
+
+}
+
+// Load parameters descriptor saved by DumpKeys
+func LoadParameters(dirName string) Parameters {
+
+	paramSet, err := TryLoadParameters(dirName)
+	check(err)
+
+	return paramSet
+
+}
+
+func TryLoadParameters(dirName string) (Parameters, error) {
+	return LoadParametersFromStore(NewLocalKeyStore(dirName))
+}
+
+// Load parameters descriptor saved by DumpKeysToStore
+func LoadParametersFromStore(store KeyStore) (Parameters, error) {
+
+	byteArr, err := ReadAll(store, "parameters")
+	if err != nil {
+		return Parameters{}, err
+	}
+
+	paramSet := Parameters{}
+	if err = paramSet.UnmarshalBinary(byteArr); err != nil {
+		return Parameters{}, &CorruptFileError{Location: "parameters", Err: err}
+	}
+
+	return paramSet, nil
+
+}
+
+func LoadKeysWithParameters(dirName string, paramSet Parameters, sk bool, pk bool, rlk bool, rotk bool) KeyChain {
+
+	keyChain, err := TryLoadKeysWithParameters(dirName, paramSet, sk, pk, rlk, rotk)
+	check(err)
+
+	return keyChain
+
+}
+
+// Error returning variant of LoadKeys
+func TryLoadKeys(dirName string, paramsIndex int, sk bool, pk bool, rlk bool, rotk bool) (KeyChain, error) {
+
+	// Bundle describes its own parameters and keys
+	hasBundle, err := Exists(NewLocalKeyStore(dirName), BundleFileName)
+	if err != nil {
+		return KeyChain{}, err
+	} else if hasBundle {
+		return TryLoadBundle(path.Join(dirName, BundleFileName))
+	}
+
+	keyChain, err := TryLoadKeysWithParameters(dirName, DefaultParameters(paramsIndex), sk, pk, rlk, rotk)
+	keyChain.ParamsIndex = paramsIndex
+
+	return keyChain, err
+
+}
+
+// Error returning variant of LoadKeysWithParameters. Returns *MissingKeyError, *ParameterMismatchError or *CorruptFileError
+func TryLoadKeysWithParameters(dirName string, paramSet Parameters, sk bool, pk bool, rlk bool, rotk bool) (KeyChain, error) {
+	return LoadKeysFromStore(NewLocalKeyStore(dirName), paramSet, sk, pk, rlk, rotk)
+}
+
+// Load keys saved in store either as a bundle (BundleFileName) or as one object per key
+// (secret_key, public_key, relin_key, rotation_key_<galois element>).
+// Returns *MissingKeyError, *ParameterMismatchError or *CorruptFileError
+func LoadKeysFromStore(store KeyStore, paramSet Parameters, sk bool, pk bool, rlk bool, rotk bool) (KeyChain, error) {
+
+	// Load from bundle if store contains one
+	hasBundle, err := Exists(store, BundleFileName)
+	if err != nil {
+		return KeyChain{}, err
+	} else if hasBundle {
+		return loadKeysFromStoreBundle(store, paramSet, sk, pk, rlk, rotk)
+	}
+
+	// Check saved parameters descriptor if there's one
+	hasParameters, err := Exists(store, "parameters")
+	if err != nil {
+		return KeyChain{}, err
+	} else if hasParameters {
+
+		savedParams, err := LoadParametersFromStore(store)
+		if err != nil {
+			return KeyChain{}, err
+		}
+
+		if !savedParams.Equals(paramSet) {
+			return KeyChain{}, &ParameterMismatchError{Location: "parameters"}
 		}
+
 	}
 
+	toLoad := [3]bool{sk, pk, rlk}
+	fileNames := [3]string{"secret_key", "public_key", "relin_key"}
+
 	var skey *rlwe.SecretKey
 	var pkey *rlwe.PublicKey
 	var rlkey *rlwe.RelinearizationKey
@@ -148,67 +340,214 @@ func LoadKeys(dirName string, paramsIndex int, sk bool, pk bool, rlk bool, rotk
 
 	for i := range toLoad {
 
-		if toLoad[i] && i < 3 {
+		if !toLoad[i] {
+			continue
+		}
 
-			var byteArr []byte
-			var err error
+		byteArr, err := ReadAll(store, fileNames[i])
+		if err != nil {
+			return KeyChain{}, err
+		}
 
-			byteArr, err = os.ReadFile(dirName + "/" + fileNames[i])
-			check(err)
+		switch i {
+		case 0:
+			skey = &rlwe.SecretKey{}
+			err = safeUnmarshal(skey, byteArr)
+		case 1:
+			pkey = &rlwe.PublicKey{}
+			err = safeUnmarshal(pkey, byteArr)
+		case 2:
+			rlkey = &rlwe.RelinearizationKey{}
+			err = safeUnmarshal(rlkey, byteArr)
+		}
 
-			switch i {
-			case 0:
-				skey = &rlwe.SecretKey{}
-				err = skey.UnmarshalBinary(byteArr)
-			case 1:
-				pkey = &rlwe.PublicKey{}
-				err = pkey.UnmarshalBinary(byteArr)
-			case 2:
-				rlkey = &rlwe.RelinearizationKey{}
-				err = rlkey.UnmarshalBinary(byteArr)
+		if err != nil {
+			return KeyChain{}, &CorruptFileError{Location: fileNames[i], Err: err}
+		}
+
+	}
+
+	if rotk {
+
+		source, err := newRotationKeySource(store)
+		if err != nil {
+			return KeyChain{}, err
+		}
+
+		galEls, err := source.galoisElements()
+		if err != nil {
+			return KeyChain{}, err
+		}
 
+		if len(galEls) == 0 {
+			return KeyChain{}, &MissingKeyError{Key: "rotation keys", Location: "store"}
+		}
+
+		Params, err := paramSet.CKKSParameters()
+		if err != nil {
+			return KeyChain{}, err
+		}
+
+		rotKeys = rlwe.NewRotationKeySet(Params.Parameters, []uint64{})
+
+		for _, galEl := range galEls {
+			if rotKeys.Keys[galEl], err = source.load(galEl); err != nil {
+				return KeyChain{}, err
 			}
-			check(err)
+		}
 
-		} else if toLoad[i] && i == 3 {
+	}
 
-			files, err := ioutil.ReadDir(dirName)
-			check(err)
+	return KeyChain{ParamsIndex: -1, Params: paramSet, SecretKey: skey, PublicKey: pkey, RelinKey: rlkey, GaloisKey: rotKeys, BtspGalKey: rotKeys}, nil
+
+}
+
+func rotationKeyName(galEl uint64) string {
+	return "rotation_key_" + strconv.FormatUint(galEl, 10)
+}
+
+// List galois elements of rotation keys saved in store in ascending order
+func ListRotationKeys(store KeyStore) ([]uint64, error) {
+
+	source, err := newRotationKeySource(store)
+	if err != nil {
+		return nil, err
+	}
+
+	return source.galoisElements()
+
+}
+
+// Load a single rotation key from store. Only the bytes of the requested key are read
+func LoadRotationKey(store KeyStore, galEl uint64) (*rlwe.SwitchingKey, error) {
+
+	source, err := newRotationKeySource(store)
+	if err != nil {
+		return nil, err
+	}
+
+	return source.load(galEl)
+
+}
+
+// rotationKeySource reads rotation keys from a store holding either a bundle or one object per key. Whether
+// the store holds a bundle and its header are only read once, so loading many keys doesn't request them again
+type rotationKeySource struct {
+	store  KeyStore
+	bundle *BundleHeader // nil when keys are stored as one object each
+	size   int64         // Size of the bundle
+}
+
+func newRotationKeySource(store KeyStore) (rotationKeySource, error) {
+
+	// Only a missing bundle means keys are stored as one object each, like Exists
+	size, err := store.Size(BundleFileName)
+
+	var missing *MissingKeyError
+	if errors.As(err, &missing) {
+		return rotationKeySource{store: store}, nil
+	} else if err != nil {
+		return rotationKeySource{}, err
+	}
+
+	header, err := ReadBundleHeader(storeReaderAt{store: store, name: BundleFileName, size: size}, size)
+	if err != nil {
+		return rotationKeySource{}, err
+	}
+
+	return rotationKeySource{store: store, bundle: &header, size: size}, nil
+
+}
+
+// Galois elements of every rotation key of the source in ascending order
+func (s rotationKeySource) galoisElements() ([]uint64, error) {
+
+	if s.bundle != nil {
+
+		galEls := append(s.bundle.GaloisElements(RotationKeySection), s.bundle.GaloisElements(BootstrapRotationKeySection)...)
+		sort.Slice(galEls, func(i, j int) bool { return galEls[i] < galEls[j] })
+
+		return galEls, nil
+
+	}
+
+	names, err := s.store.List("rotation_key_")
+	if err != nil {
+		return nil, err
+	}
+
+	galEls := []uint64{}
+
+	for _, name := range names {
+
+		galEl, err := strconv.ParseUint(strings.TrimPrefix(name, "rotation_key_"), 10, 64)
+		if err != nil {
+			return nil, &CorruptFileError{Location: name, Err: err}
+		}
+
+		galEls = append(galEls, galEl)
+
+	}
+
+	sort.Slice(galEls, func(i, j int) bool { return galEls[i] < galEls[j] })
 
-			galEls := []uint64{}
-			rotKeysMap := make(map[uint64]*rlwe.SwitchingKey)
+	return galEls, nil
 
-			for i := range files{
+}
 
-				if strings.Contains(files[i].Name(), fileNames[3]){
+// Load rotation key of galEl. Only the bytes of the requested key are read
+func (s rotationKeySource) load(galEl uint64) (*rlwe.SwitchingKey, error) {
 
-					keyNameSep := strings.Split(files[i].Name(), "_")
-					galEl, err := strconv.Atoi(keyNameSep[len(keyNameSep) - 1])
-					check(err)
-					rotK, err := os.ReadFile(path.Join(dirName, files[i].Name()))
-					check(err)
+	var data []byte
+	var err error
+	location := rotationKeyName(galEl)
 
-					swk := &rlwe.SwitchingKey{}
-					swk.UnmarshalBinary(rotK)
-					rotKeysMap[uint64(galEl)] = swk
-					galEls = append(galEls, uint64(galEl))
+	if s.bundle != nil {
 
-				}
-				
+		section, found := s.bundle.Section(RotationKeySection, galEl)
+		if !found {
+			if section, found = s.bundle.Section(BootstrapRotationKeySection, galEl); !found {
+				return nil, &MissingKeyError{Key: location, Location: BundleFileName}
 			}
+		}
 
-			paramSet := bootstrapping.DefaultParametersSparse[paramsIndex]
-			Params, _ := ckks.NewParametersFromLiteral(paramSet.SchemeParams)
-			rotKeys := rlwe.NewRotationKeySet(Params.Parameters, galEls)
-			
-			rotKeys.Keys = rotKeysMap
-			
-			check(err)
+		location = "bundle section " + section.Type
+		if data, err = ReadBundleSection(storeReaderAt{store: s.store, name: BundleFileName, size: s.size}, section); err != nil {
+			return nil, err
 		}
 
+	} else if data, err = ReadAll(s.store, location); err != nil {
+		return nil, err
+	}
+
+	swk := &rlwe.SwitchingKey{}
+	if err = safeUnmarshal(swk, data); err != nil {
+		return nil, &CorruptFileError{Location: location, Err: err}
+	}
+
+	return swk, nil
+
+}
+
+func loadKeysFromStoreBundle(store KeyStore, paramSet Parameters, sk bool, pk bool, rlk bool, rotk bool) (KeyChain, error) {
+
+	size, err := store.Size(BundleFileName)
+	if err != nil {
+		return KeyChain{}, err
+	}
+
+	reader := storeReaderAt{store: store, name: BundleFileName, size: size}
+
+	header, err := ReadBundleHeader(reader, size)
+	if err != nil {
+		return KeyChain{}, err
+	}
+
+	if !header.Parameters.Equals(paramSet) {
+		return KeyChain{}, &ParameterMismatchError{Location: BundleFileName}
 	}
 
-	return KeyChain{paramsIndex, skey, pkey, rlkey, rotKeys, rotKeys}
+	return ReadBundle(reader, header, sk, pk, rlk, rotk)
 
 }
 
@@ -249,38 +588,42 @@ func LoadKeyPairFromBytes(dirName string, paramsIndex int, sk *[]byte, pk *[]byt
 
 	}
 
-	return KeyChain{paramsIndex, skey, pkey, rlkey, galkey, btpRotKey}
+	return KeyChain{ParamsIndex: paramsIndex, SecretKey: skey, PublicKey: pkey, RelinKey: rlkey, GaloisKey: galkey, BtspGalKey: btpRotKey}
 
 }
 
 func (k KeyChain) DumpKeys(dirName string, sk bool, pk bool, rlk bool, galk bool, btpGalK bool) {
+	check(k.TryDumpKeys(dirName, sk, pk, rlk, galk, btpGalK))
+}
 
-	log := logger.NewLogger(true)
-	toSave := [5]bool{sk, pk, rlk, galk, btpGalK}
+// Error returning variant of DumpKeys
+func (k KeyChain) TryDumpKeys(dirName string, sk bool, pk bool, rlk bool, galk bool, btpGalK bool) error {
+	return k.DumpKeysToStore(NewLocalKeyStore(dirName), sk, pk, rlk, galk, btpGalK)
+}
 
-	if !fileExist(dirName) {
-		e := os.Mkdir(dirName, 0777)
-		check(e)
-	}
+// Save selected keys and the parameters descriptor as one object per key in store
+func (k KeyChain) DumpKeysToStore(store KeyStore, sk bool, pk bool, rlk bool, galk bool, btpGalK bool) error {
 
-	if sk && k.SecretKey == nil {
-		panic("Keychain doesn't have secret keys")
-	}
+	log := logger.NewLogger(true)
+	toSave := [5]bool{sk, pk, rlk, galk, btpGalK}
 
-	if pk && k.PublicKey == nil {
-		panic("Keychain doesn't have public keys")
-	}
+	present := [5]bool{k.SecretKey != nil, k.PublicKey != nil, k.RelinKey != nil, k.GaloisKey != nil, k.BtspGalKey != nil}
+	keyNames := [5]string{"secret key", "public key", "relinearlize key", "galois keys", "bootstrapping galois keys"}
 
-	if rlk && k.RelinKey == nil {
-		panic("Keychain doesn't have relinearlize keys")
+	for i := range toSave {
+		if toSave[i] && !present[i] {
+			return &MissingKeyError{Key: keyNames[i], Location: "keychain"}
+		}
 	}
 
-	if galk && k.GaloisKey == nil {
-		panic("Keychain doesn't have galois keys")
+	// Save parameters descriptor so keys can be loaded with the parameters they were generated with
+	paramsByte, err := k.GetParameters().MarshalBinary()
+	if err != nil {
+		return err
 	}
 
-	if btpGalK && k.BtspGalKey == nil {
-		panic("Keychain doesn't have bootstrapping galois keys")
+	if err = store.Write("parameters", paramsByte); err != nil {
+		return err
 	}
 
 	for i := range toSave {
@@ -313,19 +656,50 @@ func (k KeyChain) DumpKeys(dirName string, sk bool, pk bool, rlk bool, galk bool
 				byteArr, byteErr = k.BtspGalKey.MarshalBinary()
 			}
 
-			check(byteErr)
-
-			f, e := os.Create(dirName + "/" + name)
-			check(e)
+			if byteErr != nil {
+				return byteErr
+			}
 
 			log.Log("Saving " + name)
-			_, e = f.Write(byteArr)
-			check(e)
+			if e := store.Write(name, byteArr); e != nil {
+				return e
+			}
+
+		}
+
+	}
+
+	return nil
+
+}
+
+// Save every rotation key (galois and bootstrapping) as its own object (rotation_key_<galois element>)
+// so that LoadKeysFromStore and LoadRotationKey can read them individually
+func (k KeyChain) DumpRotationKeysToStore(store KeyStore) error {
+
+	for _, rotKeys := range []*rlwe.RotationKeySet{k.GaloisKey, k.BtspGalKey} {
+
+		if rotKeys == nil {
+			continue
+		}
+
+		for galEl, swk := range rotKeys.Keys {
+
+			data, err := swk.MarshalBinary()
+			if err != nil {
+				return err
+			}
+
+			if err = store.Write(rotationKeyName(galEl), data); err != nil {
+				return err
+			}
 
 		}
 
 	}
 
+	return nil
+
 }
 
 func fileExist(dirName string) bool {
@@ -335,6 +709,19 @@ func fileExist(dirName string) bool {
 	return true
 }
 
+// Unmarshal key data, recovering from panics raised by lattigo on truncated data
+func safeUnmarshal(k encoding.BinaryUnmarshaler, data []byte) (err error) {
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("invalid key data: %v", r)
+		}
+	}()
+
+	return k.UnmarshalBinary(data)
+
+}
+
 func check(err error) {
 	if err != nil {
 		panic(err)
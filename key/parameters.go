@@ -0,0 +1,99 @@
+package key
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+
+	"github.com/tuneinsight/lattigo/v4/ckks"
+	"github.com/tuneinsight/lattigo/v4/ckks/bootstrapping"
+)
+
+// Parameters describe the CKKS scheme used by a KeyChain. BootstrappingParams is optional
+// and only required when bootstrapping keys are generated or a bootstrapper is created.
+type Parameters struct {
+	SchemeParams        ckks.ParametersLiteral
+	BootstrappingParams *bootstrapping.Parameters
+}
+
+// Create parameter descriptor from a ckks parameters literal and an optional bootstrapping parameters (can be nil)
+func NewParameters(schemeParams ckks.ParametersLiteral, bootstrappingParams *bootstrapping.Parameters) Parameters {
+	return Parameters{SchemeParams: schemeParams, BootstrappingParams: bootstrappingParams}
+}
+
+// Get parameter descriptor of bootstrapping.DefaultParametersSparse[paramsIndex]
+func DefaultParameters(paramsIndex int) Parameters {
+
+	paramSet := bootstrapping.DefaultParametersSparse[paramsIndex]
+	bootstrappingParams := paramSet.BootstrappingParams
+
+	return Parameters{SchemeParams: paramSet.SchemeParams, BootstrappingParams: &bootstrappingParams}
+
+}
+
+// Generate ckks parameters from the scheme parameters literal
+func (p Parameters) CKKSParameters() (ckks.Parameters, error) {
+	return ckks.NewParametersFromLiteral(p.SchemeParams)
+}
+
+func (p Parameters) HasBootstrapping() bool {
+	return p.BootstrappingParams != nil
+}
+
+func (p Parameters) IsEmpty() bool {
+	return p.SchemeParams.LogN == 0
+}
+
+// Check if both descriptors describe the same scheme and bootstrapping parameters
+func (p Parameters) Equals(other Parameters) bool {
+
+	params, err := p.CKKSParameters()
+	if err != nil {
+		return false
+	}
+
+	otherParams, err := other.CKKSParameters()
+	if err != nil || !params.Equals(otherParams) {
+		return false
+	}
+
+	if p.HasBootstrapping() != other.HasBootstrapping() {
+		return false
+	}
+
+	if p.HasBootstrapping() {
+		btpBytes, _ := json.Marshal(p.BootstrappingParams)
+		otherBtpBytes, _ := json.Marshal(other.BootstrappingParams)
+		return bytes.Equal(btpBytes, otherBtpBytes)
+	}
+
+	return true
+
+}
+
+func (p Parameters) MarshalBinary() ([]byte, error) {
+	return json.Marshal(p)
+}
+
+func (p *Parameters) UnmarshalBinary(data []byte) error {
+
+	if err := json.Unmarshal(data, p); err != nil {
+		return err
+	}
+
+	if p.IsEmpty() {
+		return errors.New("parameters descriptor doesn't contain scheme parameters")
+	}
+
+	return nil
+
+}
+
+func mustCKKSParameters(p Parameters) ckks.Parameters {
+
+	params, err := p.CKKSParameters()
+	check(err)
+
+	return params
+
+}
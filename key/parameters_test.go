@@ -0,0 +1,75 @@
+package key
+
+import (
+	"testing"
+
+	"github.com/tuneinsight/lattigo/v4/ckks"
+)
+
+func TestParametersMarshalling(t *testing.T) {
+
+	paramSet := DefaultParameters(3)
+
+	data, err := paramSet.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loaded := Parameters{}
+	if err = loaded.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+
+	if !loaded.HasBootstrapping() {
+		t.Error("Bootstrapping parameters wasn't unmarshalled")
+	}
+
+	original, _ := paramSet.CKKSParameters()
+	params, err := loaded.CKKSParameters()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !original.Equals(params) {
+		t.Error("Unmarshalled scheme parameters doesn't match original parameters")
+	}
+
+}
+
+func TestCustomParametersWithoutBootstrapping(t *testing.T) {
+
+	paramSet := NewParameters(ckks.PN12QP109, nil)
+	keyChain := GenerateKeyPairWithParameters(paramSet)
+
+	if keyChain.ParamsIndex != -1 {
+		t.Error("Keychain generated from custom parameters should have params index -1")
+	}
+
+	if keyChain.GetParameters().SchemeParams.LogN != 12 {
+		t.Error("Keychain doesn't carry custom parameters")
+	}
+
+	if keyChain.GetParameters().HasBootstrapping() {
+		t.Error("Custom parameters shouldn't have bootstrapping parameters")
+	}
+
+	if (KeyChain{ParamsIndex: 3}).GetParameters().SchemeParams.LogN != 15 {
+		t.Error("Keychain without descriptor should fall back to default parameters at params index")
+	}
+
+	// Both generators share the key generation and refuse to generate bootstrapping keys without their parameters
+	for name, generate := range map[string]func(){
+		"all rotations":   func() { GenerateKeysFromKeyPairWithParameters(paramSet, keyChain.SecretKey, nil, true, false) },
+		"given rotations": func() { GenerateKeysForRotationsWithParameters(paramSet, keyChain.SecretKey, nil, []int{1}, true, false) },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Generating bootstrapping keys for %s without bootstrapping parameters should panic", name)
+				}
+			}()
+			generate()
+		}()
+	}
+
+}
@@ -0,0 +1,180 @@
+package key
+
+import (
+	"container/list"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/tuneinsight/lattigo/v4/rlwe"
+)
+
+//=================================================
+//			  LAZY ROTATION KEY LOADER
+//=================================================
+
+// RotationKeyLoader fetches rotation keys on first use through a SwitchingKeyLoader and keeps
+// at most Capacity of them resident, evicting the least recently used key first.
+// A loader is safe to share between goroutines and Utils copies.
+type RotationKeyLoader struct {
+	Capacity int // Maximum amount of resident keys, 0 or less for unbounded
+
+	lock     *sync.Mutex
+	loader   func(galEl uint64) (*rlwe.SwitchingKey, error)
+	galEls   map[uint64]bool
+	resident map[uint64]*list.Element
+	order    *list.List // Most recently used key at the front
+	loaded   int
+}
+
+type residentKey struct {
+	galEl uint64
+	swk   *rlwe.SwitchingKey
+}
+
+// Create loader for keys of galEls. loader is called whenever a key that isn't resident is requested and must return nil if the key can't be loaded
+func NewRotationKeyLoader(galEls []uint64, loader SwitchingKeyLoader, capacity int) *RotationKeyLoader {
+
+	return newRotationKeyLoader(galEls, func(galEl uint64) (*rlwe.SwitchingKey, error) {
+		if swk := loader(galEl); swk != nil {
+			return swk, nil
+		}
+		return nil, &MissingKeyError{Key: "rotation key for galois element " + strconv.FormatUint(galEl, 10), Location: "rotation key loader"}
+	}, capacity)
+
+}
+
+func newRotationKeyLoader(galEls []uint64, loader func(galEl uint64) (*rlwe.SwitchingKey, error), capacity int) *RotationKeyLoader {
+
+	available := make(map[uint64]bool, len(galEls))
+	for _, galEl := range galEls {
+		available[galEl] = true
+	}
+
+	return &RotationKeyLoader{
+		Capacity: capacity,
+		lock:     &sync.Mutex{},
+		loader:   loader,
+		galEls:   available,
+		resident: make(map[uint64]*list.Element),
+		order:    list.New(),
+	}
+
+}
+
+// Create loader that reads rotation keys from store like LoadRotationKey. The store is checked here: the bundle
+// header and the range of every section are read and validated once, or the names of key objects are listed.
+// Errors reading or decoding a key are returned by Load
+func NewRotationKeyLoaderFromStore(store KeyStore, capacity int) (*RotationKeyLoader, error) {
+
+	source, err := newRotationKeySource(store)
+	if err != nil {
+		return nil, err
+	}
+
+	galEls, err := source.galoisElements()
+	if err != nil {
+		return nil, err
+	}
+
+	return newRotationKeyLoader(galEls, source.load, capacity), nil
+
+}
+
+// Check if the loader can provide key for galEl
+func (l *RotationKeyLoader) Has(galEl uint64) bool {
+	return l.galEls[galEl]
+}
+
+// Get galois elements of every key the loader can provide in ascending order
+func (l *RotationKeyLoader) GaloisElements() []uint64 {
+
+	galEls := make([]uint64, 0, len(l.galEls))
+	for galEl := range l.galEls {
+		galEls = append(galEls, galEl)
+	}
+
+	sort.Slice(galEls, func(i, j int) bool { return galEls[i] < galEls[j] })
+
+	return galEls
+
+}
+
+// Get key for galEl like Load. Panics with the error of Load since it's used by evaluators that can't return errors
+func (l *RotationKeyLoader) Get(galEl uint64) *rlwe.SwitchingKey {
+
+	swk, err := l.Load(galEl)
+	if err != nil {
+		panic(err)
+	}
+
+	return swk
+
+}
+
+// Get key for galEl, loading it if it isn't resident. Returns *MissingKeyError if the loader can't provide the key
+// and the error of the underlying loader otherwise
+func (l *RotationKeyLoader) Load(galEl uint64) (*rlwe.SwitchingKey, error) {
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if element, exist := l.resident[galEl]; exist {
+		l.order.MoveToFront(element)
+		return element.Value.(residentKey).swk, nil
+	}
+
+	if !l.galEls[galEl] {
+		return nil, &MissingKeyError{Key: "rotation key for galois element " + strconv.FormatUint(galEl, 10), Location: "rotation key loader"}
+	}
+
+	swk, err := l.loader(galEl)
+	if err != nil {
+		return nil, err
+	}
+
+	l.loaded++
+	l.resident[galEl] = l.order.PushFront(residentKey{galEl, swk})
+
+	for l.Capacity > 0 && l.order.Len() > l.Capacity {
+		oldest := l.order.Back()
+		l.order.Remove(oldest)
+		delete(l.resident, oldest.Value.(residentKey).galEl)
+	}
+
+	return swk, nil
+
+}
+
+// Get rotation key set containing keys for galEls. Keys stay referenced by the set even if they are evicted from the loader
+func (l *RotationKeyLoader) RotationKeySet(galEls []uint64) *rlwe.RotationKeySet {
+
+	rtks := &rlwe.RotationKeySet{Keys: make(map[uint64]*rlwe.SwitchingKey, len(galEls))}
+
+	for _, galEl := range galEls {
+		rtks.Keys[galEl] = l.Get(galEl)
+	}
+
+	return rtks
+
+}
+
+// Amount of keys currently resident
+func (l *RotationKeyLoader) Resident() int {
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	return l.order.Len()
+
+}
+
+// Amount of times a key was fetched through the underlying loader
+func (l *RotationKeyLoader) Loaded() int {
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	return l.loaded
+
+}
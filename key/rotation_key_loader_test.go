@@ -0,0 +1,145 @@
+package key
+
+import (
+	"errors"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/tuneinsight/lattigo/v4/ckks"
+)
+
+func TestRotationKeyLoader(t *testing.T) {
+
+	paramSet := NewParameters(ckks.PN12QP109, nil)
+	keyPair := GenerateKeyPairWithParameters(paramSet)
+	keyChain := GenerateKeysForRotationsWithParameters(paramSet, keyPair.SecretKey, keyPair.PublicKey, []int{1, 2, 4}, false, false)
+
+	store := NewMemoryKeyStore()
+	if err := keyChain.DumpRotationKeysToStore(store); err != nil {
+		t.Fatal(err)
+	}
+
+	loader, err := NewRotationKeyLoaderFromStore(store, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	params := mustCKKSParameters(paramSet)
+	galEls := []uint64{params.GaloisElementForColumnRotationBy(1), params.GaloisElementForColumnRotationBy(2), params.GaloisElementForColumnRotationBy(4)}
+
+	for _, galEl := range galEls {
+		if !loader.Has(galEl) {
+			t.Errorf("Loader should provide key for galois element %d", galEl)
+		}
+	}
+
+	if !loader.Get(galEls[0]).Equals(keyChain.GaloisKey.Keys[galEls[0]]) {
+		t.Error("Lazily loaded key doesn't match original key")
+	}
+
+	loader.Get(galEls[1])
+	loader.Get(galEls[0])
+	loader.Get(galEls[2])
+
+	if loader.Resident() != 2 {
+		t.Errorf("Expected 2 resident keys but got %d", loader.Resident())
+	}
+
+	// galEls[1] was least recently used so it should be the only key loaded again
+	loader.Get(galEls[0])
+	loader.Get(galEls[2])
+
+	if loader.Loaded() != 3 {
+		t.Errorf("Expected 3 loads but got %d", loader.Loaded())
+	}
+
+	loader.Get(galEls[1])
+
+	if loader.Loaded() != 4 {
+		t.Errorf("Evicted key should be loaded again (expected 4 loads but got %d)", loader.Loaded())
+	}
+
+	rtks := loader.RotationKeySet(galEls)
+	if !rtks.Equals(keyChain.GaloisKey) {
+		t.Error("Rotation key set built from loader doesn't match original keys")
+	}
+
+	// Errors of keys that can't be loaded are returned by Load instead of panicking
+	store.Write(rotationKeyName(galEls[0]), []byte("not a key"))
+
+	loader, err = NewRotationKeyLoaderFromStore(store, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var corrupt *CorruptFileError
+	if _, err = loader.Load(galEls[0]); !errors.As(err, &corrupt) {
+		t.Errorf("Loading corrupted key should return CorruptFileError but got %v", err)
+	}
+
+	var missing *MissingKeyError
+	if _, err = loader.Load(3); !errors.As(err, &missing) {
+		t.Errorf("Loading key the loader doesn't have should return MissingKeyError but got %v", err)
+	}
+
+}
+
+// Store that counts requests made to the underlying store
+type countingStore struct {
+	KeyStore
+	sizes  map[string]int
+	ranges map[string]int
+}
+
+func (s countingStore) Size(name string) (int64, error) {
+	s.sizes[name]++
+	return s.KeyStore.Size(name)
+}
+
+func (s countingStore) ReadRange(name string, offset int64, length int64) ([]byte, error) {
+	s.ranges[name]++
+	return s.KeyStore.ReadRange(name, offset, length)
+}
+
+func TestRotationKeyLoaderBundle(t *testing.T) {
+
+	paramSet := NewParameters(ckks.PN12QP109, nil)
+	keyPair := GenerateKeyPairWithParameters(paramSet)
+	keyChain := GenerateKeysForRotationsWithParameters(paramSet, keyPair.SecretKey, keyPair.PublicKey, []int{1, 2, 4}, false, false)
+
+	bundlePath := path.Join(t.TempDir(), BundleFileName)
+	keyChain.DumpBundle(bundlePath, false, true, false, true, false)
+
+	data, err := os.ReadFile(bundlePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := countingStore{KeyStore: NewMemoryKeyStore(), sizes: map[string]int{}, ranges: map[string]int{}}
+	store.Write(BundleFileName, data)
+
+	loader, err := NewRotationKeyLoaderFromStore(store, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	galEls := loader.GaloisElements()
+	for _, galEl := range galEls {
+		if !loader.Get(galEl).Equals(keyChain.GaloisKey.Keys[galEl]) {
+			t.Errorf("Key of galois element %d loaded from bundle doesn't match original key", galEl)
+		}
+	}
+
+	// Prelude and header are read once, then a single range per key
+	if store.sizes[BundleFileName] != 1 || store.ranges[BundleFileName] != 2+len(galEls) {
+		t.Errorf("Expected 1 size and %d range requests but got %d and %d", 2+len(galEls), store.sizes[BundleFileName], store.ranges[BundleFileName])
+	}
+
+	// Corrupted bundle is detected when the loader is created rather than when a key is used
+	store.Write(BundleFileName, data[:len(data)-1])
+	if _, err = NewRotationKeyLoaderFromStore(store, 0); err == nil {
+		t.Error("Loader from store with corrupted bundle header should return error")
+	}
+
+}
@@ -0,0 +1,136 @@
+package key
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+//=================================================
+//				 S3 COMPATIBLE STORE
+//=================================================
+
+// S3KeyStore stores keys as objects under Prefix in an S3 compatible bucket
+type S3KeyStore struct {
+	Client *s3.Client
+	Bucket string
+	Prefix string
+}
+
+func NewS3KeyStore(client *s3.Client, bucket string, prefix string) S3KeyStore {
+	return S3KeyStore{Client: client, Bucket: bucket, Prefix: prefix}
+}
+
+func (s S3KeyStore) objectKey(name string) string {
+	return s.Prefix + name
+}
+
+func (s S3KeyStore) location() string {
+	return "s3://" + s.Bucket + "/" + s.Prefix
+}
+
+func (s S3KeyStore) wrapError(name string, err error) error {
+
+	var responseErr interface{ HTTPStatusCode() int }
+	if errors.As(err, &responseErr) && responseErr.HTTPStatusCode() == http.StatusNotFound {
+		return &MissingKeyError{Key: name, Location: s.location()}
+	}
+
+	return err
+
+}
+
+func (s S3KeyStore) Size(name string) (int64, error) {
+
+	objectKey := s.objectKey(name)
+
+	headObject, err := s.Client.HeadObject(context.TODO(), &s3.HeadObjectInput{
+		Bucket: &s.Bucket,
+		Key:    &objectKey,
+	})
+
+	if err != nil {
+		return 0, s.wrapError(name, err)
+	}
+
+	return headObject.ContentLength, nil
+
+}
+
+func (s S3KeyStore) ReadRange(name string, offset int64, length int64) ([]byte, error) {
+
+	if length <= 0 {
+		return []byte{}, nil
+	}
+
+	objectKey := s.objectKey(name)
+	byteRange := fmt.Sprintf("bytes=%d-%d", offset, offset+length-1)
+
+	s3Object, err := s.Client.GetObject(context.TODO(), &s3.GetObjectInput{
+		Bucket: &s.Bucket,
+		Key:    &objectKey,
+		Range:  &byteRange,
+	})
+
+	if err != nil {
+		return nil, s.wrapError(name, err)
+	}
+
+	defer s3Object.Body.Close()
+
+	return ioutil.ReadAll(s3Object.Body)
+
+}
+
+func (s S3KeyStore) List(prefix string) ([]string, error) {
+
+	listPrefix := s.objectKey(prefix)
+	paginator := s3.NewListObjectsV2Paginator(s.Client, &s3.ListObjectsV2Input{
+		Bucket: &s.Bucket,
+		Prefix: &listPrefix,
+	})
+
+	names := []string{}
+
+	for paginator.HasMorePages() {
+
+		page, err := paginator.NextPage(context.TODO())
+		if err != nil {
+			return nil, err
+		}
+
+		for _, object := range page.Contents {
+			if object.Key != nil {
+				names = append(names, strings.TrimPrefix(*object.Key, s.Prefix))
+			}
+		}
+
+	}
+
+	sort.Strings(names)
+
+	return names, nil
+
+}
+
+func (s S3KeyStore) Write(name string, data []byte) error {
+
+	objectKey := s.objectKey(name)
+
+	_, err := s.Client.PutObject(context.TODO(), &s3.PutObjectInput{
+		Bucket:        &s.Bucket,
+		Key:           &objectKey,
+		Body:          bytes.NewReader(data),
+		ContentLength: int64(len(data)),
+	})
+
+	return err
+
+}
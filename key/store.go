@@ -0,0 +1,252 @@
+package key
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+//=================================================
+//				    KEY STORE
+//=================================================
+
+// KeyStore is a storage backend keys can be read from and written to.
+// Objects are addressed by name (eg. "public_key", "rotation_key_5").
+// Reading an object that doesn't exist returns *MissingKeyError.
+type KeyStore interface {
+	// Size of the object in bytes
+	Size(name string) (int64, error)
+	// Read length bytes of the object starting at offset
+	ReadRange(name string, offset int64, length int64) ([]byte, error)
+	// List name of every object starting with prefix in ascending order
+	List(prefix string) ([]string, error)
+	Write(name string, data []byte) error
+}
+
+// Read the whole object from store
+func ReadAll(store KeyStore, name string) ([]byte, error) {
+
+	size, err := store.Size(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return store.ReadRange(name, 0, size)
+
+}
+
+// Check if store has an object with the given name. Only *MissingKeyError means the object doesn't exist,
+// other errors of the store (eg. timeouts or denied access) are returned
+func Exists(store KeyStore, name string) (bool, error) {
+
+	_, err := store.Size(name)
+
+	var missing *MissingKeyError
+	if errors.As(err, &missing) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	return true, nil
+
+}
+
+// storeReaderAt reads an object of a KeyStore through io.ReaderAt so bundles can be range read from any backend
+type storeReaderAt struct {
+	store KeyStore
+	name  string
+	size  int64
+}
+
+func (r storeReaderAt) ReadAt(p []byte, off int64) (int, error) {
+
+	if off >= r.size {
+		return 0, io.EOF
+	}
+
+	length := int64(len(p))
+	if off+length > r.size {
+		length = r.size - off
+	}
+
+	data, err := r.store.ReadRange(r.name, off, length)
+	if err != nil {
+		return 0, err
+	}
+
+	n := copy(p, data)
+	if n < len(p) {
+		return n, io.EOF
+	}
+
+	return n, nil
+
+}
+
+//=================================================
+//				 LOCAL FILE SYSTEM
+//=================================================
+
+type LocalKeyStore struct {
+	Dir string
+}
+
+func NewLocalKeyStore(dirName string) LocalKeyStore {
+	return LocalKeyStore{Dir: dirName}
+}
+
+func (s LocalKeyStore) Size(name string) (int64, error) {
+
+	info, err := os.Stat(path.Join(s.Dir, name))
+	if os.IsNotExist(err) {
+		return 0, &MissingKeyError{Key: name, Location: s.Dir}
+	} else if err != nil {
+		return 0, err
+	}
+
+	return info.Size(), nil
+
+}
+
+func (s LocalKeyStore) ReadRange(name string, offset int64, length int64) ([]byte, error) {
+
+	f, err := os.Open(path.Join(s.Dir, name))
+	if os.IsNotExist(err) {
+		return nil, &MissingKeyError{Key: name, Location: s.Dir}
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	data := make([]byte, length)
+	n, err := f.ReadAt(data, offset)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	return data[:n], nil
+
+}
+
+func (s LocalKeyStore) List(prefix string) ([]string, error) {
+
+	files, err := ioutil.ReadDir(s.Dir)
+	if os.IsNotExist(err) {
+		return []string{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+	for _, file := range files {
+		if !file.IsDir() && strings.HasPrefix(file.Name(), prefix) {
+			names = append(names, file.Name())
+		}
+	}
+
+	return names, nil
+
+}
+
+func (s LocalKeyStore) Write(name string, data []byte) error {
+
+	location := path.Join(s.Dir, name)
+
+	if err := os.MkdirAll(filepath.Dir(location), 0777); err != nil {
+		return err
+	}
+
+	return os.WriteFile(location, data, 0644)
+
+}
+
+//=================================================
+//					 IN MEMORY
+//=================================================
+
+type MemoryKeyStore struct {
+	lock    *sync.RWMutex
+	objects map[string][]byte
+}
+
+func NewMemoryKeyStore() MemoryKeyStore {
+	return MemoryKeyStore{lock: &sync.RWMutex{}, objects: make(map[string][]byte)}
+}
+
+func (s MemoryKeyStore) Size(name string) (int64, error) {
+
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	data, exist := s.objects[name]
+	if !exist {
+		return 0, &MissingKeyError{Key: name, Location: "memory"}
+	}
+
+	return int64(len(data)), nil
+
+}
+
+func (s MemoryKeyStore) ReadRange(name string, offset int64, length int64) ([]byte, error) {
+
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	data, exist := s.objects[name]
+	if !exist {
+		return nil, &MissingKeyError{Key: name, Location: "memory"}
+	}
+
+	if offset >= int64(len(data)) {
+		return []byte{}, nil
+	}
+
+	end := offset + length
+	if end > int64(len(data)) {
+		end = int64(len(data))
+	}
+
+	result := make([]byte, end-offset)
+	copy(result, data[offset:end])
+
+	return result, nil
+
+}
+
+func (s MemoryKeyStore) List(prefix string) ([]string, error) {
+
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	names := []string{}
+	for name := range s.objects {
+		if strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+
+	return names, nil
+
+}
+
+func (s MemoryKeyStore) Write(name string, data []byte) error {
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	stored := make([]byte, len(data))
+	copy(stored, data)
+	s.objects[name] = stored
+
+	return nil
+
+}
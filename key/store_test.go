@@ -0,0 +1,257 @@
+package key
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/tuneinsight/lattigo/v4/ckks"
+	"github.com/tuneinsight/lattigo/v4/rlwe"
+)
+
+// Minimal S3 compatible server supporting HEAD, ranged GET, PUT and ListObjectsV2 with path style addressing
+func newS3StandIn() *httptest.Server {
+
+	var lock sync.RWMutex
+	objects := make(map[string][]byte)
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		objectKey := strings.TrimPrefix(r.URL.Path, "/test-bucket")
+		objectKey = strings.TrimPrefix(objectKey, "/")
+
+		switch r.Method {
+		case http.MethodPut:
+			data, _ := ioutil.ReadAll(r.Body)
+			lock.Lock()
+			objects[objectKey] = data
+			lock.Unlock()
+			return
+		case http.MethodGet:
+			if r.URL.Query().Get("list-type") == "2" {
+				prefix := r.URL.Query().Get("prefix")
+				body := "<ListBucketResult><Name>test-bucket</Name><IsTruncated>false</IsTruncated>"
+				lock.RLock()
+				for name := range objects {
+					if strings.HasPrefix(name, prefix) {
+						body += "<Contents><Key>" + name + "</Key></Contents>"
+					}
+				}
+				lock.RUnlock()
+				w.Header().Set("Content-Type", "application/xml")
+				fmt.Fprint(w, body+"</ListBucketResult>")
+				return
+			}
+		}
+
+		lock.RLock()
+		data, exist := objects[objectKey]
+		lock.RUnlock()
+
+		if !exist {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		if r.Method == http.MethodHead {
+			w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+			return
+		}
+
+		var start, end int
+		if _, err := fmt.Sscanf(r.Header.Get("Range"), "bytes=%d-%d", &start, &end); err == nil {
+			if end >= len(data) {
+				end = len(data) - 1
+			}
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, len(data)))
+			w.WriteHeader(http.StatusPartialContent)
+			w.Write(data[start : end+1])
+			return
+		}
+
+		w.Write(data)
+
+	}))
+
+}
+
+func newStandInS3KeyStore(url string, prefix string) S3KeyStore {
+
+	client := s3.New(s3.Options{
+		Region:           "us-east-1",
+		EndpointResolver: s3.EndpointResolverFromURL(url),
+		UsePathStyle:     true,
+		Credentials:      aws.AnonymousCredentials{},
+	})
+
+	return NewS3KeyStore(client, "test-bucket", prefix)
+
+}
+
+func testKeyStore(t *testing.T, store KeyStore) {
+
+	paramSet := NewParameters(ckks.PN12QP109, nil)
+	keyPair := GenerateKeyPairWithParameters(paramSet)
+	keyChain := GenerateKeysForRotationsWithParameters(paramSet, keyPair.SecretKey, keyPair.PublicKey, []int{1, 2, -1}, false, false)
+
+	if err := keyChain.DumpKeysToStore(store, true, true, true, false, false); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := keyChain.DumpRotationKeysToStore(store); err != nil {
+		t.Fatal(err)
+	}
+
+	galEls, err := ListRotationKeys(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(galEls) != 3 {
+		t.Errorf("Expected 3 rotation keys in store but got %d", len(galEls))
+	}
+
+	loaded, err := LoadKeysFromStore(store, paramSet, true, true, true, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !loaded.PublicKey.Equals(keyChain.PublicKey) || !loaded.RelinKey.Equals(keyChain.RelinKey) || !loaded.GaloisKey.Equals(keyChain.GaloisKey) {
+		t.Error("Keys loaded from store doesn't match saved keys")
+	}
+
+	swk, err := LoadRotationKey(store, galEls[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !swk.Equals(keyChain.GaloisKey.Keys[galEls[0]]) {
+		t.Error("Rotation key loaded individually doesn't match saved key")
+	}
+
+	var missingErr *MissingKeyError
+	if _, err = LoadRotationKey(store, 3); !errors.As(err, &missingErr) {
+		t.Errorf("Loading missing rotation key should return MissingKeyError but got %v", err)
+	}
+
+	// Encoded galois keys are range read
+	encoded, err := EncodeGaloisKeys(keyChain.GaloisKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = store.Write("galois", encoded); err != nil {
+		t.Fatal(err)
+	}
+
+	params, _ := paramSet.CKKSParameters()
+	rtks := rlwe.NewRotationKeySet(params.Parameters, []uint64{})
+
+	if err = UnmarshalGaloisFromStore(rtks, store, "galois"); err != nil {
+		t.Fatal(err)
+	}
+
+	if !rtks.Equals(keyChain.GaloisKey) {
+		t.Error("Galois keys unmarshalled from store doesn't match original keys")
+	}
+
+}
+
+func TestMemoryKeyStore(t *testing.T) {
+	testKeyStore(t, NewMemoryKeyStore())
+}
+
+func TestLocalKeyStore(t *testing.T) {
+	testKeyStore(t, NewLocalKeyStore(path.Join(t.TempDir(), "keys")))
+}
+
+func TestS3KeyStore(t *testing.T) {
+
+	server := newS3StandIn()
+	defer server.Close()
+
+	testKeyStore(t, newStandInS3KeyStore(server.URL, "keys/"))
+
+}
+
+func TestKeyStoreBundle(t *testing.T) {
+
+	paramSet := NewParameters(ckks.PN12QP109, nil)
+	keyPair := GenerateKeyPairWithParameters(paramSet)
+	keyChain := GenerateKeysForRotationsWithParameters(paramSet, keyPair.SecretKey, keyPair.PublicKey, []int{1, 4}, false, false)
+
+	bundlePath := path.Join(t.TempDir(), BundleFileName)
+	keyChain.DumpBundle(bundlePath, false, true, true, true, false)
+
+	data, err := os.ReadFile(bundlePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := NewMemoryKeyStore()
+	store.Write(BundleFileName, data)
+
+	loaded, err := LoadKeysFromStore(store, paramSet, false, true, true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !loaded.PublicKey.Equals(keyChain.PublicKey) {
+		t.Error("Public key loaded from bundle in store doesn't match original key")
+	}
+
+	galEl := mustCKKSParameters(paramSet).GaloisElementForColumnRotationBy(4)
+	swk, err := LoadRotationKey(store, galEl)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !swk.Equals(keyChain.GaloisKey.Keys[galEl]) {
+		t.Error("Rotation key loaded from bundle in store doesn't match original key")
+	}
+
+}
+
+// Store that can't be reached, like S3 timing out or denying access
+type unreachableStore struct {
+	KeyStore
+}
+
+var errUnreachable = errors.New("store is unreachable")
+
+func (s unreachableStore) Size(name string) (int64, error) {
+	return 0, errUnreachable
+}
+
+func TestUnreachableKeyStore(t *testing.T) {
+
+	store := unreachableStore{NewMemoryKeyStore()}
+
+	if _, err := Exists(store, BundleFileName); !errors.Is(err, errUnreachable) {
+		t.Errorf("Exists should return error of unreachable store but got %v", err)
+	}
+
+	// Errors other than missing keys aren't mistaken for a store without bundle
+	if _, err := LoadKeysFromStore(store, NewParameters(ckks.PN12QP109, nil), false, true, false, false); !errors.Is(err, errUnreachable) {
+		t.Errorf("Loading keys should return error of unreachable store but got %v", err)
+	}
+
+	if _, err := ListRotationKeys(store); !errors.Is(err, errUnreachable) {
+		t.Errorf("Listing rotation keys should return error of unreachable store but got %v", err)
+	}
+
+	if exist, err := Exists(NewMemoryKeyStore(), BundleFileName); exist || err != nil {
+		t.Errorf("Missing object should not exist without error but got %t and %v", exist, err)
+	}
+
+}
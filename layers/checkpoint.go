@@ -0,0 +1,153 @@
+package layers
+
+import (
+	"fmt"
+
+	"github.com/perm-ai/go-cerebrum/optimizers"
+	"github.com/tuneinsight/lattigo/v4/rlwe"
+)
+
+//=================================================
+//				  LAYER CHECKPOINT
+//=================================================
+
+// LayerCheckpoint holds the training state of a layer. Weights and biases stay encrypted
+// and are stored as marshalled ciphertexts, so no secret key is needed to save or restore them.
+type LayerCheckpoint struct {
+	Type           string
+	Shape          []int    // Shape of Weights before flattening
+	Weights        [][]byte // Weight ciphertexts flattened in row-major order
+	Bias           [][]byte
+	WeightLevel    int
+	BtspOutput     []bool
+	BtspActivation []bool
+	LearningRate   float64
+	OptimizerType  string
+	OptimizerStep  int
+	Optimizer      map[string][][]byte // Encrypted optimizer state by weight key, empty for plain SGD
+}
+
+func (c LayerCheckpoint) checkType(layerType string) error {
+
+	if c.Type != layerType {
+		return fmt.Errorf("checkpoint of %s layer can't be restored into %s layer", c.Type, layerType)
+	}
+
+	return nil
+
+}
+
+func (c LayerCheckpoint) checkShape(shape []int) error {
+
+	if len(c.Shape) != len(shape) {
+		return fmt.Errorf("%s checkpoint has weight shape %v but layer has shape %v", c.Type, c.Shape, shape)
+	}
+
+	for i := range shape {
+		if c.Shape[i] != shape[i] {
+			return fmt.Errorf("%s checkpoint has weight shape %v but layer has shape %v", c.Type, c.Shape, shape)
+		}
+	}
+
+	return nil
+
+}
+
+func (c *LayerCheckpoint) saveOptimizer(optimizer optimizers.Optimizer) error {
+
+	if optimizer == nil {
+		return nil
+	}
+
+	step, state := optimizer.State()
+
+	c.OptimizerType = optimizer.GetType()
+	c.OptimizerStep = step
+	c.Optimizer = make(map[string][][]byte, len(state))
+
+	for key := range state {
+		data, err := marshalCiphertexts(state[key])
+		if err != nil {
+			return err
+		}
+		c.Optimizer[key] = data
+	}
+
+	return nil
+
+}
+
+func (c LayerCheckpoint) restoreOptimizer(optimizer optimizers.Optimizer) error {
+
+	if optimizer == nil || c.OptimizerType == "" {
+		return nil
+	}
+
+	if c.OptimizerType != optimizer.GetType() {
+		return fmt.Errorf("checkpoint has state of %s optimizer but layer uses %s optimizer", c.OptimizerType, optimizer.GetType())
+	}
+
+	state := make(map[string][]*rlwe.Ciphertext, len(c.Optimizer))
+
+	for key := range c.Optimizer {
+		cts, err := unmarshalCiphertexts(c.Optimizer[key])
+		if err != nil {
+			return err
+		}
+		state[key] = cts
+	}
+
+	optimizer.LoadState(c.OptimizerStep, state)
+
+	return nil
+
+}
+
+func marshalCiphertexts(cts []*rlwe.Ciphertext) ([][]byte, error) {
+
+	data := make([][]byte, len(cts))
+
+	for i := range cts {
+
+		if cts[i] == nil {
+			continue
+		}
+
+		var err error
+		if data[i], err = cts[i].MarshalBinary(); err != nil {
+			return nil, err
+		}
+
+	}
+
+	return data, nil
+
+}
+
+func unmarshalCiphertexts(data [][]byte) (cts []*rlwe.Ciphertext, err error) {
+
+	// Lattigo panics instead of returning an error on some malformed inputs
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("malformed ciphertext: %v", r)
+		}
+	}()
+
+	cts = make([]*rlwe.Ciphertext, len(data))
+
+	for i := range data {
+
+		if len(data[i]) == 0 {
+			continue
+		}
+
+		cts[i] = &rlwe.Ciphertext{}
+		if err = cts[i].UnmarshalBinary(data[i]); err != nil {
+			return nil, err
+		}
+
+	}
+
+	return cts, nil
+
+}
@@ -1,12 +1,14 @@
 package layers
 
 import (
+	"fmt"
 	"math"
 	"sync"
 
 	"github.com/tuneinsight/lattigo/v4/rlwe"
 	"github.com/perm-ai/go-cerebrum/activations"
 	"github.com/perm-ai/go-cerebrum/array"
+	"github.com/perm-ai/go-cerebrum/optimizers"
 	"github.com/perm-ai/go-cerebrum/utility"
 )
 
@@ -19,9 +21,10 @@ type conv2dKernel struct {
 	Column int
 	Depth  int
 	Data   [][][]*rlwe.Ciphertext
+	Plain  [][][]*rlwe.Plaintext // Encoded public weights used instead of Data when set
 }
 
-func generateRandomNormal2dKernel(row int, col int, depth int, utils utility.Utils) conv2dKernel {
+func generateRandomNormal2dKernel(row int, col int, depth int, level int, utils utility.Utils) conv2dKernel {
 
 	weightStdDev := math.Sqrt(2.0 / float64(row*col*depth))
 	randomNums := array.GenerateRandomNormalArray(row*col*depth, weightStdDev)
@@ -36,190 +39,86 @@ func generateRandomNormal2dKernel(row int, col int, depth int, utils utility.Uti
 			data[r][c] = make([]*rlwe.Ciphertext, depth)
 
 			for d := 0; d < depth; d++ {
-				data[r][c][d] = utils.EncryptToLevel(utils.GenerateFilledArray(randomNums[(r*col)+c]), 9)
+				data[r][c][d] = utils.EncryptToLevel(utils.GenerateFilledArray(randomNums[(((r*col)+c)*depth)+d]), level)
 			}
 		}
 	}
 
-	return conv2dKernel{row, col, depth, data}
-
-}
-
-func generate2dKernelFromArray(data [][][]*rlwe.Ciphertext) conv2dKernel {
-
-	row := len(data)
-	col := len(data[0])
-	depth := len(data[0][0])
-
 	return conv2dKernel{Row: row, Column: col, Depth: depth, Data: data}
 
 }
 
-func (k *conv2dKernel) updateWeight(gradient [][]*rlwe.Ciphertext, lr rlwe.Plaintext, utils utility.Utils, weightLevel int) {
-
-	// create row weight group
-	var rowWg sync.WaitGroup
-
-	for row := range gradient {
-
-		// Add 1 task to wait group
-		rowWg.Add(1)
-
-		go func(rowIndex int) {
-
-			defer rowWg.Done()
-
-			var colWg sync.WaitGroup
-
-			for col := range gradient[rowIndex] {
-
-				colWg.Add(1)
-
-				go func(colIndex int, colUtils utility.Utils) {
-					defer colWg.Done()
-
-					averagedLrGradient := colUtils.MultiplyPlainNew(gradient[rowIndex][colIndex], &lr, true, false)
-
-					if averagedLrGradient.Level() < weightLevel {
-						colUtils.BootstrapInPlace(averagedLrGradient)
-					}
-
-					var depWg sync.WaitGroup
-
-					for d := range k.Data[rowIndex][colIndex] {
-						depWg.Add(1)
-
-						go func(depIndex int, depUtils utility.Utils) {
-							defer depWg.Done()
-							depUtils.Sub(k.Data[rowIndex][colIndex][depIndex], averagedLrGradient, k.Data[rowIndex][colIndex][depIndex])
-						}(d, colUtils.ShallowCopy())
-
-					}
-
-					depWg.Done()
-
-				}(col, utils.ShallowCopy())
-
-			}
-
-			colWg.Wait()
-
-		}(row)
-
-	}
-
-	rowWg.Wait()
-
-}
-
-func (k *conv2dKernel) dilate(dilation []int) {
-
-	// Calculate the number of row for dilated kernel
-	newRow := k.Row + ((dilation[0] - 1) * (k.Row - 1))
-
-	// Calculate row modulo for checking if certain row should be empty or not
-	rowMod := dilation[0]
-
-	// Calculate the number of column for dilated kernel
-	newCol := k.Column + ((dilation[1] - 1) * (k.Column - 1))
-
-	// Calculate column modulo for checking if certain column should be empty or not
-	colMod := dilation[1]
-
-	newData := make([][][]*rlwe.Ciphertext, newRow)
-
-	oldRow := 0
-
-	for row := range newData {
-
-		oldCol := 0
-		newData[row] = make([][]*rlwe.Ciphertext, newCol)
+// Encode public kernel weights given as [row][column][depth]
+func generatePlain2dKernel(weights [][][]float64, utils utility.Utils) conv2dKernel {
 
-		if row%rowMod == 0 {
-			for col := range newData[row] {
+	data := make([][][]*rlwe.Plaintext, len(weights))
 
-				newData[row][col] = make([]*rlwe.Ciphertext, k.Depth)
+	for r := range weights {
 
-				if col%colMod == 0 {
+		data[r] = make([][]*rlwe.Plaintext, len(weights[r]))
 
-					newData[row][col] = k.Data[oldRow][oldCol]
-					oldCol++
+		for c := range weights[r] {
 
-				}
+			data[r][c] = make([]*rlwe.Plaintext, len(weights[r][c]))
 
+			for d := range weights[r][c] {
+				data[r][c][d] = utils.EncodePlaintextFromArray(utils.GenerateFilledArray(weights[r][c][d]))
 			}
-			oldRow++
 		}
-
 	}
 
-	k.Row = newRow
-	k.Column = newCol
-	k.Data = newData
+	return conv2dKernel{Row: len(weights), Column: len(weights[0]), Depth: len(weights[0][0]), Plain: data}
 
 }
 
-// Add padding to kernel (used as part of calculation for dL/dA(l-1))
-// size is padding you want to add for [row, column]
-func (k *conv2dKernel) addPadding(size []int) {
-
-	newRow := k.Row + (2 * size[0])
-	newCol := k.Column + (2 * size[1])
+func generate2dKernelFromArray(data [][][]*rlwe.Ciphertext) conv2dKernel {
 
-	newData := make([][][]*rlwe.Ciphertext, newRow)
+	row := len(data)
+	col := len(data[0])
+	depth := len(data[0][0])
 
-	oldRowIndex := 0
+	return conv2dKernel{Row: row, Column: col, Depth: depth, Data: data}
 
-	// Loop through each new row in new data
-	for newRowIndex := 0; newRowIndex < newRow; newRowIndex++ {
+}
 
-		newData[newRowIndex] = make([][]*rlwe.Ciphertext, newCol)
-		oldColIndex := 0
+// Update kernel with gradient given as [row][column][depth] summed over the batch and averaged by averager using the update
+// rule of optimizer. Weights are identified by keyPrefix followed by their position
+func (k *conv2dKernel) updateWeight(gradient [][][]*rlwe.Ciphertext, averager rlwe.Plaintext, utils utility.Utils, weightLevel int, optimizer optimizers.Optimizer, keyPrefix string, lr float64) {
 
-		// Check if that row isn't in padding
-		if newRowIndex >= size[0] && newRowIndex < size[0]+k.Row {
+	// create weight group
+	var wg sync.WaitGroup
 
-			// Loop through each column in that row
-			for newColIndex := 0; newColIndex < newCol; newColIndex++ {
+	for row := range gradient {
+		for col := range gradient[row] {
+			for dep := range gradient[row][col] {
 
-				// Check if column in padding or not
-				if newColIndex >= size[1] && newColIndex < size[1]+k.Column {
-					newData[newRowIndex][newColIndex] = k.Data[oldRowIndex][oldColIndex]
-					oldColIndex++
-				} else {
-					newData[newRowIndex][newColIndex] = make([]*rlwe.Ciphertext, k.Depth)
+				// Weight that never overlaps with input has no gradient
+				if gradient[row][col][dep] == nil {
+					continue
 				}
 
-			}
-			oldRowIndex++
-
-		}
-
-	}
-
-	k.Row = newRow
-	k.Column = newCol
-	k.Data = newData
-
-}
+				wg.Add(1)
 
-func (k conv2dKernel) rotate180() conv2dKernel {
+				go func(rowIndex int, colIndex int, depIndex int, depUtils utility.Utils) {
 
-	rotated := make([][][]*rlwe.Ciphertext, k.Row)
+					defer wg.Done()
 
-	for row := range rotated {
+					depUtils.SumElementsInPlace(gradient[rowIndex][colIndex][depIndex])
+					averagedLrGradient := depUtils.MultiplyPlainNew(gradient[rowIndex][colIndex][depIndex], &averager, true, false)
 
-		rotated[row] = make([][]*rlwe.Ciphertext, k.Column)
+					if averagedLrGradient.Level() < weightLevel {
+						depUtils.BootstrapInPlace(averagedLrGradient)
+					}
 
-		for col := range rotated[row] {
+					optimizer.Update(fmt.Sprintf("%s/%d/%d/%d", keyPrefix, rowIndex, colIndex, depIndex), k.Data[rowIndex][colIndex][depIndex], averagedLrGradient, lr, depUtils)
 
-			rotated[row][col] = k.Data[k.Row-row-1][k.Column-col-1]
+				}(row, col, dep, utils.ShallowCopy())
 
+			}
 		}
-
 	}
 
-	return conv2dKernel{k.Row, k.Column, k.Depth, rotated}
+	wg.Wait()
 
 }
 
@@ -235,10 +134,12 @@ type Conv2D struct {
 	Padding        bool
 	Activation     *activations.Activation
 	InputSize      []int
+	PlainBias      []float64 // Public bias used instead of Bias when kernels hold public weights
 	btspOutput     []bool
 	btspActivation []bool
 	batchSize      int
 	weightLevel    int
+	optimizer      optimizers.Optimizer
 }
 
 // Constructor for Convolutional layer struct
@@ -252,10 +153,16 @@ type Conv2D struct {
 // batchSize, when useBias is true, is the number of training example in a training ciphertexts with lowest number of training data
 func NewConv2D(utils utility.Utils, filters int, kernelSize []int, strides []int, padding bool, activation *activations.Activation, useBias bool, inputSize []int, batchSize int) Conv2D {
 
+	// Weights are encrypted at level 9 unless parameters don't have that many levels
+	weightLevel := 9
+	if utils.Params.MaxLevel() < weightLevel {
+		weightLevel = utils.Params.MaxLevel()
+	}
+
 	kernels := make([]conv2dKernel, filters)
 
 	for i := range kernels {
-		kernels[i] = generateRandomNormal2dKernel(kernelSize[0], kernelSize[1], inputSize[2], utils)
+		kernels[i] = generateRandomNormal2dKernel(kernelSize[0], kernelSize[1], inputSize[2], weightLevel, utils)
 	}
 
 	bias := []*rlwe.Ciphertext{}
@@ -263,11 +170,27 @@ func NewConv2D(utils utility.Utils, filters int, kernelSize []int, strides []int
 		randomBias := utils.GenerateFilledArraySize(0, filters)
 		bias = make([]*rlwe.Ciphertext, filters)
 		for i := range bias {
-			bias[i] = utils.EncryptToLevel(utils.GenerateFilledArraySize(randomBias[i], batchSize), 9)
+			bias[i] = utils.EncryptToLevel(utils.GenerateFilledArraySize(randomBias[i], batchSize), weightLevel)
 		}
 	}
 
-	return Conv2D{utils: utils, Kernels: kernels, Bias: bias, Strides: strides, Padding: padding, Activation: activation, InputSize: inputSize, batchSize: batchSize, btspOutput: []bool{false, false}, btspActivation: []bool{false, false}, weightLevel: 9}
+	return Conv2D{utils: utils, Kernels: kernels, Bias: bias, Strides: strides, Padding: padding, Activation: activation, InputSize: inputSize, batchSize: batchSize, btspOutput: []bool{false, false}, btspActivation: []bool{false, false}, weightLevel: weightLevel, optimizer: optimizers.NewSGD()}
+
+}
+
+// Create convolutional layer with public kernels kept as encoded plaintexts for the public model, private data setting.
+// Products with input use MultiplyPlain so they don't need relinearization. The layer isn't trainable but still
+// propagates input gradient so layers before it can be trained.
+// kernels is given as [filter][row][column][depth] and bias can be nil for layer without bias
+func NewPlainConv2D(utils utility.Utils, kernels [][][][]float64, bias []float64, strides []int, padding bool, activation *activations.Activation, inputSize []int, batchSize int) (Conv2D, error) {
+
+	c := Conv2D{utils: utils, Strides: strides, Padding: padding, Activation: activation, InputSize: inputSize, batchSize: batchSize, btspOutput: []bool{false, false}, btspActivation: []bool{false, false}, weightLevel: utils.Params.MaxLevel()}
+
+	if err := c.SetPlainKernels(kernels, bias); err != nil {
+		return Conv2D{}, err
+	}
+
+	return c, nil
 
 }
 
@@ -275,6 +198,51 @@ func (c *Conv2D) LoadKernels(kernels []conv2dKernel) {
 	c.Kernels = kernels
 }
 
+// Use public kernels and bias encoded as plaintexts instead of encrypted kernels. The layer stops being trainable.
+// kernels is given as [filter][row][column][depth] and bias can be nil for layer without bias
+func (c *Conv2D) SetPlainKernels(kernels [][][][]float64, bias []float64) error {
+
+	if len(kernels) == 0 || len(kernels[0]) == 0 || len(kernels[0][0]) == 0 {
+		return fmt.Errorf("kernel is empty")
+	}
+
+	if bias != nil && len(bias) != len(kernels) {
+		return fmt.Errorf("bias has %d values but layer has %d filters", len(bias), len(kernels))
+	}
+
+	for k := range kernels {
+		if len(kernels[k]) != len(kernels[0]) {
+			return fmt.Errorf("kernel %d has %d rows but kernel 0 has %d rows", k, len(kernels[k]), len(kernels[0]))
+		}
+		for r := range kernels[k] {
+			if len(kernels[k][r]) != len(kernels[0][0]) {
+				return fmt.Errorf("kernel %d row %d has %d columns but kernel 0 has %d columns", k, r, len(kernels[k][r]), len(kernels[0][0]))
+			}
+			for col := range kernels[k][r] {
+				if len(kernels[k][r][col]) != c.InputSize[2] {
+					return fmt.Errorf("kernel %d has depth %d but input has %d channels", k, len(kernels[k][r][col]), c.InputSize[2])
+				}
+			}
+		}
+	}
+
+	encoded := make([]conv2dKernel, len(kernels))
+	for k := range kernels {
+		encoded[k] = generatePlain2dKernel(kernels[k], c.utils)
+	}
+
+	c.Kernels = encoded
+	c.Bias = []*rlwe.Ciphertext{}
+	c.PlainBias = bias
+
+	return nil
+
+}
+
+func (c Conv2D) hasPlainKernels() bool {
+	return len(c.Kernels) != 0 && c.Kernels[0].Plain != nil
+}
+
 // Evaluate forward pass of the convolutional 2d layer
 // input must be packed according to section 3.1.1 in https://eprint.iacr.org/2018/1056.pdf
 func (c Conv2D) Forward(input [][][]*rlwe.Ciphertext) Output2d {
@@ -345,6 +313,7 @@ func (c Conv2D) Forward(input [][][]*rlwe.Ciphertext) Output2d {
 							// Declare result to store the dot product of kernel and that region of input
 							// var result *rlwe.Ciphertext
 							kernelCiphertext := []*rlwe.Ciphertext{}
+							kernelPlaintext := []*rlwe.Plaintext{}
 							inputCiphertext := []*rlwe.Ciphertext{}
 
 							for krow := 0; krow < c.Kernels[kernelIndex].Row; krow++ {
@@ -357,13 +326,31 @@ func (c Conv2D) Forward(input [][][]*rlwe.Ciphertext) Output2d {
 
 									for kdep := 0; kdep < c.Kernels[kernelIndex].Depth; kdep++ {
 
-										kernelCiphertext = append(kernelCiphertext, c.Kernels[kernelIndex].Data[krow][kcol][kdep])
+										if c.hasPlainKernels() {
+											kernelPlaintext = append(kernelPlaintext, c.Kernels[kernelIndex].Plain[krow][kcol][kdep])
+										} else {
+											kernelCiphertext = append(kernelCiphertext, c.Kernels[kernelIndex].Data[krow][kcol][kdep])
+										}
 										inputCiphertext = append(inputCiphertext, input[rowIndex+krow][colIndex+kcol][kdep])
 
 									}
 								}
 							}
 
+							if c.hasPlainKernels() {
+
+								utils := c.utils.CopyWithClonedEval()
+								result := utils.InterPlainDotProduct(inputCiphertext, kernelPlaintext, nil)
+
+								if len(c.PlainBias) != 0 {
+									utils.Evaluator.AddConst(result, c.PlainBias[kernelIndex], result)
+								}
+
+								output1dChannel <- result
+								return
+
+							}
+
 							result := c.utils.InterDotProduct(kernelCiphertext, inputCiphertext, true, true, nil)
 
 							if len(c.Bias) != 0 {
@@ -426,6 +413,31 @@ func (c Conv2D) Forward(input [][][]*rlwe.Ciphertext) Output2d {
 
 }
 
+// Evaluate the layer for inference only and return only the final (activated) output. Unlike Forward, ciphertexts are
+// only bootstrapped when their level isn't enough for the next operation
+func (c Conv2D) Predict(input [][][]*rlwe.Ciphertext) [][][]*rlwe.Ciphertext {
+
+	for r := range input {
+		for col := range input[r] {
+			c.utils.Bootstrap1dIfBelow(input[r][col], c.GetForwardLevelConsumption())
+		}
+	}
+
+	// Replace training bootstrapping schedule with one that only bootstraps output if activation can't be evaluated
+	outputLevel := minLevel3d(input) - c.GetForwardLevelConsumption()
+	c.btspOutput = []bool{c.HasActivation() && outputLevel < c.GetForwardActivationLevelConsumption(), false}
+	c.btspActivation = []bool{false, false}
+
+	output := c.Forward(input)
+
+	if c.HasActivation() {
+		return output.ActivationOutput
+	}
+
+	return output.Output
+
+}
+
 func (c Conv2D) Backward(input [][][]*rlwe.Ciphertext, output [][][]*rlwe.Ciphertext, gradient [][][]*rlwe.Ciphertext, hasPrevLayer bool) Gradient2d {
 
 	gradients := Gradient2d{}
@@ -519,7 +531,7 @@ func (c Conv2D) Backward(input [][][]*rlwe.Ciphertext, output [][][]*rlwe.Cipher
 			for ri := range gradient {
 				for ci := range gradient[ri] {
 					if gradients.BiasGradient[k] == nil {
-						gradients.BiasGradient[k] = gradient[ri][ci][k]
+						gradients.BiasGradient[k] = gradient[ri][ci][k].CopyNew()
 					} else {
 						c.utils.Add(gradient[ri][ci][k], gradients.BiasGradient[k], gradients.BiasGradient[k])
 					}
@@ -528,230 +540,180 @@ func (c Conv2D) Backward(input [][][]*rlwe.Ciphertext, output [][][]*rlwe.Cipher
 		}
 	}
 
-	// Calculate ∂Z/∂F
-	gradientKernel := generate2dKernelFromArray(gradient)
-
-	padding := 0
-	if c.Padding {
-		padding = 1
+	// Public kernels aren't trained, only ∂L/∂A(l-1) is needed
+	if !c.hasPlainKernels() {
+		gradients.WeightGradient = c.weightGradient(input, gradient)
 	}
 
-	if c.Strides[0] != 0 && c.Strides[1] != 0 {
-		gradientKernel.dilate(c.Strides)
+	if hasPrevLayer {
+		gradients.InputGradient = c.inputGradient(gradient)
 	}
 
-	gradients.WeightGradient = make([][][]*rlwe.Ciphertext, len(c.Kernels))
-	weightGradientKernelChannels := make([]chan [][]*rlwe.Ciphertext, len(c.Kernels))
-
-	// loop throught gradient of each kernel
-	for k := 0; k < gradientKernel.Depth; k++ {
-
-		weightGradientKernelChannels[k] = make(chan [][]*rlwe.Ciphertext)
+	return gradients
+}
 
-		go func(kernelIndex int, weightGradientKernelChannel chan [][]*rlwe.Ciphertext) {
+// Calculate ∂L/∂F where ∂L/∂F[k][kr][kc][d] = Σi Σj ∂L/∂Z[i][j][k] * A(l-1)[i*stride+kr-padding][j*stride+kc-padding][d]
+func (c Conv2D) weightGradient(input [][][]*rlwe.Ciphertext, gradient [][][]*rlwe.Ciphertext) [][][][]*rlwe.Ciphertext {
 
-			weightGradientRowChannels := make([]chan []*rlwe.Ciphertext, c.Kernels[0].Row)
-			currentGradientRow := 0
+	padding := c.paddingSize()
+	weightGradient := make([][][][]*rlwe.Ciphertext, len(c.Kernels))
 
-			// Loop through input row
-			for row := (padding * -1); row <= c.InputSize[0]-gradientKernel.Row+padding; row++ {
+	var wg sync.WaitGroup
 
-				weightGradientRowChannels[currentGradientRow] = make(chan []*rlwe.Ciphertext)
+	for k := range c.Kernels {
 
-				go func(kernelIndex int, rowIndex int, weightGradientRowChannel chan []*rlwe.Ciphertext) {
+		weightGradient[k] = make([][][]*rlwe.Ciphertext, c.Kernels[k].Row)
 
-					weightGradientColChannels := make([]chan *rlwe.Ciphertext, c.Kernels[0].Column)
-					currentGradientCol := 0
+		for krow := range weightGradient[k] {
 
-					// Loop through input column
-					for col := (padding * -1); col <= c.InputSize[1]-gradientKernel.Column+padding; col++ {
+			weightGradient[k][krow] = make([][]*rlwe.Ciphertext, c.Kernels[k].Column)
 
-						weightGradientColChannels[currentGradientCol] = make(chan *rlwe.Ciphertext)
+			for kcol := range weightGradient[k][krow] {
 
-						go func(kernelIndex int, rowIndex int, colIndex int, weightGradientColChannel chan *rlwe.Ciphertext) {
+				weightGradient[k][krow][kcol] = make([]*rlwe.Ciphertext, c.Kernels[k].Depth)
 
-							kernelCiphertexts := []*rlwe.Ciphertext{}
-							inputCiphertexts := []*rlwe.Ciphertext{}
+				for kdep := range weightGradient[k][krow][kcol] {
 
-							// loop through gradient kernel's row and column
-							for krow := 0; krow < gradientKernel.Row; krow++ {
-								for kcol := 0; kcol < gradientKernel.Column; kcol++ {
-									// Check if in padding or is nil
-									if rowIndex+krow == -1 || colIndex+kcol == -1 || gradientKernel.Data[krow][kcol][0] == nil {
-										continue
-									}
-									// Loop through input channel
-									for dep := 0; dep < c.InputSize[2]; dep++ {
-										kernelCiphertexts = append(kernelCiphertexts, gradientKernel.Data[krow][kcol][kernelIndex])
-										inputCiphertexts = append(inputCiphertexts, input[rowIndex+krow][colIndex+kcol][dep])
-									}
-								}
-							}
+					wg.Add(1)
 
-							result := c.utils.InterDotProduct(kernelCiphertexts, inputCiphertexts, true, true, nil)
+					go func(kernelIndex int, krow int, kcol int, kdep int, utils utility.Utils) {
 
-							weightGradientColChannel <- result
+						defer wg.Done()
 
-						}(kernelIndex, rowIndex, col, weightGradientColChannels[currentGradientCol])
+						gradientCiphertexts := []*rlwe.Ciphertext{}
+						inputCiphertexts := []*rlwe.Ciphertext{}
 
-						currentGradientCol++
-					}
+						// Loop through each output position this weight contributed to
+						for row := range gradient {
+							for col := range gradient[row] {
 
-					// Generate array to store weight gradient of each column in a row
-					rowWeightGradient := make([]*rlwe.Ciphertext, c.Kernels[0].Column)
+								inputRow := row*c.Strides[0] + krow - padding
+								inputCol := col*c.Strides[1] + kcol - padding
 
-					// Capture weight gradient of each column and save to array
-					for col := range weightGradientColChannels {
-						rowWeightGradient[col] = <-weightGradientColChannels[col]
-					}
+								// Check if in padding
+								if inputRow < 0 || inputCol < 0 || inputRow >= c.InputSize[0] || inputCol >= c.InputSize[1] {
+									continue
+								}
 
-					// Sent row gradient back to row channel reciever
-					weightGradientRowChannel <- rowWeightGradient
+								gradientCiphertexts = append(gradientCiphertexts, gradient[row][col][kernelIndex])
+								inputCiphertexts = append(inputCiphertexts, input[inputRow][inputCol][kdep])
 
-				}(kernelIndex, row, weightGradientRowChannels[currentGradientRow])
+							}
+						}
 
-				currentGradientRow++
-			}
+						weightGradient[kernelIndex][krow][kcol][kdep] = utils.InterDotProduct(gradientCiphertexts, inputCiphertexts, true, false, nil)
 
-			// Generate array to store weight gradient of each row in a kernel
-			kernelWeightGradient := make([][]*rlwe.Ciphertext, c.Kernels[0].Row)
+					}(k, krow, kcol, kdep, c.utils.CopyWithClonedEval())
 
-			// Capture weight gradient of each row from channels
-			for row := range weightGradientRowChannels {
-				kernelWeightGradient = <-weightGradientKernelChannels[row]
+				}
 			}
-
-			// Sent kernel weight gradient back
-			weightGradientKernelChannel <- kernelWeightGradient
-
-		}(k, weightGradientKernelChannels[k])
-
-	}
-
-	// Capture weight gradient from channels
-	for k := range weightGradientKernelChannels {
-		gradients.WeightGradient[k] = <-weightGradientKernelChannels[k]
+		}
 	}
 
-	// Calculate ∂L/∂A(l-1)
-
-	// Rotate all kernel by 180 degree clockwise
-	rotatedKernels := make([]conv2dKernel, len(c.Kernels))
+	wg.Wait()
 
-	for k := range c.Kernels {
-		rotatedKernels[k] = c.Kernels[k].rotate180()
-	}
+	return weightGradient
 
-	// Prepare loss gradient w.r.t output of this layer (∂L/∂Z(l-1)) for ∂L/∂A(l-1) convolution calculation
-	lossGrad := generate2dKernelFromArray(gradient)
-	lossGrad.dilate(c.Strides)
-	lossGrad.addPadding([]int{c.Kernels[0].Row, c.Kernels[0].Column})
+}
 
-	if hasPrevLayer {
+// Calculate ∂L/∂A(l-1) where ∂L/∂A(l-1)[r][c][d] = Σk Σi Σj ∂L/∂Z[i][j][k] * F[k][r-i*stride+padding][c-j*stride+padding][d]
+// which is the full convolution of dilated ∂L/∂Z with kernels rotated by 180 degree
+func (c Conv2D) inputGradient(gradient [][][]*rlwe.Ciphertext) [][][]*rlwe.Ciphertext {
 
-		gradients.InputGradient = make([][][]*rlwe.Ciphertext, c.InputSize[0])
-		inputGradientChannels := make([]chan [][]*rlwe.Ciphertext, c.InputSize[0])
+	padding := c.paddingSize()
+	inputGradient := make([][][]*rlwe.Ciphertext, c.InputSize[0])
 
-		// Perform convolution between loss wrt Z and filter
-		for row := 0; row < lossGrad.Row-c.Kernels[0].Row; row++ {
+	// Input that doesn't contribute to any output gets zero gradient
+	zeroLevel := gradient[0][0][0].Level()
+	if !c.hasPlainKernels() {
+		zeroLevel--
+	}
 
-			inputGradientChannels[row] = make(chan [][]*rlwe.Ciphertext)
+	var wg sync.WaitGroup
 
-			go func(rowIndex int, inputGradientChannel chan [][]*rlwe.Ciphertext) {
+	for row := range inputGradient {
 
-				inputGradientColumnChannels := make([]chan []*rlwe.Ciphertext, c.InputSize[1])
+		inputGradient[row] = make([][]*rlwe.Ciphertext, c.InputSize[1])
 
-				for col := 0; col < lossGrad.Column-c.Kernels[0].Column; col++ {
+		for col := range inputGradient[row] {
 
-					inputGradientColumnChannels[col] = make(chan []*rlwe.Ciphertext)
+			inputGradient[row][col] = make([]*rlwe.Ciphertext, c.InputSize[2])
 
-					go func(rowIndex int, colIndex int, inputGradientColumnChannel chan []*rlwe.Ciphertext) {
+			wg.Add(1)
 
-						inputGradientDepthChannels := make([]chan *rlwe.Ciphertext, c.InputSize[2])
+			go func(rowIndex int, colIndex int, utils utility.Utils) {
 
-						for d := 0; d < c.InputSize[2]; d++ {
+				defer wg.Done()
 
-							inputGradientDepthChannels[d] = make(chan *rlwe.Ciphertext)
+				for d := 0; d < c.InputSize[2]; d++ {
 
-							go func(rowIndex int, colIndex int, depIndex int, inputGradientDepthChannel chan *rlwe.Ciphertext) {
+					kernelCiphertexts := []*rlwe.Ciphertext{}
+					kernelPlaintexts := []*rlwe.Plaintext{}
+					gradientCiphertexts := []*rlwe.Ciphertext{}
 
-								rotatedKernelsCiphertexts := []*rlwe.Ciphertext{}
-								lossGradientCiphertexts := []*rlwe.Ciphertext{}
+					// Loop through each output position this input contributed to
+					for i := range gradient {
 
-								// Loop through each kernel
-								for k := 0; k < len(c.Kernels); k++ {
-									// Loop through each row in kernel
-									for krow := range c.Kernels[k].Data {
-										// Loop through each column in kernel
-										for kcol := range c.Kernels[k].Data[krow] {
+						krow := rowIndex - i*c.Strides[0] + padding
+						if krow < 0 || krow >= c.Kernels[0].Row {
+							continue
+						}
 
-											// Check if in padding
-											if rotatedKernels[k].Data[krow][kcol][depIndex] != nil && lossGrad.Data[rowIndex+krow][colIndex+kcol][k] != nil {
+						for j := range gradient[i] {
 
-												rotatedKernelsCiphertexts = append(rotatedKernelsCiphertexts, rotatedKernels[k].Data[krow][kcol][depIndex])
-												lossGradientCiphertexts = append(lossGradientCiphertexts, lossGrad.Data[rowIndex+krow][colIndex+kcol][k])
+							kcol := colIndex - j*c.Strides[1] + padding
+							if kcol < 0 || kcol >= c.Kernels[0].Column {
+								continue
+							}
 
-											}
+							for k := range c.Kernels {
 
-										}
-									}
+								if c.hasPlainKernels() {
+									kernelPlaintexts = append(kernelPlaintexts, c.Kernels[k].Plain[krow][kcol][d])
+								} else {
+									kernelCiphertexts = append(kernelCiphertexts, c.Kernels[k].Data[krow][kcol][d])
 								}
+								gradientCiphertexts = append(gradientCiphertexts, gradient[i][j][k])
 
-								// Calculate dot product and send result back through channel
-								inputGradientDepthChannel <- c.utils.InterDotProduct(rotatedKernelsCiphertexts, lossGradientCiphertexts, true, true, nil)
-
-							}(rowIndex, colIndex, d, inputGradientDepthChannels[d])
-
-						}
-
-						// Create array to store input gradient for a column
-						inputColumnGradient := make([]*rlwe.Ciphertext, c.InputSize[2])
-
-						// Capture and store values through channels
-						for d := range inputGradientDepthChannels {
-							inputColumnGradient[d] = <-inputGradientDepthChannels[d]
-						}
-
-						// Bootstrap if necessary
-						if c.btspOutput[1] {
-							c.utils.Bootstrap1dInPlace(inputColumnGradient, true)
+							}
 						}
+					}
 
-						// Send input gradient of this column back through channel
-						inputGradientColumnChannel <- inputColumnGradient
-
-					}(rowIndex, col, inputGradientColumnChannels[col])
+					if len(gradientCiphertexts) == 0 {
+						inputGradient[rowIndex][colIndex][d] = utils.CopyWithClonedEncryptor().EncryptToLevel(utils.GenerateFilledArray(0), zeroLevel)
+					} else if c.hasPlainKernels() {
+						inputGradient[rowIndex][colIndex][d] = utils.InterPlainDotProduct(gradientCiphertexts, kernelPlaintexts, nil)
+					} else {
+						inputGradient[rowIndex][colIndex][d] = utils.InterDotProduct(kernelCiphertexts, gradientCiphertexts, true, false, nil)
+					}
 
 				}
 
-				// Create array to store input gradient for this row
-				inputGradientRow := make([][]*rlwe.Ciphertext, c.InputSize[1])
-
-				// Capture and store input gradient of each column in this row and store it in this row's array
-				for col := range inputGradientColumnChannels {
-					inputGradientRow[col] = <-inputGradientColumnChannels[col]
+				// Bootstrap if necessary
+				if c.btspOutput[1] {
+					utils.Bootstrap1dInPlace(inputGradient[rowIndex][colIndex], true)
 				}
 
-				// Send input gradient of this row back through channel
-				inputGradientChannel <- inputGradientRow
-
-			}(row, inputGradientChannels[row])
+			}(row, col, c.utils.CopyWithClonedEval())
 
 		}
+	}
 
-		// Capture row from go routine channels and save to gradient struct
-		for row := range inputGradientChannels {
-			gradients.InputGradient[row] = <-inputGradientChannels[row]
-		}
+	wg.Wait()
 
-	}
+	return inputGradient
 
-	return gradients
 }
 
 func (c *Conv2D) UpdateGradient(gradient Gradient2d, lr float64) {
 
-	batchAverager := c.utils.EncodePlaintextFromArray(c.utils.GenerateFilledArraySize(lr/float64(c.batchSize), c.batchSize))
+	if c.hasPlainKernels() {
+		return
+	}
+
+	c.optimizer.Step()
+
+	batchAverager := c.utils.EncodePlaintextFromArray(c.utils.GenerateFilledArraySize(c.optimizer.GradientScale(lr)/float64(c.batchSize), c.batchSize))
 
 	// create weight group
 	var wg sync.WaitGroup
@@ -779,14 +741,14 @@ func (c *Conv2D) UpdateGradient(gradient Gradient2d, lr float64) {
 						biasUtils.BootstrapInPlace(gradient.BiasGradient[index])
 					}
 
-					biasUtils.Sub(c.Bias[index], gradient.BiasGradient[index], c.Bias[index])
+					c.optimizer.Update(fmt.Sprintf("bias/%d", index), c.Bias[index], gradient.BiasGradient[index], lr, biasUtils)
 
 				}(utils.ShallowCopy())
 
 			}
 
 			// Update weight
-			c.Kernels[index].updateWeight(gradient.WeightGradient[index], *batchAverager, utils, c.weightLevel)
+			c.Kernels[index].updateWeight(gradient.WeightGradient[index], *batchAverager, utils, c.weightLevel, c.optimizer, fmt.Sprintf("kernel/%d", index), lr)
 
 			biasWg.Wait()
 
@@ -797,13 +759,21 @@ func (c *Conv2D) UpdateGradient(gradient Gradient2d, lr float64) {
 	wg.Wait()
 }
 
-func (c *Conv2D) GetOutputSize() []int {
+// Padding added to each side of input
+func (c Conv2D) paddingSize() int {
 
-	padding := 0
 	if c.Padding {
-		padding = 1
+		return 1
 	}
 
+	return 0
+
+}
+
+func (c *Conv2D) GetOutputSize() []int {
+
+	padding := c.paddingSize()
+
 	// (W1−F+2P)/S+1
 	outputRowSize := int(float64(c.InputSize[0]-c.Kernels[0].Row+(2*padding))/float64(c.Strides[0])) + 1
 
@@ -815,7 +785,18 @@ func (c *Conv2D) GetOutputSize() []int {
 }
 
 func (c Conv2D) IsTrainable() bool {
-	return true
+	return !c.hasPlainKernels()
+}
+
+func (c Conv2D) PlanRotations(planner *utility.RotationPlanner) {
+
+	// Public kernels are never updated
+	if c.hasPlainKernels() {
+		return
+	}
+
+	// Bias gradient summation over batch
+	planner.AddSumElements()
 }
 
 func (c Conv2D) HasActivation() bool {
@@ -867,3 +848,128 @@ func (c Conv2D) GetBackwardActivationLevelConsumption() int {
 func (c *Conv2D) SetWeightLevel(lvl int) {
 	c.weightLevel = lvl
 }
+
+// Replace the update rule used by UpdateGradient. Each layer must have its own optimizer state
+func (c *Conv2D) SetOptimizer(optimizer optimizers.Optimizer) {
+	c.optimizer = optimizer
+}
+
+func (c Conv2D) GetOptimizer() optimizers.Optimizer {
+	return c.optimizer
+}
+
+func (c Conv2D) GetWeightLevel() int {
+	return c.weightLevel
+}
+
+func (c Conv2D) GetBatchSize() int {
+	return c.batchSize
+}
+
+func (c Conv2D) HasBias() bool {
+	return len(c.Bias) != 0 || c.PlainBias != nil
+}
+
+// Save encrypted kernels, bias and training settings of the layer. Public kernels aren't trained so only settings are saved for them
+func (c Conv2D) Checkpoint() (LayerCheckpoint, error) {
+
+	checkpoint := LayerCheckpoint{
+		Type:           "conv2d",
+		WeightLevel:    c.weightLevel,
+		BtspOutput:     append([]bool{}, c.btspOutput...),
+		BtspActivation: append([]bool{}, c.btspActivation...),
+	}
+
+	if c.hasPlainKernels() {
+		return checkpoint, nil
+	}
+
+	weights := []*rlwe.Ciphertext{}
+	for k := range c.Kernels {
+		for r := range c.Kernels[k].Data {
+			for col := range c.Kernels[k].Data[r] {
+				weights = append(weights, c.Kernels[k].Data[r][col]...)
+			}
+		}
+	}
+
+	var err error
+
+	checkpoint.Shape = c.kernelShape()
+	if checkpoint.Weights, err = marshalCiphertexts(weights); err != nil {
+		return LayerCheckpoint{}, err
+	}
+
+	if checkpoint.Bias, err = marshalCiphertexts(c.Bias); err != nil {
+		return LayerCheckpoint{}, err
+	}
+
+	if err = checkpoint.saveOptimizer(c.optimizer); err != nil {
+		return LayerCheckpoint{}, err
+	}
+
+	return checkpoint, nil
+
+}
+
+// Restore kernels, bias and training settings saved by Checkpoint
+func (c *Conv2D) Restore(checkpoint LayerCheckpoint) error {
+
+	if err := checkpoint.checkType("conv2d"); err != nil {
+		return err
+	}
+
+	if !c.hasPlainKernels() {
+
+		if err := checkpoint.checkShape(c.kernelShape()); err != nil {
+			return err
+		}
+
+		weights, err := unmarshalCiphertexts(checkpoint.Weights)
+		if err != nil {
+			return err
+		}
+
+		bias, err := unmarshalCiphertexts(checkpoint.Bias)
+		if err != nil {
+			return err
+		}
+
+
+		shape := c.kernelShape()
+		if len(weights) != shape[0]*shape[1]*shape[2]*shape[3] || len(bias) != len(c.Bias) {
+			return fmt.Errorf("conv2d checkpoint has %d weights and %d biases but layer has %d weights and %d biases", len(weights), len(bias), shape[0]*shape[1]*shape[2]*shape[3], len(c.Bias))
+		}
+
+		if err = checkpoint.restoreOptimizer(c.optimizer); err != nil {
+			return err
+		}
+
+		i := 0
+		for k := range c.Kernels {
+			for r := range c.Kernels[k].Data {
+				for col := range c.Kernels[k].Data[r] {
+					for d := range c.Kernels[k].Data[r][col] {
+						c.Kernels[k].Data[r][col][d] = weights[i]
+						i++
+					}
+				}
+			}
+		}
+
+		copy(c.Bias, bias)
+
+	}
+
+	c.weightLevel = checkpoint.WeightLevel
+	copy(c.btspOutput, checkpoint.BtspOutput)
+	copy(c.btspActivation, checkpoint.BtspActivation)
+
+	return nil
+
+}
+
+// Get shape of kernels as [filter, row, column, depth]
+func (c Conv2D) kernelShape() []int {
+	return []int{len(c.Kernels), c.Kernels[0].Row, c.Kernels[0].Column, c.Kernels[0].Depth}
+}
@@ -5,7 +5,9 @@ import (
 	"math"
 	"testing"
 
+	"github.com/tuneinsight/lattigo/v4/ckks"
 	"github.com/tuneinsight/lattigo/v4/rlwe"
+	"github.com/perm-ai/go-cerebrum/internal/utilitytest"
 	"github.com/perm-ai/go-cerebrum/key"
 	"github.com/perm-ai/go-cerebrum/logger"
 	"github.com/perm-ai/go-cerebrum/utility"
@@ -224,4 +226,58 @@ func TestConv2dForward(t *testing.T) {
 		fmt.Printf("%f %f %f\n", one, two, three)
 	}
 
-}
\ No newline at end of file
+}
+
+func TestPlainConv2dForward(t *testing.T) {
+
+	utils := utilitytest.NewUtils(key.NewParameters(ckks.PN13QP218, nil), math.Pow(2, 30))
+
+	image := [][]float64{{1, 0.5, -1}, {0, 2, 1}, {-0.5, 1, 0.25}}
+	kernels := [][][][]float64{{{{0.5}, {-1}}, {{0.25}, {1}}}, {{{1}, {0}}, {{0}, {-1}}}}
+	bias := []float64{0.5, -0.25}
+
+	input := make([][][]*rlwe.Ciphertext, len(image))
+	for r := range image {
+		input[r] = make([][]*rlwe.Ciphertext, len(image[r]))
+		for c := range image[r] {
+			input[r][c] = []*rlwe.Ciphertext{utils.EncryptToPointer(utils.GenerateFilledArraySize(image[r][c], 1))}
+		}
+	}
+
+	conv, err := NewPlainConv2D(utils, kernels, bias, []int{1, 1}, false, nil, []int{3, 3, 1}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output := conv.Forward(input).Output
+	outputSize := conv.GetOutputSize()
+
+	if outputSize[0] != 2 || outputSize[1] != 2 || outputSize[2] != 2 {
+		t.Fatalf("Expected output size [2 2 2] but got %d", outputSize)
+	}
+
+	for r := 0; r < outputSize[0]; r++ {
+		for c := 0; c < outputSize[1]; c++ {
+			for k := range kernels {
+
+				expected := bias[k]
+				for kr := range kernels[k] {
+					for kc := range kernels[k][kr] {
+						expected += kernels[k][kr][kc][0] * image[r+kr][c+kc]
+					}
+				}
+
+				result := utils.Decrypt(output[r][c][k])[0]
+				if math.Abs(result-expected) > 1e-3 {
+					t.Errorf("Output [%d][%d][%d] expected %f but got %f", r, c, k, expected, result)
+				}
+
+			}
+		}
+	}
+
+	if _, err = NewPlainConv2D(utils, kernels, bias, []int{1, 1}, false, nil, []int{3, 3, 2}, 1); err == nil {
+		t.Error("Kernel depth that doesn't match input channels should return an error")
+	}
+
+}
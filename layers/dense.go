@@ -9,7 +9,9 @@ import (
 	"sync"
 
 	"github.com/perm-ai/go-cerebrum/activations"
+	"github.com/perm-ai/go-cerebrum/key"
 	"github.com/perm-ai/go-cerebrum/logger"
+	"github.com/perm-ai/go-cerebrum/optimizers"
 	"github.com/perm-ai/go-cerebrum/utility"
 	"github.com/tuneinsight/lattigo/v4/ckks"
 	"github.com/tuneinsight/lattigo/v4/rlwe"
@@ -31,6 +33,9 @@ type Dense struct {
 	batchSize      int
 	weightLevel    int
 	lr             float64
+	PlainWeights   [][]*rlwe.Plaintext // Encoded public weights used instead of Weights when set
+	PlainBias      []float64           // Public bias used instead of Bias when PlainWeights is set
+	optimizer      optimizers.Optimizer
 }
 
 func NewDense(utils utility.Utils, inputUnit int, outputUnit int, activation *activations.Activation, useBias bool, batchSize int, lr float64, weightLevel int) Dense {
@@ -93,7 +98,27 @@ func NewDense(utils utility.Utils, inputUnit int, outputUnit int, activation *ac
 
 	wg.Wait()
 
-	return Dense{utils, inputUnit, outputUnit, weights, bias, activation, []bool{false, false}, []bool{false, false}, batchSize, weightLevel, lr}
+	return Dense{utils, inputUnit, outputUnit, weights, bias, activation, []bool{false, false}, []bool{false, false}, batchSize, weightLevel, lr, nil, nil, optimizers.NewSGD()}
+
+}
+
+// Create dense layer with public weights kept as encoded plaintexts for the public model, private data setting.
+// Products with input use MultiplyPlain so they don't need relinearization. The layer isn't trainable but still
+// propagates input gradient so layers before it can be trained. weights[node][input] follows the layout of ExportWeights,
+// bias can be nil for layer without bias
+func NewPlainDense(utils utility.Utils, weights [][]float64, bias []float64, activation *activations.Activation, batchSize int) (Dense, error) {
+
+	if len(weights) == 0 {
+		return Dense{}, fmt.Errorf("weight has no node")
+	}
+
+	d := Dense{utils: utils, InputUnit: len(weights[0]), OutputUnit: len(weights), Activation: activation, btspOutput: []bool{false, false}, btspActivation: []bool{false, false}, batchSize: batchSize, weightLevel: utils.Params.MaxLevel()}
+
+	if err := d.SetPlainWeights(weights, bias); err != nil {
+		return Dense{}, err
+	}
+
+	return d, nil
 
 }
 
@@ -106,12 +131,18 @@ func (d Dense) Forward(input []*rlwe.Ciphertext) Output1d {
 
 	dotProductCounter := logger.NewOperationsCounter(fmt.Sprintf("Forward propagating (%d) multiplying", d.InputUnit), d.InputUnit*d.OutputUnit)
 
-	for node := range d.Weights {
+	for node := 0; node < d.OutputUnit; node++ {
 
 		wg.Add(1)
 
 		go func(nodeIndex int, utils utility.Utils) {
 			defer wg.Done()
+
+			if d.PlainWeights != nil {
+				output[nodeIndex] = d.plainNodeOutput(input, nodeIndex, utils, &dotProductCounter)
+				return
+			}
+
 			output[nodeIndex] = utils.InterDotProduct(input, d.Weights[nodeIndex], !d.btspOutput[0], true, &dotProductCounter)
 
 			if len(d.Bias) != 0 {
@@ -154,6 +185,76 @@ func (d Dense) Forward(input []*rlwe.Ciphertext) Output1d {
 
 }
 
+// Evaluate the layer for inference only. Only the final (activated) output is returned and ciphertexts are
+// only bootstrapped when their level isn't enough for the next operation. Input of the caller isn't modified
+func (d Dense) Predict(input []*rlwe.Ciphertext) []*rlwe.Ciphertext {
+
+	// Bootstrap copies of the inputs that are too low so the caller's ciphertexts keep their level
+	minLevel := d.GetForwardLevelConsumption()
+	input = append([]*rlwe.Ciphertext{}, input...)
+	for i := range input {
+		if input[i].Level() < minLevel {
+			input[i] = input[i].CopyNew()
+		}
+	}
+
+	d.utils.Bootstrap1dIfBelow(input, minLevel)
+
+	output := make([]*rlwe.Ciphertext, d.OutputUnit)
+
+	var wg sync.WaitGroup
+
+	for node := 0; node < d.OutputUnit; node++ {
+
+		wg.Add(1)
+
+		go func(nodeIndex int, utils utility.Utils) {
+
+			defer wg.Done()
+
+			if d.PlainWeights != nil {
+
+				output[nodeIndex] = d.plainNodeOutput(input, nodeIndex, utils, nil)
+
+			} else {
+
+				output[nodeIndex] = utils.InterDotProduct(input, d.Weights[nodeIndex], true, false, nil)
+
+				if len(d.Bias) != 0 && d.Bias[nodeIndex] != nil {
+					utils.Add(output[nodeIndex], d.Bias[nodeIndex], output[nodeIndex])
+				}
+
+			}
+
+		}(node, d.utils.CopyWithClonedEval())
+
+	}
+
+	wg.Wait()
+
+	if d.Activation == nil {
+		return output
+	}
+
+	d.utils.Bootstrap1dIfBelow(output, d.GetForwardActivationLevelConsumption())
+
+	return (*d.Activation).Forward(output, d.batchSize)
+
+}
+
+// Calculate output of node from public weights, rescaling once after the whole dot product
+func (d Dense) plainNodeOutput(input []*rlwe.Ciphertext, node int, utils utility.Utils, counter *logger.OperationsCounter) *rlwe.Ciphertext {
+
+	output := utils.InterPlainDotProduct(input, d.PlainWeights[node], counter)
+
+	if len(d.PlainBias) != 0 {
+		utils.Evaluator.AddConst(output, d.PlainBias[node], output)
+	}
+
+	return output
+
+}
+
 // input is A(l-1) - activation of previous layer
 // output is Z(l) - output of this layer
 // gradient is ∂L/∂A(l) - influence that the activation of this layer has on the next layer
@@ -188,7 +289,7 @@ func (d *Dense) Backward(input []*rlwe.Ciphertext, output []*rlwe.Ciphertext, gr
 
 			var wg sync.WaitGroup
 
-			for b := range d.Bias {
+			for b := range gradient {
 
 				wg.Add(1)
 
@@ -215,9 +316,21 @@ func (d *Dense) Backward(input []*rlwe.Ciphertext, output []*rlwe.Ciphertext, gr
 		gradients.BiasGradient = gradient
 	}
 
-	gradients.WeightGradient = d.utils.InterOuter(gradients.BiasGradient, input, true)
 	gradients.InputGradient = make([]*rlwe.Ciphertext, d.InputUnit)
 
+	// Public weights aren't trained, only ∂L/∂A(l-1) is needed
+	if d.PlainWeights != nil {
+
+		if hasPrevLayer {
+			d.plainInputGradient(gradients.BiasGradient, gradients.InputGradient)
+		}
+
+		return gradients
+
+	}
+
+	gradients.WeightGradient = d.utils.InterOuter(gradients.BiasGradient, input, true)
+
 	if hasPrevLayer {
 
 		var inputWg sync.WaitGroup
@@ -243,8 +356,6 @@ func (d *Dense) Backward(input []*rlwe.Ciphertext, output []*rlwe.Ciphertext, gr
 			btpTimer.LogTimeTakenSecond()
 		}
 
-		fmt.Printf("Backward (%d) input gradient: %f\n", d.InputUnit, d.utils.Decrypt(gradients.InputGradient[4])[0:5])
-
 	}
 
 	fmt.Printf("Backward (%d) weight level: %d \tbias level: %d\n", d.InputUnit, gradients.WeightGradient[0][0].Level(), gradients.BiasGradient[0].Level())
@@ -253,9 +364,47 @@ func (d *Dense) Backward(input []*rlwe.Ciphertext, output []*rlwe.Ciphertext, gr
 
 }
 
+// Calculate ∂L/∂A(l-1) from public weights and store it in inputGradient
+func (d *Dense) plainInputGradient(outputGradient []*rlwe.Ciphertext, inputGradient []*rlwe.Ciphertext) {
+
+	var wg sync.WaitGroup
+
+	for xi := range inputGradient {
+
+		wg.Add(1)
+
+		go func(xIndex int, utils utility.Utils) {
+
+			defer wg.Done()
+
+			column := make([]*rlwe.Plaintext, d.OutputUnit)
+			for node := range column {
+				column[node] = d.PlainWeights[node][xIndex]
+			}
+
+			inputGradient[xIndex] = utils.InterPlainDotProduct(outputGradient, column, nil)
+
+		}(xi, d.utils.CopyWithClonedEval())
+
+	}
+
+	wg.Wait()
+
+	if d.btspOutput[1] {
+		d.utils.Bootstrap1dInPlace(inputGradient, true)
+	}
+
+}
+
 func (d *Dense) UpdateGradient(gradient Gradient1d, lr float64) {
 
-	avgScale := lr / float64(d.batchSize)
+	if d.PlainWeights != nil {
+		return
+	}
+
+	d.optimizer.Step()
+
+	avgScale := d.optimizer.GradientScale(lr) / float64(d.batchSize)
 	batchAverager := d.utils.EncodePlaintextFromArray(d.utils.GenerateFilledArraySize(avgScale, d.batchSize))
 
 	bootstrapGradient := gradient.WeightGradient[0][0].Level()-1 < d.weightLevel
@@ -338,7 +487,7 @@ func (d *Dense) UpdateGradient(gradient Gradient1d, lr float64) {
 						averagedLrBias := biasUtils.MultiplyPlainNew(gradient.BiasGradient[nodeIndex], batchAverager, false, false)
 						biasUtils.Evaluator.Rescale(averagedLrBias, d.utils.Params.NewScale(biasUtils.Scale/2), averagedLrBias)
 
-						biasUtils.Sub(d.Bias[nodeIndex], averagedLrBias, d.Bias[nodeIndex])
+						d.optimizer.Update(fmt.Sprintf("bias/%d", nodeIndex), d.Bias[nodeIndex], averagedLrBias, lr, biasUtils)
 						counter.Increment()
 					}
 
@@ -421,8 +570,8 @@ func (d *Dense) UpdateGradient(gradient Gradient1d, lr float64) {
 						weightUtils.MultiplyPlain(gradient.WeightGradient[nodeIndex][weightIndex], batchAverager, gradient.WeightGradient[nodeIndex][weightIndex], false, false)
 						weightUtils.Evaluator.Rescale(gradient.WeightGradient[nodeIndex][weightIndex], rlwe.NewScale(weightUtils.Scale/2), gradient.WeightGradient[nodeIndex][weightIndex])
 
-						// Perform SGD
-						weightUtils.Sub(d.Weights[nodeIndex][weightIndex], gradient.WeightGradient[nodeIndex][weightIndex], d.Weights[nodeIndex][weightIndex])
+						// Apply update rule of optimizer
+						d.optimizer.Update(fmt.Sprintf("weight/%d/%d", nodeIndex, weightIndex), d.Weights[nodeIndex][weightIndex], gradient.WeightGradient[nodeIndex][weightIndex], lr, weightUtils)
 
 						counter.Increment()
 
@@ -505,7 +654,7 @@ func (d *Dense) UpdateGradient(gradient Gradient1d, lr float64) {
 						biasGradient := biasUtils.MultiplyPlainNew(cts[len(cts)-1], filter, rescale, true)
 						utils.Rotate(biasGradient, nodeIndex)
 						biasUtils.FillCiphertextInPlace(biasGradient, d.batchSize)
-						biasUtils.Sub(d.Bias[nodeIndex], biasGradient, d.Bias[nodeIndex])
+						d.optimizer.Update(fmt.Sprintf("bias/%d", nodeIndex), d.Bias[nodeIndex], biasGradient, lr, biasUtils)
 
 					}(utils.CopyWithClonedEval())
 
@@ -541,7 +690,7 @@ func (d *Dense) UpdateGradient(gradient Gradient1d, lr float64) {
 						// Isolate weight grqadient
 						weightGradient := weightUtils.MultiplyPlainNew(cts[ct], filter, rescale, false)
 						weightUtils.SumElementsInPlace(weightGradient)
-						weightUtils.Sub(d.Weights[nodeIndex][weightIndex], weightGradient, d.Weights[nodeIndex][weightIndex])
+						d.optimizer.Update(fmt.Sprintf("weight/%d/%d", nodeIndex, weightIndex), d.Weights[nodeIndex][weightIndex], weightGradient, lr, weightUtils)
 
 					}(w, utils.CopyWithClonedEval())
 
@@ -566,7 +715,25 @@ func (d Dense) GetOutputSize() int {
 }
 
 func (d Dense) IsTrainable() bool {
-	return true
+	return d.PlainWeights == nil
+}
+
+func (d Dense) PlanRotations(planner *utility.RotationPlanner) {
+
+	// Public weights are never updated
+	if d.PlainWeights != nil {
+		return
+	}
+
+	// Gradient summation over batch
+	planner.AddSumElements()
+
+	// Bias gradient alignment and refill when updating from packed gradients
+	for node := 0; node < d.OutputUnit; node++ {
+		planner.AddRotation(node)
+	}
+	planner.AddFillCiphertext(d.batchSize)
+
 }
 
 func (d Dense) HasActivation() bool {
@@ -619,23 +786,93 @@ func (d *Dense) SetWeightLevel(lvl int) {
 	d.weightLevel = lvl
 }
 
+// Replace the update rule used by UpdateGradient. Each layer must have its own optimizer state
+func (d *Dense) SetOptimizer(optimizer optimizers.Optimizer) {
+	d.optimizer = optimizer
+}
+
+func (d Dense) GetOptimizer() optimizers.Optimizer {
+	return d.optimizer
+}
+
 func (d *Dense) GetWeightLevel() int {
 	return d.weightLevel
 }
 
+func (d Dense) GetBatchSize() int {
+	return d.batchSize
+}
+
+func (d Dense) GetLearningRate() float64 {
+	return d.lr
+}
+
+func (d Dense) HasBias() bool {
+	if d.PlainWeights != nil {
+		return d.PlainBias != nil
+	}
+	return len(d.Bias) != 0 && d.Bias[0] != nil
+}
+
 type denseWeight struct {
 	Weight [][]float64
 	Bias   []float64
 }
 
-func (d *Dense) LoadWeights(filename string, weightLevel int) {
+func readDenseWeights(filename string) (denseWeight, error) {
 
-	jsonFile, _ := os.Open(filename)
+	var data denseWeight
+
+	jsonFile, err := os.Open(filename)
+	if err != nil {
+		return data, err
+	}
 	defer jsonFile.Close()
-	file, _ := ioutil.ReadAll(jsonFile)
 
-	var data denseWeight
-	json.Unmarshal([]byte(file), &data)
+	file, err := ioutil.ReadAll(jsonFile)
+	if err != nil {
+		return data, err
+	}
+
+	if err = json.Unmarshal([]byte(file), &data); err != nil {
+		return data, &key.CorruptFileError{Location: filename, Err: err}
+	}
+
+	return data, nil
+
+}
+
+func (d Dense) checkWeightShape(weights [][]float64, bias []float64) error {
+
+	if len(weights) != d.OutputUnit {
+		return fmt.Errorf("weight has %d nodes but layer has %d output units", len(weights), d.OutputUnit)
+	}
+
+	if bias != nil && len(bias) != d.OutputUnit {
+		return fmt.Errorf("bias has %d nodes but layer has %d output units", len(bias), d.OutputUnit)
+	}
+
+	for node := range weights {
+		if len(weights[node]) != d.InputUnit {
+			return fmt.Errorf("weight of node %d has %d weights but layer has %d input units", node, len(weights[node]), d.InputUnit)
+		}
+	}
+
+	return nil
+
+}
+
+// Load weights exported by ExportWeights and encrypt them at weightLevel. Returns *key.CorruptFileError if the file can't be decoded
+func (d *Dense) LoadWeights(filename string, weightLevel int) error {
+
+	data, err := readDenseWeights(filename)
+	if err != nil {
+		return err
+	}
+
+	if err = d.checkWeightShape(data.Weight, data.Bias); err != nil {
+		return fmt.Errorf("weight file '%s': %w", filename, err)
+	}
 
 	counter := logger.NewOperationsCounter("Load weight", (d.InputUnit*d.OutputUnit)+d.OutputUnit)
 
@@ -663,7 +900,9 @@ func (d *Dense) LoadWeights(filename string, weightLevel int) {
 
 			}
 
-			d.Bias[nodeIndex] = nodeUtils.EncryptToLevel(nodeUtils.GenerateFilledArraySize(data.Bias[nodeIndex], d.batchSize), weightLevel)
+			if data.Bias != nil {
+				d.Bias[nodeIndex] = nodeUtils.EncryptToLevel(nodeUtils.GenerateFilledArraySize(data.Bias[nodeIndex], d.batchSize), weightLevel)
+			}
 			counter.Increment()
 
 			weightWg.Wait()
@@ -674,9 +913,72 @@ func (d *Dense) LoadWeights(filename string, weightLevel int) {
 
 	wg.Wait()
 
+	return nil
+
 }
 
-func (d *Dense) ExportWeights(filename string) {
+// Use public weights and bias encoded as plaintexts instead of encrypted weights. The layer stops being trainable.
+// weights[node][input] follows the layout of ExportWeights
+func (d *Dense) SetPlainWeights(weights [][]float64, bias []float64) error {
+
+	if err := d.checkWeightShape(weights, bias); err != nil {
+		return err
+	}
+
+	encoded := make([][]*rlwe.Plaintext, d.OutputUnit)
+
+	var wg sync.WaitGroup
+
+	for node := range weights {
+
+		encoded[node] = make([]*rlwe.Plaintext, d.InputUnit)
+
+		wg.Add(1)
+
+		go func(nodeIndex int, utils utility.Utils) {
+			defer wg.Done()
+			for w := range weights[nodeIndex] {
+				encoded[nodeIndex][w] = utils.EncodePlaintextFromArray(utils.GenerateFilledArraySize(weights[nodeIndex][w], d.batchSize))
+			}
+		}(node, d.utils.CopyWithClonedEncoder())
+
+	}
+
+	wg.Wait()
+
+	d.PlainWeights = encoded
+	d.PlainBias = bias
+
+	return nil
+
+}
+
+// Load weights exported by ExportWeights as public plaintext weights. Returns *key.CorruptFileError if the file can't be decoded
+func (d *Dense) LoadPlainWeights(filename string) error {
+
+	data, err := readDenseWeights(filename)
+	if err != nil {
+		return err
+	}
+
+	if err = d.SetPlainWeights(data.Weight, data.Bias); err != nil {
+		return fmt.Errorf("weight file '%s': %w", filename, err)
+	}
+
+	return nil
+
+}
+
+// Decrypt weights and bias and save them as json. Requires secret key in utils' keychain
+func (d *Dense) ExportWeights(filename string) error {
+
+	if d.PlainWeights != nil {
+		return d.exportPlainWeights(filename)
+	}
+
+	if d.utils.KeyChain.SecretKey == nil {
+		return &key.MissingKeyError{Key: "secret key", Location: "layer's keychain"}
+	}
 
 	plainWeights := make([][]float64, len(d.Weights))
 
@@ -703,8 +1005,124 @@ func (d *Dense) ExportWeights(filename string) {
 
 	weight := denseWeight{plainWeights, bias}
 
-	file, _ := json.MarshalIndent(weight, "", " ")
+	file, err := json.MarshalIndent(weight, "", " ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filename, file, 0644)
+
+}
+
+// Decode public weights and save them as json
+func (d *Dense) exportPlainWeights(filename string) error {
+
+	plainWeights := make([][]float64, d.OutputUnit)
+
+	for node := range d.PlainWeights {
+		plainWeights[node] = make([]float64, d.InputUnit)
+		for i := range d.PlainWeights[node] {
+			plainWeights[node][i] = d.utils.Decode(d.PlainWeights[node][i])[0]
+		}
+	}
+
+	bias := d.PlainBias
+	if bias == nil {
+		bias = make([]float64, d.OutputUnit)
+	}
+
+	file, err := json.MarshalIndent(denseWeight{plainWeights, bias}, "", " ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filename, file, 0644)
+
+}
+
+// Save encrypted weights, bias and training settings of the layer. Public weights aren't trained so only settings are saved for them
+func (d Dense) Checkpoint() (LayerCheckpoint, error) {
+
+	checkpoint := LayerCheckpoint{
+		Type:           "dense",
+		WeightLevel:    d.weightLevel,
+		BtspOutput:     append([]bool{}, d.btspOutput...),
+		BtspActivation: append([]bool{}, d.btspActivation...),
+		LearningRate:   d.lr,
+	}
+
+	if d.PlainWeights != nil {
+		return checkpoint, nil
+	}
+
+	weights := make([]*rlwe.Ciphertext, 0, d.InputUnit*d.OutputUnit)
+	for node := range d.Weights {
+		weights = append(weights, d.Weights[node]...)
+	}
+
+	var err error
+
+	checkpoint.Shape = []int{d.OutputUnit, d.InputUnit}
+	if checkpoint.Weights, err = marshalCiphertexts(weights); err != nil {
+		return LayerCheckpoint{}, err
+	}
+
+	if checkpoint.Bias, err = marshalCiphertexts(d.Bias); err != nil {
+		return LayerCheckpoint{}, err
+	}
+
+	if err = checkpoint.saveOptimizer(d.optimizer); err != nil {
+		return LayerCheckpoint{}, err
+	}
+
+	return checkpoint, nil
+
+}
+
+// Restore weights, bias and training settings saved by Checkpoint
+func (d *Dense) Restore(checkpoint LayerCheckpoint) error {
+
+	if err := checkpoint.checkType("dense"); err != nil {
+		return err
+	}
+
+	if d.PlainWeights == nil {
+
+		if err := checkpoint.checkShape([]int{d.OutputUnit, d.InputUnit}); err != nil {
+			return err
+		}
+
+		weights, err := unmarshalCiphertexts(checkpoint.Weights)
+		if err != nil {
+			return err
+		}
+
+		bias, err := unmarshalCiphertexts(checkpoint.Bias)
+		if err != nil {
+			return err
+		}
+
+		if len(weights) != d.OutputUnit*d.InputUnit || len(bias) != d.OutputUnit {
+			return fmt.Errorf("dense checkpoint has %d weights and %d biases but layer has %d weights and %d biases", len(weights), len(bias), d.OutputUnit*d.InputUnit, d.OutputUnit)
+		}
+
+		if err = checkpoint.restoreOptimizer(d.optimizer); err != nil {
+			return err
+		}
+
+		for node := range d.Weights {
+			copy(d.Weights[node], weights[node*d.InputUnit:(node+1)*d.InputUnit])
+		}
+
+		d.Bias = bias
+
+	}
+
+	d.weightLevel = checkpoint.WeightLevel
+	d.lr = checkpoint.LearningRate
+	copy(d.btspOutput, checkpoint.BtspOutput)
+	copy(d.btspActivation, checkpoint.BtspActivation)
 
-	_ = ioutil.WriteFile(filename, file, 0644)
+	return nil
 
 }
@@ -0,0 +1,174 @@
+package layers
+
+import (
+	"encoding/json"
+	"math"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/perm-ai/go-cerebrum/activations"
+	"github.com/perm-ai/go-cerebrum/internal/utilitytest"
+	"github.com/perm-ai/go-cerebrum/key"
+	"github.com/tuneinsight/lattigo/v4/ckks"
+	"github.com/tuneinsight/lattigo/v4/rlwe"
+)
+
+func TestDensePredict(t *testing.T) {
+
+	utils := utilitytest.NewUtils(key.NewParameters(ckks.PN13QP218, nil), math.Pow(2, 30))
+
+	var sigmoid activations.Activation = activations.Sigmoid{U: utils}
+
+	batchSize := 4
+	inputs := [][]float64{{0.5, -1, 0.25, 1}, {1, 0.5, -0.5, 0}, {-0.25, 0.75, 1, -1}}
+	weights := [][]float64{{0.2, -0.4, 0.6}, {-0.3, 0.1, 0.5}}
+	bias := []float64{0.1, -0.2}
+
+	expected := make([][]float64, len(weights))
+	for node := range weights {
+		expected[node] = make([]float64, batchSize)
+		for i := 0; i < batchSize; i++ {
+			linear := bias[node]
+			for w := range weights[node] {
+				linear += weights[node][w] * inputs[w][i]
+			}
+			expected[node][i] = 0.5 + 0.197*linear - 0.004*math.Pow(linear, 3)
+		}
+	}
+
+	encryptInput := func() []*rlwe.Ciphertext {
+		input := make([]*rlwe.Ciphertext, len(inputs))
+		for i := range inputs {
+			input[i] = utils.EncryptToPointer(inputs[i])
+		}
+		return input
+	}
+
+	validate := func(name string, output []*rlwe.Ciphertext) {
+
+		if len(output) != len(weights) {
+			t.Fatalf("%s: expected %d outputs but got %d", name, len(weights), len(output))
+		}
+
+		for node := range output {
+			decrypted := utils.Decrypt(output[node])
+			for i := 0; i < batchSize; i++ {
+				if math.Abs(decrypted[i]-expected[node][i]) > 1e-3 {
+					t.Errorf("%s: node %d data %d expected %f but got %f", name, node, i, expected[node][i], decrypted[i])
+				}
+			}
+		}
+
+	}
+
+	// Plaintext weights
+	plainDense := NewDense(utils, 3, 2, &sigmoid, true, batchSize, 0.1, 4)
+	if err := plainDense.SetPlainWeights(weights, bias); err != nil {
+		t.Fatal(err)
+	}
+	validate("plaintext weights", plainDense.Predict(encryptInput()))
+
+	// Encrypted weights loaded from exported file
+	file, _ := json.Marshal(denseWeight{weights, bias})
+	weightPath := path.Join(t.TempDir(), "layer_0.json")
+	if err := os.WriteFile(weightPath, file, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	encryptedDense := NewDense(utils, 3, 2, &sigmoid, true, batchSize, 0.1, 4)
+	if err := encryptedDense.LoadWeights(weightPath, 4); err != nil {
+		t.Fatal(err)
+	}
+	validate("encrypted weights", encryptedDense.Predict(encryptInput()))
+
+	if err := plainDense.SetPlainWeights(weights[:1], bias[:1]); err == nil {
+		t.Error("Setting plaintext weights with wrong shape should return an error")
+	}
+
+}
+
+func TestPlainDense(t *testing.T) {
+
+	utils := utilitytest.NewUtils(key.NewParameters(ckks.PN13QP218, nil), math.Pow(2, 30))
+
+	batchSize := 2
+	weights := [][]float64{{0.2, -0.4, 0.6}, {-0.3, 0.1, 0.5}}
+	bias := []float64{0.1, -0.2}
+
+	dense, err := NewPlainDense(utils, weights, bias, nil, batchSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if dense.IsTrainable() {
+		t.Error("Dense layer with public weights shouldn't be trainable")
+	}
+
+	inputs := [][]float64{{0.5, -1}, {1, 0.5}, {-0.25, 0.75}}
+	input := make([]*rlwe.Ciphertext, len(inputs))
+	for i := range inputs {
+		input[i] = utils.EncryptToPointer(inputs[i])
+	}
+
+	output := dense.Forward(input).Output
+
+	for node := range weights {
+
+		if output[node].Level() != input[0].Level()-1 {
+			t.Errorf("Forward with public weights should consume 1 level but consumed %d", input[0].Level()-output[node].Level())
+		}
+
+		decrypted := utils.Decrypt(output[node])
+		for i := 0; i < batchSize; i++ {
+			expected := bias[node]
+			for w := range weights[node] {
+				expected += weights[node][w] * inputs[w][i]
+			}
+			if math.Abs(decrypted[i]-expected) > 1e-3 {
+				t.Errorf("Forward of node %d data %d expected %f but got %f", node, i, expected, decrypted[i])
+			}
+		}
+
+	}
+
+	outputGradient := []float64{0.5, -1}
+	gradient := []*rlwe.Ciphertext{
+		utils.EncryptToPointer(utils.GenerateFilledArraySize(outputGradient[0], batchSize)),
+		utils.EncryptToPointer(utils.GenerateFilledArraySize(outputGradient[1], batchSize)),
+	}
+
+	gradients := dense.Backward(input, output, gradient, true)
+
+	if gradients.WeightGradient != nil {
+		t.Error("Weight gradient of public weights shouldn't be calculated")
+	}
+
+	for x := range inputs {
+		expected := outputGradient[0]*weights[0][x] + outputGradient[1]*weights[1][x]
+		decrypted := utils.Decrypt(gradients.InputGradient[x])
+		if math.Abs(decrypted[0]-expected) > 1e-3 {
+			t.Errorf("Input gradient %d expected %f but got %f", x, expected, decrypted[0])
+		}
+	}
+
+	// Public weights export without secret key
+	weightPath := path.Join(t.TempDir(), "layer_0.json")
+	if err := dense.ExportWeights(weightPath); err != nil {
+		t.Fatal(err)
+	}
+
+	exported, err := readDenseWeights(weightPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for node := range weights {
+		for w := range weights[node] {
+			if math.Abs(exported.Weight[node][w]-weights[node][w]) > 1e-6 {
+				t.Errorf("Exported weight [%d][%d] expected %f but got %f", node, w, weights[node][w], exported.Weight[node][w])
+			}
+		}
+	}
+
+}
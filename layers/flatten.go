@@ -31,7 +31,7 @@ func (f Flatten2D) Forward(input [][][]*rlwe.Ciphertext) Output1d {
 	for r := range input{
 		for c := range input[r]{
 			for d := range input[r][c]{
-				output[(r * f.InputSize[0]) + (c * f.InputSize[1]) + (d * f.InputSize[2])] = input[r][c][d]
+				output[(((r * f.InputSize[1]) + c) * f.InputSize[2]) + d] = input[r][c][d]
 			}
 		}
 	}
@@ -49,7 +49,7 @@ func (f Flatten2D) Backward(output []*rlwe.Ciphertext) Gradient2d{
 		for c := range gradient[r]{
 			gradient[r][c] = make([]*rlwe.Ciphertext, f.InputSize[2])
 			for d := range gradient[r][c]{
-				gradient[r][c][d] = output[(r * f.InputSize[0]) + (c * f.InputSize[1]) + (d * f.InputSize[2])]
+				gradient[r][c][d] = output[(((r * f.InputSize[1]) + c) * f.InputSize[2]) + d]
 			}
 		}
 	}
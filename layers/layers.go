@@ -1,13 +1,17 @@
 package layers
 
-import "github.com/tuneinsight/lattigo/v4/rlwe"
+import (
+	"github.com/perm-ai/go-cerebrum/optimizers"
+	"github.com/perm-ai/go-cerebrum/utility"
+	"github.com/tuneinsight/lattigo/v4/rlwe"
+)
 
 //=================================================
 //		   		2 DIMENTIONAL GRADIENT
 //=================================================
 type Gradient2d struct {
 	BiasGradient   []*rlwe.Ciphertext
-	WeightGradient [][][]*rlwe.Ciphertext
+	WeightGradient [][][][]*rlwe.Ciphertext // [kernel][row][column][depth]
 	InputGradient  [][][]*rlwe.Ciphertext
 }
 
@@ -43,6 +47,7 @@ type Output1d struct {
 
 type Layer1D interface {
 	Forward(input []*rlwe.Ciphertext) Output1d
+	Predict(input []*rlwe.Ciphertext) []*rlwe.Ciphertext // Inference only forward pass returning the final output
 	Backward(input []*rlwe.Ciphertext, output []*rlwe.Ciphertext, gradient []*rlwe.Ciphertext, hasPrevLayer bool) Gradient1d
 	UpdateGradient(gradient Gradient1d, lr float64)
 
@@ -57,8 +62,14 @@ type Layer1D interface {
 	GetForwardActivationLevelConsumption() int
 	GetBackwardActivationLevelConsumption() int
 	SetWeightLevel(lvl int)
+	SetOptimizer(optimizer optimizers.Optimizer) // Replace update rule used by UpdateGradient
+	PlanRotations(planner *utility.RotationPlanner) // Add rotations performed by the layer to planner
 
-	ExportWeights(filename string)
+	ExportWeights(filename string) error
+	LoadPlainWeights(filename string) error // Load weights exported by ExportWeights as plaintext weights used by Predict
+
+	Checkpoint() (LayerCheckpoint, error) // Save encrypted training state of the layer
+	Restore(checkpoint LayerCheckpoint) error
 }
 
 //=================================================
@@ -67,6 +78,7 @@ type Layer1D interface {
 
 type Layer2D interface {
 	Forward(input [][][]*rlwe.Ciphertext) Output2d
+	Predict(input [][][]*rlwe.Ciphertext) [][][]*rlwe.Ciphertext // Inference only forward pass returning the final output
 	Backward(input [][][]*rlwe.Ciphertext, output [][][]*rlwe.Ciphertext, gradient [][][]*rlwe.Ciphertext, hasPrevLayer bool) Gradient2d
 	UpdateGradient(gradient Gradient2d, lr float64)
 	GetOutputSize() []int
@@ -80,4 +92,43 @@ type Layer2D interface {
 	GetForwardActivationLevelConsumption() int
 	GetBackwardActivationLevelConsumption() int
 	SetWeightLevel(lvl int)
+	SetOptimizer(optimizer optimizers.Optimizer) // Replace update rule used by UpdateGradient
+	PlanRotations(planner *utility.RotationPlanner) // Add rotations performed by the layer to planner
+
+	Checkpoint() (LayerCheckpoint, error) // Save encrypted training state of the layer
+	Restore(checkpoint LayerCheckpoint) error
 }
+
+// Get the lowest level of ciphertexts in a 3 dimentional array
+func minLevel3d(ct [][][]*rlwe.Ciphertext) int {
+
+	level := -1
+
+	for r := range ct {
+		for c := range ct[r] {
+			for d := range ct[r][c] {
+				if level == -1 || ct[r][c][d].Level() < level {
+					level = ct[r][c][d].Level()
+				}
+			}
+		}
+	}
+
+	return level
+
+}
+
+// Get the lowest level of ciphertexts in an array
+func minLevel1d(ct []*rlwe.Ciphertext) int {
+
+	level := -1
+
+	for i := range ct {
+		if level == -1 || ct[i].Level() < level {
+			level = ct[i].Level()
+		}
+	}
+
+	return level
+
+}
\ No newline at end of file
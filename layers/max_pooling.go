@@ -0,0 +1,404 @@
+package layers
+
+import (
+	"math"
+	"math/bits"
+	"sync"
+
+	"github.com/perm-ai/go-cerebrum/optimizers"
+	"github.com/perm-ai/go-cerebrum/utility"
+	"github.com/tuneinsight/lattigo/v4/rlwe"
+)
+
+// Degree of the polynomial approximating |x| / 2, which consumes 4 levels and 1 more if 1 / Bound isn't an integer
+const maxPoolingDegree = 14
+
+// Level of bootstrapped ciphertexts, same as the level the model plans bootstrapping with
+const maxPoolingBootstrapLevel = 9
+
+// MaxPooling2D takes the maximum of each pool with a tournament of pairwise comparisons where
+// max(a, b) = (a + b) / 2 + |a - b| / 2 and |x| is approximated by a polynomial fitted over [-Bound, Bound].
+// Forward keeps the derivative of each comparison so Backward routes the gradient to the approximated argmax.
+// Rounds are split into stages that each fit in the levels of a bootstrapped ciphertext and values are bootstrapped
+// before a stage when they don't have enough levels for it
+type MaxPooling2D struct {
+	utils      utility.Utils
+	InputSize  []int
+	Size       []int
+	Strides    []int
+	Bound      float64 // Difference between any two values in a pool must lie in [-Bound, Bound]
+	halfAbs    utility.Polynomial
+	route      utility.Polynomial
+	routes     *maxPoolingRoutes
+	btspOutput []bool
+}
+
+// Share of gradient routed to the first value of each comparison, [row][column][depth][round][pair], from the last Forward
+type maxPoolingRoutes struct {
+	weights [][][][][]*rlwe.Ciphertext
+}
+
+func NewMaxPoolingLayer(utils utility.Utils, inputSize []int, poolingSize []int, strides []int, bound float64) MaxPooling2D {
+
+	halfAbs := utility.FitChebyshev(func(x float64) float64 { return math.Abs(x) / 2 }, -bound, bound, maxPoolingDegree, utils)
+
+	// d max(a, b) / da = 1/2 + d(|a - b| / 2) / d(a - b)
+	route := halfAbs.Derivative()
+	route.Coeff[0].Value += 0.5
+
+	// Shift so equal values and empty slots stay exact
+	halfAbs.Coeff[0].Value -= halfAbs.EvaluatePlain(0)
+
+	return MaxPooling2D{utils, inputSize, poolingSize, strides, bound, halfAbs, route, &maxPoolingRoutes{}, []bool{false, false}}
+
+}
+
+func (p MaxPooling2D) GetOutputSize() []int {
+
+	row := int(float64(p.InputSize[0]-p.Size[0])/float64(p.Strides[0])) + 1
+	column := int(float64(p.InputSize[1]-p.Size[1])/float64(p.Strides[1])) + 1
+
+	return []int{row, column, p.InputSize[2]}
+
+}
+
+// Number of tournament rounds needed to find the maximum of a pool
+func (p MaxPooling2D) rounds() int {
+	return bits.Len(uint(p.Size[0]*p.Size[1] - 1))
+}
+
+// Levels consumed by each tournament round
+func (p MaxPooling2D) roundLevel() int {
+	return int(math.Max(float64(p.halfAbs.GetLevelConsumption()), 1))
+}
+
+// Number of tournament rounds in a stage, leaving at least 1 level for the next layer
+func (p MaxPooling2D) stageRounds() int {
+	return int(math.Max(float64((maxPoolingBootstrapLevel-1)/p.roundLevel()), 1))
+}
+
+func (p MaxPooling2D) Forward(input [][][]*rlwe.Ciphertext) Output2d {
+	return Output2d{Output: p.pool(input, true)}
+}
+
+// Evaluate the layer for inference only. Input isn't modified, copies are bootstrapped by the tournament when needed
+func (p MaxPooling2D) Predict(input [][][]*rlwe.Ciphertext) [][][]*rlwe.Ciphertext {
+
+	p.btspOutput = []bool{false, false}
+
+	return p.pool(input, false)
+
+}
+
+// Compute maximum of every pool concurrently. Routes are only kept when they are needed for Backward
+func (p MaxPooling2D) pool(input [][][]*rlwe.Ciphertext, keepRoutes bool) [][][]*rlwe.Ciphertext {
+
+	outputSize := p.GetOutputSize()
+	output := make([][][]*rlwe.Ciphertext, outputSize[0])
+	routes := make([][][][][]*rlwe.Ciphertext, outputSize[0])
+
+	var wg sync.WaitGroup
+
+	for outRow := range output {
+
+		output[outRow] = make([][]*rlwe.Ciphertext, outputSize[1])
+		routes[outRow] = make([][][][]*rlwe.Ciphertext, outputSize[1])
+
+		for outCol := range output[outRow] {
+
+			output[outRow][outCol] = make([]*rlwe.Ciphertext, outputSize[2])
+			routes[outRow][outCol] = make([][][]*rlwe.Ciphertext, outputSize[2])
+
+			for depth := 0; depth < outputSize[2]; depth++ {
+
+				wg.Add(1)
+
+				go func(outRow int, outCol int, depth int, utils utility.Utils) {
+
+					defer wg.Done()
+
+					// Values of the pool in row-major order
+					values := make([]*rlwe.Ciphertext, 0, p.Size[0]*p.Size[1])
+					for poolRow := 0; poolRow < p.Size[0]; poolRow++ {
+						for poolCol := 0; poolCol < p.Size[1]; poolCol++ {
+							values = append(values, input[outRow*p.Strides[0]+poolRow][outCol*p.Strides[1]+poolCol][depth])
+						}
+					}
+
+					output[outRow][outCol][depth], routes[outRow][outCol][depth] = p.tournament(values, utils, keepRoutes)
+
+				}(outRow, outCol, depth, p.utils.CopyWithClonedEval())
+
+			}
+
+		}
+
+	}
+
+	wg.Wait()
+
+	if keepRoutes {
+		p.routes.weights = routes
+	}
+
+	if p.btspOutput[0] {
+		p.utils.Bootstrap3dInPlace(output)
+	}
+
+	return output
+
+}
+
+// Compare values in pairs until the maximum is left. Odd value out advances to the next round
+func (p MaxPooling2D) tournament(values []*rlwe.Ciphertext, utils utility.Utils, keepRoutes bool) (*rlwe.Ciphertext, [][]*rlwe.Ciphertext) {
+
+	routes := [][]*rlwe.Ciphertext{}
+	slots := utils.Params.Slots()
+
+	for round := 0; len(values) > 1; round++ {
+
+		// Levels needed by the rounds left in this stage
+		if round%p.stageRounds() == 0 && utils.Bootstrapper != nil {
+			stageLevel := int(math.Min(float64(p.stageRounds()), float64(p.rounds()-round))) * p.roundLevel()
+			if minLevel1d(values) < stageLevel {
+				values = utility.Clone1dCiphertext(values)
+				utils.Bootstrap1dIfBelow(values, stageLevel)
+			}
+		}
+
+		winners := make([]*rlwe.Ciphertext, (len(values)+1)/2)
+		roundRoutes := make([]*rlwe.Ciphertext, len(values)/2)
+
+		for i := 0; i+1 < len(values); i += 2 {
+
+			diff := utils.SubNew(values[i], values[i+1])
+
+			mean := values[i].CopyNew()
+			utils.Add(mean, values[i+1], mean)
+			utils.MultiplyConst(mean, 0.5, mean, true, false)
+
+			winners[i/2] = p.halfAbs.Evaluate(diff, slots)
+			utils.Add(winners[i/2], mean, winners[i/2])
+
+			if keepRoutes {
+				roundRoutes[i/2] = p.route.Evaluate(diff, slots)
+			}
+
+		}
+
+		if len(values)%2 == 1 {
+			winners[len(winners)-1] = values[len(values)-1]
+		}
+
+		routes = append(routes, roundRoutes)
+		values = winners
+
+	}
+
+	return values[0].CopyNew(), routes
+
+}
+
+// Calculate loss gradient wrt input of a max pooling layer from the routes of the last Forward
+// input and output params aren't used and can be nil
+func (p MaxPooling2D) Backward(input [][][]*rlwe.Ciphertext, output [][][]*rlwe.Ciphertext, gradient [][][]*rlwe.Ciphertext, hasPrevLayer bool) Gradient2d {
+
+	if p.routes.weights == nil {
+		panic("Forward of MaxPooling2D must be called before Backward")
+	}
+
+	// Route gradient of every pool to its values concurrently
+	poolGradients := make([][][][]*rlwe.Ciphertext, len(gradient))
+
+	var wg sync.WaitGroup
+
+	for outRow := range gradient {
+
+		poolGradients[outRow] = make([][][]*rlwe.Ciphertext, len(gradient[outRow]))
+
+		for outCol := range gradient[outRow] {
+
+			poolGradients[outRow][outCol] = make([][]*rlwe.Ciphertext, len(gradient[outRow][outCol]))
+
+			for depth := range gradient[outRow][outCol] {
+
+				wg.Add(1)
+
+				go func(outRow int, outCol int, depth int, utils utility.Utils) {
+
+					defer wg.Done()
+					poolGradients[outRow][outCol][depth] = p.routeGradient(gradient[outRow][outCol][depth], p.routes.weights[outRow][outCol][depth], utils)
+
+				}(outRow, outCol, depth, p.utils.CopyWithClonedEval())
+
+			}
+
+		}
+
+	}
+
+	wg.Wait()
+
+	// Sum gradients of values that are in more than one pool
+	upSampledGradient := make([][][]*rlwe.Ciphertext, p.InputSize[0])
+	for row := range upSampledGradient {
+		upSampledGradient[row] = make([][]*rlwe.Ciphertext, p.InputSize[1])
+		for column := range upSampledGradient[row] {
+			upSampledGradient[row][column] = make([]*rlwe.Ciphertext, p.InputSize[2])
+		}
+	}
+
+	for outRow := range poolGradients {
+		for outCol := range poolGradients[outRow] {
+			for depth := range poolGradients[outRow][outCol] {
+				for i, valueGradient := range poolGradients[outRow][outCol][depth] {
+
+					row := outRow*p.Strides[0] + i/p.Size[1]
+					column := outCol*p.Strides[1] + i%p.Size[1]
+
+					if upSampledGradient[row][column][depth] == nil {
+						upSampledGradient[row][column][depth] = valueGradient
+					} else {
+						p.utils.Add(upSampledGradient[row][column][depth], valueGradient, upSampledGradient[row][column][depth])
+					}
+
+				}
+			}
+		}
+	}
+
+	// Input that isn't covered by any pool doesn't affect the loss
+	zeros := p.utils.GenerateFilledArray(0)
+	for row := range upSampledGradient {
+		for column := range upSampledGradient[row] {
+			for depth := range upSampledGradient[row][column] {
+				if upSampledGradient[row][column][depth] == nil {
+					upSampledGradient[row][column][depth] = p.utils.EncryptToLevel(zeros, gradient[0][0][depth].Level())
+				}
+			}
+		}
+	}
+
+	// Bootstrap output
+	if p.btspOutput[1] {
+		p.utils.Bootstrap3dInPlace(upSampledGradient)
+	}
+
+	return Gradient2d{InputGradient: upSampledGradient}
+
+}
+
+// Split gradient of a pool back through the tournament rounds, returning gradient of each value in row-major order.
+// Routes are bootstrapped when their level is lower than the gradient so each round consumes exactly 1 level
+func (p MaxPooling2D) routeGradient(gradient *rlwe.Ciphertext, routes [][]*rlwe.Ciphertext, utils utility.Utils) []*rlwe.Ciphertext {
+
+	// Number of values competing in each round
+	participants := make([]int, len(routes))
+	count := p.Size[0] * p.Size[1]
+	for round := range routes {
+		participants[round] = count
+		count = (count + 1) / 2
+	}
+
+	gradients := []*rlwe.Ciphertext{gradient}
+
+	for round := len(routes) - 1; round >= 0; round-- {
+
+		valueGradients := make([]*rlwe.Ciphertext, participants[round])
+
+		for i := 0; i+1 < participants[round]; i += 2 {
+
+			route := routes[round][i/2]
+			if utils.Bootstrapper != nil {
+				utils.Bootstrap1dIfBelow([]*rlwe.Ciphertext{route}, gradients[i/2].Level())
+			}
+
+			valueGradients[i] = utils.MultiplyNew(gradients[i/2], route, true, false)
+
+			otherRoute := utils.Evaluator.NegNew(route)
+			utils.Evaluator.AddConst(otherRoute, 1, otherRoute)
+			valueGradients[i+1] = utils.MultiplyNew(gradients[i/2], otherRoute, true, false)
+
+		}
+
+		if participants[round]%2 == 1 {
+			valueGradients[participants[round]-1] = gradients[len(gradients)-1]
+		}
+
+		gradients = valueGradients
+
+	}
+
+	return gradients
+
+}
+
+func (p *MaxPooling2D) UpdateGradient(gradient Gradient2d, lr float64) {}
+
+func (p MaxPooling2D) IsTrainable() bool {
+	return false
+}
+
+func (p MaxPooling2D) PlanRotations(planner *utility.RotationPlanner) {}
+
+func (p MaxPooling2D) HasActivation() bool {
+	return false
+}
+
+// Levels consumed by the last stage of the tournament, which is every round when the tournament fits in one stage.
+// Earlier stages bootstrap their own values so only the last stage lowers the level of the output
+func (p MaxPooling2D) GetForwardLevelConsumption() int {
+	if p.rounds() == 0 {
+		return 0
+	}
+	return ((p.rounds()-1)%p.stageRounds() + 1) * p.roundLevel()
+}
+
+func (p MaxPooling2D) GetBackwardLevelConsumption() int {
+	return p.rounds()
+}
+
+func (p MaxPooling2D) GetForwardActivationLevelConsumption() int {
+	return 0
+}
+
+func (p MaxPooling2D) GetBackwardActivationLevelConsumption() int {
+	return 0
+}
+
+func (p *MaxPooling2D) SetBootstrapOutput(set bool, direction string) {
+	switch direction {
+	case "forward":
+		p.btspOutput[0] = set
+	case "backward":
+		p.btspOutput[1] = set
+	}
+}
+
+func (p *MaxPooling2D) SetBootstrapActivation(set bool, direction string) {
+
+}
+
+func (p *MaxPooling2D) SetWeightLevel(lvl int) {
+
+}
+
+// Pooling has no weights
+func (p *MaxPooling2D) SetOptimizer(optimizer optimizers.Optimizer) {}
+
+func (p MaxPooling2D) Checkpoint() (LayerCheckpoint, error) {
+	return LayerCheckpoint{Type: "max_pooling2d", BtspOutput: append([]bool{}, p.btspOutput...)}, nil
+}
+
+// Restore bootstrapping settings saved by Checkpoint
+func (p *MaxPooling2D) Restore(checkpoint LayerCheckpoint) error {
+
+	if err := checkpoint.checkType("max_pooling2d"); err != nil {
+		return err
+	}
+
+	copy(p.btspOutput, checkpoint.BtspOutput)
+
+	return nil
+
+}
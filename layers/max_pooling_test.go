@@ -0,0 +1,75 @@
+package layers
+
+import (
+	"math"
+	"testing"
+
+	"github.com/perm-ai/go-cerebrum/internal/utilitytest"
+	"github.com/perm-ai/go-cerebrum/key"
+	"github.com/tuneinsight/lattigo/v4/ckks"
+	"github.com/tuneinsight/lattigo/v4/rlwe"
+)
+
+func TestMaxPooling(t *testing.T) {
+
+	utils := utilitytest.NewUtils(key.NewParameters(ckks.PN15QP880, nil), math.Pow(2, 40))
+
+	batchSize := 3
+
+	// [row][column][data], maximum of each data is at a different position
+	inputs := [][][]float64{
+		{{0.9, 0.1, -0.2}, {0.3, 0.7, 0.1}},
+		{{-0.5, 0.2, 0.8}, {0.4, -0.3, 0.5}},
+	}
+	argmax := [][]int{{0, 0}, {0, 1}, {1, 0}}
+
+	input := make([][][]*rlwe.Ciphertext, len(inputs))
+	for r := range inputs {
+		input[r] = make([][]*rlwe.Ciphertext, len(inputs[r]))
+		for c := range inputs[r] {
+			input[r][c] = []*rlwe.Ciphertext{utils.EncryptToLevel(inputs[r][c], utils.Params.MaxLevel())}
+		}
+	}
+
+	pool := NewMaxPoolingLayer(utils, []int{2, 2, 1}, []int{2, 2}, []int{2, 2}, 1)
+
+	output := pool.Forward(input).Output
+
+	if consumed := utils.Params.MaxLevel() - output[0][0][0].Level(); consumed != pool.GetForwardLevelConsumption() {
+		t.Errorf("Forward consumed %d levels but reported %d", consumed, pool.GetForwardLevelConsumption())
+	}
+
+	decrypted := utils.Decrypt(output[0][0][0])
+	for i := 0; i < batchSize; i++ {
+		expected := inputs[argmax[i][0]][argmax[i][1]][i]
+		if math.Abs(decrypted[i]-expected) > 0.02 {
+			t.Errorf("Data %d expected maximum %f but got %f", i, expected, decrypted[i])
+		}
+	}
+
+	gradient := [][][]*rlwe.Ciphertext{{{utils.EncryptToLevel([]float64{1, -2, 0.5}, 9)}}}
+	gradientValues := []float64{1, -2, 0.5}
+
+	inputGradient := pool.Backward(nil, nil, gradient, true).InputGradient
+
+	if consumed := 9 - inputGradient[0][0][0].Level(); consumed != pool.GetBackwardLevelConsumption() {
+		t.Errorf("Backward consumed %d levels but reported %d", consumed, pool.GetBackwardLevelConsumption())
+	}
+
+	// Gradient is routed to the maximum of each data. Derivative of the approximated |x| overshoots by about 10%
+	for r := range inputGradient {
+		for c := range inputGradient[r] {
+			decrypted := utils.Decrypt(inputGradient[r][c][0])
+			for i := 0; i < batchSize; i++ {
+				expected := 0.0
+				if argmax[i][0] == r && argmax[i][1] == c {
+					expected = gradientValues[i]
+				}
+				if math.Abs(decrypted[i]-expected) > 0.15*math.Abs(gradientValues[i]) {
+					t.Errorf("Gradient [%d][%d] of data %d expected %f but got %f", r, c, i, expected, decrypted[i])
+				}
+			}
+		}
+	}
+
+}
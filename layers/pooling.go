@@ -2,6 +2,7 @@ package layers
 
 import (
 	"github.com/tuneinsight/lattigo/v4/rlwe"
+	"github.com/perm-ai/go-cerebrum/optimizers"
 	"github.com/perm-ai/go-cerebrum/utility"
 )
 
@@ -39,17 +40,18 @@ func (p AveragePooling2D) Forward(input [][][]*rlwe.Ciphertext) Output2d {
 	// Loop through each input datapoint that corresponds to the first row of the pooling filter
 	for row := 0; row <= p.InputSize[0]-p.Size[0]; row += p.Strides[0] {
 
-		outputChannels[row] = make(chan [][]*rlwe.Ciphertext)
+		outputChannels[currentOutRow] = make(chan [][]*rlwe.Ciphertext)
 
 		go func(rowIndex int, outputChannel chan [][]*rlwe.Ciphertext) {
 
 			// Create array of channels for sending array of depth in each column in a concurrent operations
 			outputColumnChannels := make([]chan []*rlwe.Ciphertext, outputSize[1])
+			currentOutCol := 0
 
 			// Loop through each input datapoint that corresponds to the first column of the pooling filter
 			for column := 0; column <= p.InputSize[1]-p.Size[1]; column += p.Strides[1] {
 
-				outputColumnChannels[column] = make(chan []*rlwe.Ciphertext)
+				outputColumnChannels[currentOutCol] = make(chan []*rlwe.Ciphertext)
 
 				go func(rowIndex int, colIndex int, outputColumnChannel chan []*rlwe.Ciphertext) {
 
@@ -69,7 +71,7 @@ func (p AveragePooling2D) Forward(input [][][]*rlwe.Ciphertext) Output2d {
 								for poolCol := 0; poolCol < p.Size[1]; poolCol++ {
 
 									if poolResult == nil {
-										poolResult = input[rowIndex+poolRow][colIndex+poolCol][depIndex]
+										poolResult = input[rowIndex+poolRow][colIndex+poolCol][depIndex].CopyNew()
 									} else {
 										utils.Add(poolResult, input[rowIndex+poolRow][colIndex+poolCol][depIndex], poolResult)
 									}
@@ -99,7 +101,9 @@ func (p AveragePooling2D) Forward(input [][][]*rlwe.Ciphertext) Output2d {
 
 					outputColumnChannel <- outputColumn
 
-				}(rowIndex, column, outputColumnChannels[column])
+				}(rowIndex, column, outputColumnChannels[currentOutCol])
+
+				currentOutCol++
 
 			}
 
@@ -113,7 +117,7 @@ func (p AveragePooling2D) Forward(input [][][]*rlwe.Ciphertext) Output2d {
 			// Send row array back through channel
 			outputChannel <- outputRow
 
-		}(row, outputChannels[row])
+		}(row, outputChannels[currentOutRow])
 
 		// Increment current output row
 		currentOutRow++
@@ -130,16 +134,29 @@ func (p AveragePooling2D) Forward(input [][][]*rlwe.Ciphertext) Output2d {
 
 }
 
+// Evaluate the layer for inference only. Input is only bootstrapped if its level isn't enough for averaging
+func (p AveragePooling2D) Predict(input [][][]*rlwe.Ciphertext) [][][]*rlwe.Ciphertext {
+
+	for r := range input {
+		for c := range input[r] {
+			p.utils.Bootstrap1dIfBelow(input[r][c], p.GetForwardLevelConsumption())
+		}
+	}
+
+	p.btspOutput = []bool{false, false}
+
+	return p.Forward(input).Output
+
+}
+
 // Calculate loss gradient wrt input of a pooling layer
 // input and output params aren't used and can be nil
 func (p AveragePooling2D) Backward(input [][][]*rlwe.Ciphertext, output [][][]*rlwe.Ciphertext, gradient [][][]*rlwe.Ciphertext, hasPrevLayer bool) Gradient2d {
 
-	gradientSize := p.GetOutputSize()
-
 	// ===================================================
-	// ======= Divide each gradient by output size =======
+	// ======== Divide each gradient by pool size ========
 	// ===================================================
-	divider := p.utils.EncodePlaintextFromArray(p.utils.GenerateFilledArray(1.0 / float64(gradientSize[0]*gradientSize[1])))
+	divider := p.utils.EncodePlaintextFromArray(p.utils.GenerateFilledArray(1.0 / float64(p.Size[0]*p.Size[1])))
 
 	// Declare array of channels to recieve value from each Goroutine
 	rowChannels := make([]chan [][]*rlwe.Ciphertext, len(gradient))
@@ -162,7 +179,7 @@ func (p AveragePooling2D) Backward(input [][][]*rlwe.Ciphertext, output [][][]*r
 					depChannels := make([]chan *rlwe.Ciphertext, len(gradient[rowIndex][colIndex]))
 					for depth := range gradient[rowIndex][colIndex] {
 						depChannels[depth] = make(chan *rlwe.Ciphertext)
-						p.utils.MultiplyPlainConcurrent(gradient[rowIndex][colIndex][depth], divider, true, depChannels[depth])
+						go p.utils.MultiplyPlainConcurrent(gradient[rowIndex][colIndex][depth], divider, true, depChannels[depth])
 					}
 
 					colOutput := make([]*rlwe.Ciphertext, len(gradient[rowIndex][colIndex]))
@@ -225,7 +242,7 @@ func (p AveragePooling2D) Backward(input [][][]*rlwe.Ciphertext, output [][][]*r
 					for depth := 0; depth < p.InputSize[2]; depth++ {
 
 						if upSampledGradient[row+poolRow][column+poolCol][depth] == nil {
-							upSampledGradient[row+poolRow][column+poolCol][depth] = gradient[currentGradRow][currentGradCol][depth]
+							upSampledGradient[row+poolRow][column+poolCol][depth] = gradient[currentGradRow][currentGradCol][depth].CopyNew()
 						} else {
 							p.utils.Add(upSampledGradient[row+poolRow][column+poolCol][depth], gradient[currentGradRow][currentGradCol][depth], upSampledGradient[row+poolRow][column+poolCol][depth])
 						}
@@ -245,6 +262,29 @@ func (p AveragePooling2D) Backward(input [][][]*rlwe.Ciphertext, output [][][]*r
 
 	}
 
+	// Input that isn't covered by any pool doesn't affect the loss
+	zeros := p.utils.GenerateFilledArray(0)
+	for row := range upSampledGradient {
+
+		if upSampledGradient[row] == nil {
+			upSampledGradient[row] = make([][]*rlwe.Ciphertext, p.InputSize[1])
+		}
+
+		for column := range upSampledGradient[row] {
+
+			if upSampledGradient[row][column] == nil {
+				upSampledGradient[row][column] = make([]*rlwe.Ciphertext, p.InputSize[2])
+			}
+
+			for depth := range upSampledGradient[row][column] {
+				if upSampledGradient[row][column][depth] == nil {
+					upSampledGradient[row][column][depth] = p.utils.EncryptToLevel(zeros, gradient[0][0][depth].Level())
+				}
+			}
+
+		}
+	}
+
 	// Bootstrap output
 	if p.btspOutput[1] {
 		p.utils.Bootstrap3dInPlace(upSampledGradient)
@@ -260,6 +300,8 @@ func (p AveragePooling2D) IsTrainable() bool {
 	return false
 }
 
+func (p AveragePooling2D) PlanRotations(planner *utility.RotationPlanner) {}
+
 func (p AveragePooling2D) HasActivation() bool {
 	return false
 }
@@ -295,4 +337,24 @@ func (p *AveragePooling2D) SetBootstrapActivation(set bool, direction string) {
 
 func (p *AveragePooling2D) SetWeightLevel(lvl int){
 
+}
+
+// Save bootstrapping settings of the layer. Pooling has no weights
+func (p *AveragePooling2D) SetOptimizer(optimizer optimizers.Optimizer) {}
+
+func (p AveragePooling2D) Checkpoint() (LayerCheckpoint, error) {
+	return LayerCheckpoint{Type: "average_pooling2d", BtspOutput: append([]bool{}, p.btspOutput...)}, nil
+}
+
+// Restore bootstrapping settings saved by Checkpoint
+func (p *AveragePooling2D) Restore(checkpoint LayerCheckpoint) error {
+
+	if err := checkpoint.checkType("average_pooling2d"); err != nil {
+		return err
+	}
+
+	copy(p.btspOutput, checkpoint.BtspOutput)
+
+	return nil
+
 }
\ No newline at end of file
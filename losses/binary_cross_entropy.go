@@ -0,0 +1,90 @@
+package losses
+
+import (
+	"math"
+
+	"github.com/perm-ai/go-cerebrum/utility"
+	"github.com/tuneinsight/lattigo/v4/rlwe"
+)
+
+// Degree of Chebyshev approximation of 1 / (p(1 - p)) used to calculate gradient
+const inverseDegree = 15
+
+// Binary cross entropy for a sigmoid output. Labels are 0 or 1
+type BinaryCrossEntropy struct {
+	U          utility.Utils
+	LowerBound float64 // Predictions are expected in [LowerBound, 1 - LowerBound], values outside aren't approximated correctly. Default to 0.05 if 0
+}
+
+func logInverse(x float64) float64 {
+	return math.Log(1 - x)
+}
+
+func inverse(x float64) float64 {
+	return 1 / (x * (1 - x))
+}
+
+func (b BinaryCrossEntropy) lowerBound() float64 {
+	if b.LowerBound == 0 {
+		return 0.05
+	}
+	return b.LowerBound
+}
+
+// -sum(y * log(pred) + (1 - y) * log(1 - pred)) summed over outputs and averaged over predLength data.
+// Calculated as -sum(y * (log(pred) - log(1 - pred)) + log(1 - pred)) to multiply with the label only once
+func (b BinaryCrossEntropy) Forward(pred []*rlwe.Ciphertext, y []*rlwe.Ciphertext, predLength int) *rlwe.Ciphertext {
+
+	lower, upper := b.lowerBound(), 1-b.lowerBound()
+
+	var loss *rlwe.Ciphertext
+
+	for i := range pred {
+		logPred := b.U.LogApprox(pred[i], lower, upper, logDegree)
+		logInversePred := b.U.ChebyshevApprox(pred[i], logInverse, lower, upper, logDegree)
+		term := b.U.MultiplyNew(b.U.SubNew(logPred, logInversePred), y[i], true, false)
+		b.U.Add(term, logInversePred, term)
+		if loss == nil {
+			loss = term
+		} else {
+			b.U.Add(loss, term, loss)
+		}
+	}
+
+	average(b.U, loss, -1, predLength)
+
+	return loss
+
+}
+
+// Gradient wrt prediction (pred - y) / (pred(1 - pred)). Dense multiplies it by derivative of sigmoid, which is
+// pred(1 - pred), so the gradient wrt input of sigmoid is pred - y
+func (b BinaryCrossEntropy) Backward(pred []*rlwe.Ciphertext, y []*rlwe.Ciphertext, predLength int) []*rlwe.Ciphertext {
+
+	lower, upper := b.lowerBound(), 1-b.lowerBound()
+
+	result := make([]*rlwe.Ciphertext, len(pred))
+	for i := range pred {
+		inversed := b.U.ChebyshevApprox(pred[i], inverse, lower, upper, inverseDegree)
+		result[i] = b.U.MultiplyNew(b.U.SubNew(pred[i], y[i]), inversed, true, false)
+	}
+
+	return result
+
+}
+
+// Multiplication by label and averaging consume 2 levels on top of log approximations
+func (b BinaryCrossEntropy) GetForwardLevelConsumption() int {
+	lower, upper := b.lowerBound(), 1-b.lowerBound()
+	logLevel := approximationLevel(b.U, math.Log, lower, upper, logDegree)
+	return int(math.Max(float64(logLevel), float64(approximationLevel(b.U, logInverse, lower, upper, logDegree)))) + 2
+}
+
+// Multiplication by error consumes 1 level on top of inverse approximation
+func (b BinaryCrossEntropy) GetBackwardLevelConsumption() int {
+	return approximationLevel(b.U, inverse, b.lowerBound(), 1-b.lowerBound(), inverseDegree) + 1
+}
+
+func (b BinaryCrossEntropy) PlanRotations(planner *utility.RotationPlanner) {
+	planner.AddSumElements()
+}
@@ -1,16 +1,46 @@
 package losses
 
 import (
+	"math"
+
 	"github.com/tuneinsight/lattigo/v4/rlwe"
 	"github.com/perm-ai/go-cerebrum/utility"
 )
 
+// Degree of Chebyshev approximation of log used to calculate loss
+const logDegree = 31
+
 type CrossEntropy struct {
-	U utility.Utils
+	U             utility.Utils
+	LogLowerBound float64 // Smallest prediction log is approximated for, predictions below it aren't approximated correctly. Default to 0.01 if 0
+}
+
+func (c CrossEntropy) lowerBound() float64 {
+	if c.LogLowerBound == 0 {
+		return 0.01
+	}
+	return c.LogLowerBound
 }
 
-func (c CrossEntropy) Forward(pred []*rlwe.Ciphertext, y []*rlwe.Ciphertext, predLength int) []*rlwe.Ciphertext {
-	return pred
+// Categorical cross entropy -sum(y * log(pred)) summed over outputs and averaged over predLength data.
+// log(pred) is approximated in [LogLowerBound, 1]
+func (c CrossEntropy) Forward(pred []*rlwe.Ciphertext, y []*rlwe.Ciphertext, predLength int) *rlwe.Ciphertext {
+
+	var loss *rlwe.Ciphertext
+
+	for i := range pred {
+		logPred := c.U.LogApprox(pred[i], c.lowerBound(), 1, logDegree)
+		term := c.U.MultiplyNew(logPred, y[i], true, false)
+		if loss == nil {
+			loss = term
+		} else {
+			c.U.Add(loss, term, loss)
+		}
+	}
+
+	average(c.U, loss, -1, predLength)
+
+	return loss
 }
 
 func (c CrossEntropy) Backward(pred []*rlwe.Ciphertext, y []*rlwe.Ciphertext, predLength int) []*rlwe.Ciphertext {
@@ -22,3 +52,16 @@ func (c CrossEntropy) Backward(pred []*rlwe.Ciphertext, y []*rlwe.Ciphertext, pr
 
 	return result
 }
+
+// Multiplication by label and averaging consume 2 levels on top of log approximation
+func (c CrossEntropy) GetForwardLevelConsumption() int {
+	return approximationLevel(c.U, math.Log, c.lowerBound(), 1, logDegree) + 2
+}
+
+func (c CrossEntropy) GetBackwardLevelConsumption() int {
+	return 0
+}
+
+func (c CrossEntropy) PlanRotations(planner *utility.RotationPlanner) {
+	planner.AddSumElements()
+}
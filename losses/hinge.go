@@ -0,0 +1,92 @@
+package losses
+
+import (
+	"math"
+
+	"github.com/perm-ai/go-cerebrum/utility"
+	"github.com/tuneinsight/lattigo/v4/rlwe"
+)
+
+// Degree of Chebyshev approximation of hinge loss and its gradient
+const hingeDegree = 15
+
+// Hinge loss max(0, 1 - y * pred) used to train a linear output as SVM. Labels are -1 or 1.
+// The loss is approximated by a polynomial over margins y * pred in [-Bound, Bound] and the step in its gradient
+// by a logistic function with steepness Steepness
+type Hinge struct {
+	U         utility.Utils
+	Bound     float64 // Largest absolute margin the approximation is valid for. Default to 4 if 0
+	Steepness float64 // Steepness of logistic approximation of step in gradient. Default to 5 if 0
+}
+
+func (h Hinge) bound() float64 {
+	if h.Bound == 0 {
+		return 4
+	}
+	return h.Bound
+}
+
+func (h Hinge) steepness() float64 {
+	if h.Steepness == 0 {
+		return 5
+	}
+	return h.Steepness
+}
+
+func (h Hinge) loss(margin float64) float64 {
+	return math.Max(0, 1-margin)
+}
+
+// Derivative of loss wrt margin, -1 when margin < 1 and 0 otherwise
+func (h Hinge) gradient(margin float64) float64 {
+	return -1 / (1 + math.Exp(h.steepness()*(margin-1)))
+}
+
+// Hinge loss summed over outputs and averaged over predLength data
+func (h Hinge) Forward(pred []*rlwe.Ciphertext, y []*rlwe.Ciphertext, predLength int) *rlwe.Ciphertext {
+
+	var loss *rlwe.Ciphertext
+
+	for i := range pred {
+		margin := h.U.MultiplyNew(pred[i], y[i], true, false)
+		term := h.U.ChebyshevApprox(margin, h.loss, -h.bound(), h.bound(), hingeDegree)
+		if loss == nil {
+			loss = term
+		} else {
+			h.U.Add(loss, term, loss)
+		}
+	}
+
+	average(h.U, loss, 1, predLength)
+
+	return loss
+
+}
+
+// Gradient wrt prediction -y when y * pred < 1 and 0 otherwise
+func (h Hinge) Backward(pred []*rlwe.Ciphertext, y []*rlwe.Ciphertext, predLength int) []*rlwe.Ciphertext {
+
+	result := make([]*rlwe.Ciphertext, len(pred))
+	for i := range pred {
+		margin := h.U.MultiplyNew(pred[i], y[i], true, false)
+		marginGradient := h.U.ChebyshevApprox(margin, h.gradient, -h.bound(), h.bound(), hingeDegree)
+		result[i] = h.U.MultiplyNew(marginGradient, y[i], true, false)
+	}
+
+	return result
+
+}
+
+// Margin and averaging consume 1 level each on top of loss approximation
+func (h Hinge) GetForwardLevelConsumption() int {
+	return approximationLevel(h.U, h.loss, -h.bound(), h.bound(), hingeDegree) + 2
+}
+
+// Margin and multiplication by label consume 1 level each on top of gradient approximation
+func (h Hinge) GetBackwardLevelConsumption() int {
+	return approximationLevel(h.U, h.gradient, -h.bound(), h.bound(), hingeDegree) + 2
+}
+
+func (h Hinge) PlanRotations(planner *utility.RotationPlanner) {
+	planner.AddSumElements()
+}
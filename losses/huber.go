@@ -0,0 +1,89 @@
+package losses
+
+import (
+	"math"
+
+	"github.com/perm-ai/go-cerebrum/utility"
+	"github.com/tuneinsight/lattigo/v4/rlwe"
+)
+
+// Degree of Chebyshev approximation of Huber loss and its gradient
+const huberDegree = 15
+
+// Huber loss 0.5e^2 when |e| <= Delta and Delta(|e| - 0.5Delta) otherwise, where e = pred - y.
+// The loss and its gradient are approximated by polynomials over errors in [-Bound, Bound]
+type Huber struct {
+	U     utility.Utils
+	Delta float64 // Error where loss changes from quadratic to linear. Default to 1 if 0
+	Bound float64 // Largest absolute error the approximation is valid for. Default to 4 if 0
+}
+
+func (h Huber) delta() float64 {
+	if h.Delta == 0 {
+		return 1
+	}
+	return h.Delta
+}
+
+func (h Huber) bound() float64 {
+	if h.Bound == 0 {
+		return 4
+	}
+	return h.Bound
+}
+
+func (h Huber) loss(e float64) float64 {
+	if math.Abs(e) <= h.delta() {
+		return 0.5 * e * e
+	}
+	return h.delta() * (math.Abs(e) - 0.5*h.delta())
+}
+
+func (h Huber) gradient(e float64) float64 {
+	return math.Max(-h.delta(), math.Min(h.delta(), e))
+}
+
+// Huber loss summed over outputs and averaged over predLength data
+func (h Huber) Forward(pred []*rlwe.Ciphertext, y []*rlwe.Ciphertext, predLength int) *rlwe.Ciphertext {
+
+	var loss *rlwe.Ciphertext
+
+	for i := range pred {
+		term := h.U.ChebyshevApprox(h.U.SubNew(pred[i], y[i]), h.loss, -h.bound(), h.bound(), huberDegree)
+		if loss == nil {
+			loss = term
+		} else {
+			h.U.Add(loss, term, loss)
+		}
+	}
+
+	average(h.U, loss, 1, predLength)
+
+	return loss
+
+}
+
+// Gradient wrt prediction is the error clipped to [-Delta, Delta]
+func (h Huber) Backward(pred []*rlwe.Ciphertext, y []*rlwe.Ciphertext, predLength int) []*rlwe.Ciphertext {
+
+	result := make([]*rlwe.Ciphertext, len(pred))
+	for i := range pred {
+		result[i] = h.U.ChebyshevApprox(h.U.SubNew(pred[i], y[i]), h.gradient, -h.bound(), h.bound(), huberDegree)
+	}
+
+	return result
+
+}
+
+// Averaging consumes 1 level on top of loss approximation
+func (h Huber) GetForwardLevelConsumption() int {
+	return approximationLevel(h.U, h.loss, -h.bound(), h.bound(), huberDegree) + 1
+}
+
+func (h Huber) GetBackwardLevelConsumption() int {
+	return approximationLevel(h.U, h.gradient, -h.bound(), h.bound(), huberDegree)
+}
+
+func (h Huber) PlanRotations(planner *utility.RotationPlanner) {
+	planner.AddSumElements()
+}
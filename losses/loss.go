@@ -2,9 +2,29 @@ package losses
 
 import (
 	"github.com/tuneinsight/lattigo/v4/rlwe"
+	"github.com/perm-ai/go-cerebrum/utility"
 )
 
 type Loss interface {
-	Forward(pred []*rlwe.Ciphertext, y []*rlwe.Ciphertext, predLength int) []*rlwe.Ciphertext
+	// Loss summed over outputs and averaged over the first predLength slots, packed in every slot of the result
+	// so the key holder can decrypt it to track training progress
+	Forward(pred []*rlwe.Ciphertext, y []*rlwe.Ciphertext, predLength int) *rlwe.Ciphertext
 	Backward(pred []*rlwe.Ciphertext, y []*rlwe.Ciphertext, predLength int) []*rlwe.Ciphertext
+	GetForwardLevelConsumption() int              // Levels consumed by Forward
+	GetBackwardLevelConsumption() int             // Levels consumed by Backward
+	PlanRotations(planner *utility.RotationPlanner) // Add rotations performed by the loss to planner
+}
+
+// Multiply by 1/predLength in the first predLength slots and sum all slots. Slots that don't hold data are masked out
+func average(u utility.Utils, loss *rlwe.Ciphertext, scale float64, predLength int) {
+
+	averager := u.EncodePlaintextFromArray(u.GenerateFilledArraySize(scale/float64(predLength), predLength))
+	u.MultiplyPlain(loss, averager, loss, true, false)
+	u.SumElementsInPlace(loss)
+
+}
+
+// Levels consumed by utility.ChebyshevApprox of function
+func approximationLevel(u utility.Utils, function func(float64) float64, lower float64, upper float64, degree int) int {
+	return utility.FitChebyshev(function, lower, upper, degree, u).GetLevelConsumption()
 }
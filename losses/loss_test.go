@@ -0,0 +1,125 @@
+package losses
+
+import (
+	"math"
+	"testing"
+
+	"github.com/perm-ai/go-cerebrum/internal/utilitytest"
+	"github.com/perm-ai/go-cerebrum/key"
+	"github.com/perm-ai/go-cerebrum/utility"
+	"github.com/tuneinsight/lattigo/v4/ckks"
+	"github.com/tuneinsight/lattigo/v4/rlwe"
+)
+
+type lossTest struct {
+	name     string
+	loss     Loss
+	pred     [][]float64
+	y        [][]float64
+	forward  func(pred float64, y float64) float64 // Loss of a single element
+	backward func(pred float64, y float64) float64 // Gradient wrt a single prediction
+}
+
+func TestLoss(t *testing.T) {
+
+	// Log approximation of cross entropy needs more levels than the smaller parameters have
+	utils := utilitytest.NewUtils(key.NewParameters(ckks.PN15QP880, nil), math.Pow(2, 40), MSE{}.PlanRotations)
+
+	predLength := 4
+	probability := [][]float64{{0.7, 0.2, 0.1, 0.4}, {0.3, 0.8, 0.9, 0.6}}
+	oneHot := [][]float64{{1, 0, 0, 1}, {0, 1, 1, 0}}
+	regression := [][]float64{{0.5, -1.5, 2.5, 0.2}, {-0.3, 1.2, -2, 0.9}}
+	target := [][]float64{{0, 0.5, 0, 0}, {0, -1, 1, 1}}
+	sign := [][]float64{{1, -1, -1, 1}, {-1, 1, 1, -1}}
+
+	tests := []lossTest{
+		{
+			"MSE", MSE{U: utils}, probability, oneHot,
+			func(p float64, y float64) float64 { return math.Pow(p-y, 2) },
+			func(p float64, y float64) float64 { return p - y },
+		},
+		{
+			"CrossEntropy", CrossEntropy{U: utils, LogLowerBound: 0.05}, probability, oneHot,
+			func(p float64, y float64) float64 { return -y * math.Log(p) },
+			func(p float64, y float64) float64 { return p - y },
+		},
+		{
+			"BinaryCrossEntropy", BinaryCrossEntropy{U: utils}, probability, oneHot,
+			func(p float64, y float64) float64 { return -y*math.Log(p) - (1-y)*math.Log(1-p) },
+			func(p float64, y float64) float64 { return (p - y) / (p * (1 - p)) },
+		},
+		{
+			"Huber", Huber{U: utils}, regression, target,
+			func(p float64, y float64) float64 {
+				if math.Abs(p-y) <= 1 {
+					return 0.5 * math.Pow(p-y, 2)
+				}
+				return math.Abs(p-y) - 0.5
+			},
+			func(p float64, y float64) float64 { return math.Max(-1, math.Min(1, p-y)) },
+		},
+		{
+			"Hinge", Hinge{U: utils}, regression, sign,
+			func(p float64, y float64) float64 { return math.Max(0, 1-y*p) },
+			// Step of gradient at margin 1 is approximated by logistic function
+			func(p float64, y float64) float64 { return -y / (1 + math.Exp(5*(y*p-1))) },
+		},
+	}
+
+	// Slots after predLength hold values that shouldn't affect loss
+	encrypt := func(values [][]float64) []*rlwe.Ciphertext {
+		cts := make([]*rlwe.Ciphertext, len(values))
+		for i := range values {
+			filled := utils.GenerateFilledArray(0.5)
+			copy(filled, values[i])
+			cts[i] = utils.EncryptToPointer(filled)
+		}
+		return cts
+	}
+
+	for _, test := range tests {
+
+		expected := 0.0
+		for i := range test.pred {
+			for b := 0; b < predLength; b++ {
+				expected += test.forward(test.pred[i][b], test.y[i][b]) / float64(predLength)
+			}
+		}
+
+		pred, y := encrypt(test.pred), encrypt(test.y)
+		startLevel := pred[0].Level()
+
+		loss := test.loss.Forward(utility.Clone1dCiphertext(pred), utility.Clone1dCiphertext(y), predLength)
+
+		if consumed := startLevel - loss.Level(); consumed != test.loss.GetForwardLevelConsumption() {
+			t.Errorf("%s: forward expected to consume %d levels but consumed %d", test.name, test.loss.GetForwardLevelConsumption(), consumed)
+		}
+
+		decrypted := utils.Decrypt(loss)
+		for _, slot := range []int{0, 1, len(decrypted) - 1} {
+			if math.Abs(decrypted[slot]-expected) > 5e-2 {
+				t.Errorf("%s: expected loss %f in slot %d but got %f", test.name, expected, slot, decrypted[slot])
+			}
+		}
+
+		gradient := test.loss.Backward(pred, y, predLength)
+
+		for i := range gradient {
+
+			if consumed := startLevel - gradient[i].Level(); consumed != test.loss.GetBackwardLevelConsumption() {
+				t.Errorf("%s: backward expected to consume %d levels but consumed %d", test.name, test.loss.GetBackwardLevelConsumption(), consumed)
+			}
+
+			decrypted := utils.Decrypt(gradient[i])
+			for b := 0; b < predLength; b++ {
+				expected := test.backward(test.pred[i][b], test.y[i][b])
+				if math.Abs(decrypted[b]-expected) > 0.1*math.Max(1, math.Abs(expected)) {
+					t.Errorf("%s: expected gradient %f of output %d at %d but got %f", test.name, expected, i, b, decrypted[b])
+				}
+			}
+
+		}
+
+	}
+
+}
@@ -9,29 +9,24 @@ type MSE struct {
 	U utility.Utils
 }
 
-func (m MSE) Forward(pred []*rlwe.Ciphertext, y []*rlwe.Ciphertext, predLength int) []*rlwe.Ciphertext {
-	result := make([]*rlwe.Ciphertext, len(pred))
-	error := make([]*rlwe.Ciphertext, len(pred))
-	for i := range pred{
-		error[i] = m.U.SubNew(pred[i], y[i])
-	}
-	error_squared := make([]*rlwe.Ciphertext, len(pred))
-	for i := range pred{
-		error_squared[i] = m.U.MultiplyNew(error[i], error[i], true, false)
+// Mean squared error summed over outputs and averaged over predLength data. Consumes 2 levels
+func (m MSE) Forward(pred []*rlwe.Ciphertext, y []*rlwe.Ciphertext, predLength int) *rlwe.Ciphertext {
+
+	var loss *rlwe.Ciphertext
+
+	for i := range pred {
+		error := m.U.SubNew(pred[i], y[i])
+		errorSquared := m.U.MultiplyNew(error, error, true, false)
+		if loss == nil {
+			loss = errorSquared
+		} else {
+			m.U.Add(loss, errorSquared, loss)
+		}
 	}
 
-	// sum := make([]*rlwe.Ciphertext, len(pred))
-	for i := range pred{
-		m.U.SumElementsInPlace(error_squared[i])
-	}
+	average(m.U, loss, 1, predLength)
 
-	mean := m.U.EncodePlaintextFromArray(m.U.GenerateFilledArray(float64(1/predLength)))
-
-	for i := range pred{
-		result[i] = m.U.MultiplyPlainNew(error_squared[i], mean, true, false)
-	}
-	
-	return result
+	return loss
 }
 
 func (m MSE) Backward(pred []*rlwe.Ciphertext, y []*rlwe.Ciphertext, predLength int) []*rlwe.Ciphertext {
@@ -44,3 +39,15 @@ func (m MSE) Backward(pred []*rlwe.Ciphertext, y []*rlwe.Ciphertext, predLength
 
 	return result
 }
+
+func (m MSE) GetForwardLevelConsumption() int {
+	return 2
+}
+
+func (m MSE) GetBackwardLevelConsumption() int {
+	return 0
+}
+
+func (m MSE) PlanRotations(planner *utility.RotationPlanner) {
+	planner.AddSumElements()
+}
@@ -10,8 +10,6 @@ import (
 	"strconv"
 	"strings"
 
-	"github.com/tuneinsight/lattigo/v4/ckks"
-	"github.com/tuneinsight/lattigo/v4/ckks/bootstrapping"
 	"github.com/tuneinsight/lattigo/v4/rlwe"
 	"github.com/perm-ai/go-cerebrum/key"
 	"github.com/perm-ai/go-cerebrum/logger"
@@ -166,12 +164,22 @@ func DownloadFromS3(url string) ([]byte, error) {
 
 func GetKeyChainFromS3(paramsIndex int, keyResponse DownloadKeyResponse, logEnabled bool) (key.KeyChain, error) {
 
+	keyChain, err := GetKeyChainFromS3WithParameters(key.DefaultParameters(paramsIndex), keyResponse, logEnabled)
+	keyChain.ParamsIndex = paramsIndex
+
+	return keyChain, err
+
+}
+
+func GetKeyChainFromS3WithParameters(paramSet key.Parameters, keyResponse DownloadKeyResponse, logEnabled bool) (key.KeyChain, error) {
+
 	log := logger.NewLogger(logEnabled)
 
-	paramSet := bootstrapping.DefaultParametersSparse[paramsIndex]
-	ckksParams := paramSet.SchemeParams
+	Params, err := paramSet.CKKSParameters()
 
-	Params, _ := ckks.NewParametersFromLiteral(ckksParams)
+	if err != nil {
+		return key.KeyChain{}, err
+	}
 
 	// Load pk
 	pkByte, err := DownloadFromS3(keyResponse.Pk)
@@ -241,7 +249,8 @@ func GetKeyChainFromS3(paramsIndex int, keyResponse DownloadKeyResponse, logEnab
 	rotk.Keys = keys
 
 	return key.KeyChain{
-		ParamsIndex: paramsIndex,
+		ParamsIndex: -1,
+		Params: paramSet,
 		SecretKey: nil, 
 		PublicKey: &pk,
 		RelinKey: &rlk,
@@ -0,0 +1,240 @@
+package models
+
+import (
+	"time"
+
+	"github.com/perm-ai/go-cerebrum/layers"
+	"github.com/tuneinsight/lattigo/v4/rlwe"
+)
+
+//=================================================
+//					CALLBACKS
+//=================================================
+
+// Event sent to callbacks at the start and the end of every batch. Duration, levels and loss are only set at the end
+type BatchEvent struct {
+	Epoch          int
+	Batch          int
+	TotalBatch     int
+	LearningRate   float64
+	Duration       time.Duration
+	ForwardLevels  []int   // Output level of each layer after forward propagation, 2D layers first
+	BackwardLevels []int   // Input gradient level of each layer after backward propagation, 2D layers first. -1 if not calculated
+	Loss           float64 // Decrypted loss of the batch. Only set when HasLoss is true
+	HasLoss        bool    // Loss is only computed when the model has callbacks or ComputeLoss and utils has a decryptor
+}
+
+// Event sent to callbacks at the end of every epoch
+type EpochEvent struct {
+	Epoch      int
+	TotalEpoch int
+	Duration   time.Duration
+	Loss       float64 // Mean of decrypted batch losses in the epoch. Only set when HasLoss is true
+	HasLoss    bool
+}
+
+// Event sent to callbacks right after a layer that bootstraps its output or input gradient, as scheduled when the
+// model was created with autoBootstrap, ran during Forward or Backward
+type BootstrapEvent struct {
+	Epoch     int // Position of the batch, only set by training loops
+	Batch     int
+	Layer     int // Index of layer in Layers2d or Layers1d
+	Is2D      bool
+	Direction string // "forward" or "backward"
+	Level     int    // Level of output (forward) or input gradient (backward) of the layer after bootstrapping
+}
+
+// Callback receives events from the training loops of Model. Embed BaseCallback to only implement some of the events
+type Callback interface {
+	OnBatchStart(event BatchEvent)
+	OnBatchEnd(event BatchEvent)
+	OnEpochEnd(event EpochEvent)
+	OnBootstrap(event BootstrapEvent)
+}
+
+// Callback that ignores every event
+type BaseCallback struct{}
+
+func (c BaseCallback) OnBatchStart(event BatchEvent) {}
+
+func (c BaseCallback) OnBatchEnd(event BatchEvent) {}
+
+func (c BaseCallback) OnEpochEnd(event EpochEvent) {}
+
+func (c BaseCallback) OnBootstrap(event BootstrapEvent) {}
+
+// Record decrypted loss of every batch and epoch for plotting loss curves
+type LossHistory struct {
+	BaseCallback
+	BatchLoss []float64
+	EpochLoss []float64
+}
+
+func NewLossHistory() *LossHistory {
+	return &LossHistory{}
+}
+
+func (h *LossHistory) OnBatchEnd(event BatchEvent) {
+	if event.HasLoss {
+		h.BatchLoss = append(h.BatchLoss, event.Loss)
+	}
+}
+
+func (h *LossHistory) OnEpochEnd(event EpochEvent) {
+	if event.HasLoss {
+		h.EpochLoss = append(h.EpochLoss, event.Loss)
+	}
+}
+
+// Stop training when epoch loss hasn't improved by more than MinDelta for Patience epochs
+type EarlyStopping struct {
+	BaseCallback
+	Patience int
+	MinDelta float64
+	model    *Model
+	best     float64
+	waited   int
+	hasBest  bool
+}
+
+func NewEarlyStopping(model *Model, patience int, minDelta float64) *EarlyStopping {
+	return &EarlyStopping{Patience: patience, MinDelta: minDelta, model: model}
+}
+
+func (e *EarlyStopping) OnEpochEnd(event EpochEvent) {
+
+	if !event.HasLoss {
+		return
+	}
+
+	if !e.hasBest || event.Loss < e.best-e.MinDelta {
+		e.best = event.Loss
+		e.hasBest = true
+		e.waited = 0
+		return
+	}
+
+	e.waited++
+	if e.waited >= e.Patience {
+		e.model.StopTraining()
+	}
+
+}
+
+// Add callbacks that receive events from training loops
+func (m *Model) AddCallback(callbacks ...Callback) {
+	m.Callbacks = append(m.Callbacks, callbacks...)
+}
+
+// Stop training loop after the current batch
+func (m *Model) StopTraining() {
+	m.stopTraining = true
+}
+
+func (m Model) onBatchStart(event BatchEvent) {
+	for _, callback := range m.Callbacks {
+		callback.OnBatchStart(event)
+	}
+}
+
+func (m Model) onBatchEnd(event BatchEvent) {
+	for _, callback := range m.Callbacks {
+		callback.OnBatchEnd(event)
+	}
+}
+
+func (m Model) onEpochEnd(event EpochEvent) {
+	for _, callback := range m.Callbacks {
+		callback.OnEpochEnd(event)
+	}
+}
+
+// Send bootstrap event after a layer that was scheduled to bootstrap in direction ran
+func (m Model) onBootstrap(is2D bool, layer int, direction string, level int) {
+
+	event := BootstrapEvent{Epoch: m.epoch, Batch: m.batch, Layer: layer, Is2D: is2D, Direction: direction, Level: level}
+
+	for _, callback := range m.Callbacks {
+		callback.OnBootstrap(event)
+	}
+
+}
+
+// Level of output of each layer after forward propagation
+func (m Model) forwardLevels(output2D []layers.Output2d, output1D []layers.Output1d) []int {
+
+	levels := []int{}
+
+	for layer := range m.Layers2d {
+		output := output2D[layer+1].Output
+		if m.Layers2d[layer].HasActivation() {
+			output = output2D[layer+1].ActivationOutput
+		}
+		levels = append(levels, output[0][0][0].Level())
+	}
+
+	for layer := range m.Layers1d {
+		output := output1D[layer+1].Output
+		if m.Layers1d[layer].HasActivation() {
+			output = output1D[layer+1].ActivationOutput
+		}
+		levels = append(levels, output[0].Level())
+	}
+
+	return levels
+
+}
+
+// Level of input gradient of each layer after backward propagation
+func (m Model) backwardLevels(gradient2D []layers.Gradient2d, gradient1D []layers.Gradient1d) []int {
+
+	levels := []int{}
+
+	for layer := range m.Layers2d {
+		level := -1
+		if len(gradient2D[layer].InputGradient) != 0 && gradient2D[layer].InputGradient[0][0][0] != nil {
+			level = gradient2D[layer].InputGradient[0][0][0].Level()
+		}
+		levels = append(levels, level)
+	}
+
+	for layer := range m.Layers1d {
+		level := -1
+		if len(gradient1D[layer].InputGradient) != 0 && gradient1D[layer].InputGradient[0] != nil {
+			level = gradient1D[layer].InputGradient[0].Level()
+		}
+		levels = append(levels, level)
+	}
+
+	return levels
+
+}
+
+// Calculate encrypted loss of the first batchSize slots and decrypt it. Prediction and label are bootstrapped, or
+// refreshed by the key holder when there's no bootstrapper, if they don't have enough levels for the loss.
+// ok is false when utils has no decryptor
+func (m Model) decryptLoss(pred []*rlwe.Ciphertext, y []*rlwe.Ciphertext, batchSize int) (loss float64, ok bool) {
+
+	if !m.utils.HasDecryptor() {
+		return 0, false
+	}
+
+	refresh := func(cts []*rlwe.Ciphertext) []*rlwe.Ciphertext {
+		refreshed := make([]*rlwe.Ciphertext, len(cts))
+		for i := range cts {
+			refreshed[i] = cts[i].CopyNew()
+			if refreshed[i].Level() >= m.Loss.GetForwardLevelConsumption() {
+				continue
+			}
+			if m.utils.Bootstrapper != nil {
+				m.utils.BootstrapInPlace(refreshed[i])
+			} else {
+				refreshed[i] = m.utils.EncryptToPointer(m.utils.Decrypt(cts[i]))
+			}
+		}
+		return refreshed
+	}
+
+	return m.utils.Decrypt(m.Loss.Forward(refresh(pred), refresh(y), batchSize))[0], true
+
+}
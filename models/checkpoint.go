@@ -0,0 +1,193 @@
+package models
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/perm-ai/go-cerebrum/dataset"
+	"github.com/perm-ai/go-cerebrum/key"
+	"github.com/perm-ai/go-cerebrum/layers"
+	"github.com/perm-ai/go-cerebrum/logger"
+)
+
+//=================================================
+//				 MODEL CHECKPOINT
+//=================================================
+
+// Checkpoint file layout
+// [ magic (8 bytes) | format version (uint32) | sha256 of payload (32 bytes) | payload (gob) ]
+
+const CheckpointFormatVersion = 1
+
+var checkpointMagic = []byte("CRBMCKPT")
+
+const checkpointPreludeSize = 8 + 4 + sha256.Size
+
+// Checkpoint holds the encrypted training state of a model and the position training should resume from
+type Checkpoint struct {
+	Epoch         int // Epoch to resume from (0-indexed)
+	Batch         int // First batch of Epoch that hasn't been trained yet (0-indexed)
+	Layers1d      []layers.LayerCheckpoint
+	Layers2d      []layers.LayerCheckpoint
+	ForwardLevel  [][]int
+	BackwardLevel [][]int
+}
+
+// Save encrypted state of every layer and the training cursor to filename. Training resumes from batch of epoch
+// when the checkpoint is loaded. The file is written to a temporary file first so an interrupted save never
+// replaces a previous checkpoint with a partial one
+func (m Model) SaveCheckpoint(filename string, epoch int, batch int) error {
+
+	checkpoint := Checkpoint{
+		Epoch:         epoch,
+		Batch:         batch,
+		Layers1d:      make([]layers.LayerCheckpoint, len(m.Layers1d)),
+		Layers2d:      make([]layers.LayerCheckpoint, len(m.Layers2d)),
+		ForwardLevel:  m.ForwardLevel,
+		BackwardLevel: m.BackwardLevel,
+	}
+
+	var err error
+
+	for i := range m.Layers1d {
+		if checkpoint.Layers1d[i], err = m.Layers1d[i].Checkpoint(); err != nil {
+			return fmt.Errorf("checkpoint 1D layer %d: %w", i, err)
+		}
+	}
+
+	for i := range m.Layers2d {
+		if checkpoint.Layers2d[i], err = m.Layers2d[i].Checkpoint(); err != nil {
+			return fmt.Errorf("checkpoint 2D layer %d: %w", i, err)
+		}
+	}
+
+	var payload bytes.Buffer
+	if err = gob.NewEncoder(&payload).Encode(checkpoint); err != nil {
+		return err
+	}
+
+	checksum := sha256.Sum256(payload.Bytes())
+
+	file := bytes.NewBuffer(make([]byte, 0, checkpointPreludeSize+payload.Len()))
+	file.Write(checkpointMagic)
+	binary.Write(file, binary.LittleEndian, uint32(CheckpointFormatVersion))
+	file.Write(checksum[:])
+	file.Write(payload.Bytes())
+
+	tmpFilename := filename + ".tmp"
+	if err = os.WriteFile(tmpFilename, file.Bytes(), 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpFilename, filename)
+
+}
+
+// Read checkpoint saved by SaveCheckpoint. Returns *key.CorruptFileError if the file is truncated or modified
+func ReadCheckpoint(filename string) (Checkpoint, error) {
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return Checkpoint{}, err
+	}
+
+	if len(data) < checkpointPreludeSize || !bytes.Equal(data[:8], checkpointMagic) {
+		return Checkpoint{}, &key.CorruptFileError{Location: filename, Err: errors.New("not a model checkpoint")}
+	}
+
+	if version := binary.LittleEndian.Uint32(data[8:12]); version != CheckpointFormatVersion {
+		return Checkpoint{}, &key.CorruptFileError{Location: filename, Err: fmt.Errorf("unsupported checkpoint version %d", version)}
+	}
+
+	payload := data[checkpointPreludeSize:]
+	if checksum := sha256.Sum256(payload); !bytes.Equal(checksum[:], data[12:checkpointPreludeSize]) {
+		return Checkpoint{}, &key.CorruptFileError{Location: filename, Err: errors.New("checksum mismatch")}
+	}
+
+	var checkpoint Checkpoint
+	if err = gob.NewDecoder(bytes.NewReader(payload)).Decode(&checkpoint); err != nil {
+		return Checkpoint{}, &key.CorruptFileError{Location: filename, Err: err}
+	}
+
+	return checkpoint, nil
+
+}
+
+// Restore encrypted state of every layer from checkpoint saved by SaveCheckpoint and return the epoch and batch training
+// should resume from. The model must have the same layers as the model that was saved
+func (m *Model) LoadCheckpoint(filename string) (int, int, error) {
+
+	checkpoint, err := ReadCheckpoint(filename)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if len(checkpoint.Layers1d) != len(m.Layers1d) || len(checkpoint.Layers2d) != len(m.Layers2d) {
+		return 0, 0, fmt.Errorf("checkpoint has %d 1D and %d 2D layers but model has %d 1D and %d 2D layers", len(checkpoint.Layers1d), len(checkpoint.Layers2d), len(m.Layers1d), len(m.Layers2d))
+	}
+
+	for i := range m.Layers1d {
+		if err = m.Layers1d[i].Restore(checkpoint.Layers1d[i]); err != nil {
+			return 0, 0, fmt.Errorf("restore 1D layer %d: %w", i, err)
+		}
+	}
+
+	for i := range m.Layers2d {
+		if err = m.Layers2d[i].Restore(checkpoint.Layers2d[i]); err != nil {
+			return 0, 0, fmt.Errorf("restore 2D layer %d: %w", i, err)
+		}
+	}
+
+	m.ForwardLevel = checkpoint.ForwardLevel
+	m.BackwardLevel = checkpoint.BackwardLevel
+
+	return checkpoint.Epoch, checkpoint.Batch, nil
+
+}
+
+// Train model and save checkpoint to checkpointPath every checkpointEvery batches and at the end of every epoch.
+// If checkpointPath already exists, the model is restored from it and training resumes from the saved position
+func (m *Model) TrainWithCheckpoint(dataLoader dataset.Loader, learningRate float64, batchSize int, epoch int, checkpointPath string, checkpointEvery int) error {
+
+	totalBatch := int(dataLoader.GetLength() / batchSize)
+	log := logger.NewLogger(true)
+
+	startEpoch, startBatch := 0, 0
+
+	if _, err := os.Stat(checkpointPath); err == nil {
+
+		if startEpoch, startBatch, err = m.LoadCheckpoint(checkpointPath); err != nil {
+			return err
+		}
+
+		log.Log(fmt.Sprintf("Resuming from checkpoint at epoch %d/%d batch %d/%d", startEpoch+1, epoch, startBatch+1, totalBatch))
+
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	// Position is always saved when training is stopped or an epoch ends
+	save := func(e int, batch int) error {
+
+		if batch != 0 && !m.stopTraining && (checkpointEvery <= 0 || batch%checkpointEvery != 0) {
+			return nil
+		}
+
+		if err := m.SaveCheckpoint(checkpointPath, e, batch); err != nil {
+			return err
+		}
+
+		log.Log("Checkpoint saved")
+
+		return nil
+
+	}
+
+	return m.train(dataLoader, len(m.Layers2d) != 0, learningRate, batchSize, epoch, startEpoch, startBatch, save)
+
+}
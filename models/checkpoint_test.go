@@ -0,0 +1,89 @@
+package models
+
+import (
+	"errors"
+	"math"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/perm-ai/go-cerebrum/internal/utilitytest"
+	"github.com/perm-ai/go-cerebrum/key"
+	"github.com/perm-ai/go-cerebrum/layers"
+	"github.com/perm-ai/go-cerebrum/losses"
+	"github.com/tuneinsight/lattigo/v4/ckks"
+)
+
+func TestCheckpoint(t *testing.T) {
+
+	utils := utilitytest.NewUtils(key.NewParameters(ckks.PN13QP218, nil), math.Pow(2, 30))
+
+	newModel := func(outputUnit int) Model {
+		dense := layers.NewDense(utils, 3, outputUnit, nil, true, 4, 0.1, 4)
+		return NewModel(utils, []layers.Layer1D{&dense}, []layers.Layer2D{}, losses.MSE{U: utils}, false)
+	}
+
+	saved := newModel(2)
+	savedDense := saved.Layers1d[0].(*layers.Dense)
+
+	for node := range savedDense.Weights {
+		for w := range savedDense.Weights[node] {
+			savedDense.Weights[node][w] = utils.EncryptToLevel(utils.GenerateFilledArraySize(float64(node*3+w)/10, 4), 3)
+		}
+		savedDense.Bias[node] = utils.EncryptToLevel(utils.GenerateFilledArraySize(-float64(node), 4), 3)
+	}
+	savedDense.SetBootstrapOutput(true, "forward")
+
+	checkpointPath := path.Join(t.TempDir(), "model.ckpt")
+	if err := saved.SaveCheckpoint(checkpointPath, 2, 5); err != nil {
+		t.Fatal(err)
+	}
+
+	restored := newModel(2)
+	epoch, batch, err := restored.LoadCheckpoint(checkpointPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if epoch != 2 || batch != 5 {
+		t.Errorf("Expected to resume from epoch 2 batch 5 but got epoch %d batch %d", epoch, batch)
+	}
+
+	restoredDense := restored.Layers1d[0].(*layers.Dense)
+
+	for node := range restoredDense.Weights {
+		for w := range restoredDense.Weights[node] {
+			if restoredDense.Weights[node][w].Level() != 3 {
+				t.Errorf("Weight [%d][%d] should be restored at level 3 but is at level %d", node, w, restoredDense.Weights[node][w].Level())
+			}
+			if value := utils.Decrypt(restoredDense.Weights[node][w])[0]; math.Abs(value-float64(node*3+w)/10) > 1e-3 {
+				t.Errorf("Weight [%d][%d] expected %f but got %f", node, w, float64(node*3+w)/10, value)
+			}
+		}
+		if value := utils.Decrypt(restoredDense.Bias[node])[0]; math.Abs(value+float64(node)) > 1e-3 {
+			t.Errorf("Bias %d expected %f but got %f", node, -float64(node), value)
+		}
+	}
+
+	restoredState, _ := restoredDense.Checkpoint()
+	if !restoredState.BtspOutput[0] {
+		t.Error("Bootstrap flags should be restored")
+	}
+
+	// Restoring into model with different layers
+	mismatched := newModel(3)
+	if _, _, err = mismatched.LoadCheckpoint(checkpointPath); err == nil {
+		t.Error("Restoring checkpoint into layer with different shape should return an error")
+	}
+
+	// Modified checkpoint
+	data, _ := os.ReadFile(checkpointPath)
+	data[len(data)-1] ^= 0xff
+	os.WriteFile(checkpointPath, data, 0644)
+
+	var corruptErr *key.CorruptFileError
+	if _, _, err = restored.LoadCheckpoint(checkpointPath); !errors.As(err, &corruptErr) {
+		t.Errorf("Loading modified checkpoint should return CorruptFileError but got %v", err)
+	}
+
+}
@@ -4,12 +4,14 @@ import (
 	"fmt"
 	"math"
 	"path"
+	"time"
 
 	"github.com/tuneinsight/lattigo/v4/rlwe"
 	"github.com/perm-ai/go-cerebrum/dataset"
 	"github.com/perm-ai/go-cerebrum/layers"
 	"github.com/perm-ai/go-cerebrum/logger"
 	"github.com/perm-ai/go-cerebrum/losses"
+	"github.com/perm-ai/go-cerebrum/optimizers"
 	"github.com/perm-ai/go-cerebrum/utility"
 )
 
@@ -25,6 +27,20 @@ type Model struct {
 	Loss          losses.Loss
 	ForwardLevel  [][]int
 	BackwardLevel [][]int
+	Schedule      optimizers.Schedule // Learning rate schedule used by training loops instead of constant learning rate when set
+	Callbacks     []Callback          // Receive events from training loops
+	ComputeLoss   bool                // Decrypt loss of every batch even when no callback is registered
+	stopTraining  bool
+	bootstraps    map[bootstrapSite]bool // Layers set to bootstrap by setForwardBootstrapping and setBackwardBootstrapping
+	epoch         int                    // Position of the batch being trained, sent with bootstrap events
+	batch         int
+}
+
+// Layer and direction of a scheduled bootstrap
+type bootstrapSite struct {
+	is2D      bool
+	layer     int
+	direction string
 }
 
 func NewModel(utils utility.Utils, layer1d []layers.Layer1D, layer2d []layers.Layer2D, loss losses.Loss, autoBootstrap bool) Model {
@@ -52,8 +68,8 @@ func (m Model) Forward(input2D [][][]*rlwe.Ciphertext, input1D []*rlwe.Ciphertex
 
 	if len(m.Layers2d) != 0 {
 
-		if input2D != nil {
-			panic(fmt.Sprintf("Input2d is not given. Expect input with size %d", m.Layers2d[0].GetOutputSize()))
+		if len(input2D) == 0 {
+			panic("Input2d is not given. Model with 2D layers expects input with size [row, column, channel]")
 		}
 
 		// Initialize slice for storing output
@@ -80,6 +96,14 @@ func (m Model) Forward(input2D [][][]*rlwe.Ciphertext, input1D []*rlwe.Ciphertex
 
 			output2D[layer+1] = m.Layers2d[layer].Forward(utility.Clone3dCiphertext(prevOut))
 
+			if m.bootstraps[bootstrapSite{true, layer, "forward"}] {
+				output := output2D[layer+1].Output
+				if m.Layers2d[layer].HasActivation() {
+					output = output2D[layer+1].ActivationOutput
+				}
+				m.onBootstrap(true, layer, "forward", output[0][0][0].Level())
+			}
+
 			prevLayerHasActivation = m.Layers2d[layer].HasActivation()
 
 		}
@@ -123,6 +147,14 @@ func (m Model) Forward(input2D [][][]*rlwe.Ciphertext, input1D []*rlwe.Ciphertex
 
 			output1D[layer+1] = m.Layers1d[layer].Forward(utility.Clone1dCiphertext(prevOut))
 
+			if m.bootstraps[bootstrapSite{false, layer, "forward"}] {
+				output := output1D[layer+1].Output
+				if m.Layers1d[layer].HasActivation() {
+					output = output1D[layer+1].ActivationOutput
+				}
+				m.onBootstrap(false, layer, "forward", output[0].Level())
+			}
+
 			prevLayerHasActivation = m.Layers1d[layer].HasActivation()
 
 		}
@@ -133,25 +165,53 @@ func (m Model) Forward(input2D [][][]*rlwe.Ciphertext, input1D []*rlwe.Ciphertex
 
 }
 
-func (m Model) Backward(output2D []layers.Output2d, output1D []layers.Output1d, y []*rlwe.Ciphertext) ([]layers.Gradient2d, []layers.Gradient1d) {
+// Evaluate the model for inference only and return the output of the last layer. Input is cloned once and each layer
+// only returns its final output, so intermediate outputs needed by Backward are never kept. Layers bootstrap only
+// when their input doesn't have enough level left instead of following the training bootstrapping schedule
+func (m Model) Predict(input2D [][][]*rlwe.Ciphertext, input1D []*rlwe.Ciphertext) []*rlwe.Ciphertext {
 
-	gradient1D := make([]layers.Gradient1d, len(m.Layers1d)+1)
-	gradient2D := make([]layers.Gradient2d, len(m.Layers2d)+1)
+	var output []*rlwe.Ciphertext
 
-	// Calculate loss gradient
-	var finalOutput []*rlwe.Ciphertext
+	if len(m.Layers2d) != 0 {
 
-	// Get output of last layer
-	if len(m.Layers1d) != 0 {
-		if m.Layers1d[len(m.Layers1d)-1].HasActivation() {
-			finalOutput = output1D[len(m.Layers1d)].ActivationOutput
-		} else {
-			finalOutput = output1D[len(m.Layers1d)].Output
+		if len(input2D) == 0 {
+			panic(fmt.Sprintf("Input2d is not given. Expect input with size %d", m.Flatten.InputSize))
+		}
+
+		output2D := utility.Clone3dCiphertext(input2D)
+
+		for layer := range m.Layers2d {
+			output2D = m.Layers2d[layer].Predict(output2D)
 		}
+
+		output = m.Flatten.Forward(output2D).Output
+
 	} else {
-		finalOutput = output1D[len(output1D)-1].Output
+
+		if len(input1D) == 0 {
+			panic("Input1d is not given")
+		}
+
+		output = utility.Clone1dCiphertext(input1D)
+
 	}
 
+	for layer := range m.Layers1d {
+		output = m.Layers1d[layer].Predict(output)
+	}
+
+	return output
+
+}
+
+func (m Model) Backward(output2D []layers.Output2d, output1D []layers.Output1d, y []*rlwe.Ciphertext) ([]layers.Gradient2d, []layers.Gradient1d) {
+
+	gradient1D := make([]layers.Gradient1d, len(m.Layers1d)+1)
+	gradient2D := make([]layers.Gradient2d, len(m.Layers2d)+1)
+
+	// Calculate loss gradient
+	finalOutput := m.finalOutput(output1D)
+
 	gradient1D[len(gradient1D)-1] = layers.Gradient1d{InputGradient: m.Loss.Backward(utility.Clone1dCiphertext(finalOutput), utility.Clone1dCiphertext(y), len(y))}
 
 	if len(m.Layers1d) != 0 {
@@ -178,6 +238,10 @@ func (m Model) Backward(output2D []layers.Output2d, output1D []layers.Output1d,
 
 			gradient1D[layer] = m.Layers1d[layer].Backward(utility.Clone1dCiphertext(layerInput), utility.Clone1dCiphertext(layerOutput), utility.Clone1dCiphertext(nextLayerInputGrad), (layer != 0 || len(m.Layers2d) != 0))
 
+			if m.bootstraps[bootstrapSite{false, layer, "backward"}] && len(gradient1D[layer].InputGradient) != 0 && gradient1D[layer].InputGradient[0] != nil {
+				m.onBootstrap(false, layer, "backward", gradient1D[layer].InputGradient[0].Level())
+			}
+
 		}
 
 	}
@@ -206,7 +270,11 @@ func (m Model) Backward(output2D []layers.Output2d, output1D []layers.Output1d,
 			// Get layer's output
 			layerOutput := output2D[layer+1].Output
 
-			gradient2D[layer] = m.Layers2d[layer].Backward(utility.Clone3dCiphertext(layerInput), utility.Clone3dCiphertext(layerOutput), utility.Clone3dCiphertext(nextLayerInputGrad), (layer != 0 || len(m.Layers2d) != 0))
+			gradient2D[layer] = m.Layers2d[layer].Backward(utility.Clone3dCiphertext(layerInput), utility.Clone3dCiphertext(layerOutput), utility.Clone3dCiphertext(nextLayerInputGrad), layer != 0)
+
+			if m.bootstraps[bootstrapSite{true, layer, "backward"}] && len(gradient2D[layer].InputGradient) != 0 && gradient2D[layer].InputGradient[0][0][0] != nil {
+				m.onBootstrap(true, layer, "backward", gradient2D[layer].InputGradient[0][0][0].Level())
+			}
 
 		}
 
@@ -216,6 +284,17 @@ func (m Model) Backward(output2D []layers.Output2d, output1D []layers.Output1d,
 
 }
 
+// Get output of last layer
+func (m Model) finalOutput(output1D []layers.Output1d) []*rlwe.Ciphertext {
+
+	if len(m.Layers1d) != 0 && m.Layers1d[len(m.Layers1d)-1].HasActivation() {
+		return output1D[len(m.Layers1d)].ActivationOutput
+	}
+
+	return output1D[len(output1D)-1].Output
+
+}
+
 func (m *Model) UpdateGradient(gradients1d []layers.Gradient1d, gradients2d []layers.Gradient2d, lr float64) {
 
 	for layer := range m.Layers2d {
@@ -232,99 +311,242 @@ func (m *Model) UpdateGradient(gradients1d []layers.Gradient1d, gradients2d []la
 
 }
 
-func (m *Model) Train2D(dataLoader dataset.Loader, learningRate float64, batchSize int, epoch int) {
-
-	for e := 0; e < epoch; e++ {
+// Use optimizer as the update rule of every layer. Each layer gets its own copy created with optimizer.New()
+// so encrypted optimizer state is never shared between layers
+func (m *Model) SetOptimizer(optimizer optimizers.Optimizer) {
 
-		for i := 0; i < int(dataLoader.GetLength()/batchSize); i++ {
+	for layer := range m.Layers2d {
+		m.Layers2d[layer].SetOptimizer(optimizer.New())
+	}
 
-			x, y := dataLoader.Load2D(i*batchSize, batchSize)
+	for layer := range m.Layers1d {
+		m.Layers1d[layer].SetOptimizer(optimizer.New())
+	}
 
-			outputs2D, outputs1D := m.Forward(x, []*rlwe.Ciphertext{})
-			gradients2D, gradients1D := m.Backward(outputs2D, outputs1D, y)
-			m.UpdateGradient(gradients1D, gradients2D, learningRate)
+}
 
-		}
+// Learning rate of a batch from Schedule or learningRate if model has no schedule
+func (m Model) learningRate(learningRate float64, epoch int, batch int, totalBatch int) float64 {
 
+	if m.Schedule == nil {
+		return learningRate
 	}
 
+	return m.Schedule.LearningRate(epoch, batch, totalBatch)
+
 }
 
-func (m *Model) Train1D(dataLoader dataset.Loader, learningRate float64, batchSize int, epoch int) {
+// Train model on a batch loaded with Load2D if load2D is set or Load1D otherwise and send batch events to callbacks.
+// Return decrypted loss of the batch when it's available
+func (m *Model) trainBatch(dataLoader dataset.Loader, load2D bool, learningRate float64, batchSize int, epoch int, totalEpoch int, batch int, totalBatch int, log logger.Logger) (float64, bool) {
 
-	totalBatch := int(dataLoader.GetLength() / batchSize)
-	log := logger.NewLogger(true)
+	m.epoch, m.batch = epoch, batch
+
+	event := BatchEvent{Epoch: epoch, Batch: batch, TotalBatch: totalBatch, LearningRate: m.learningRate(learningRate, epoch, batch, totalBatch)}
+	m.onBatchStart(event)
 
-	for e := 0; e < epoch; e++ {
+	start := time.Now()
+	log.Log(fmt.Sprintf("Epoch : %d/%d\t\tBatch : %d/%d", epoch+1, totalEpoch, batch+1, totalBatch))
 
-		for i := 0; i < totalBatch; i++ {
+	var outputs2D []layers.Output2d
+	var outputs1D []layers.Output1d
+	var y []*rlwe.Ciphertext
 
-			log.Log(fmt.Sprintf("Epoch : %d/%d\t\tBatch : %d/%d", e+1, epoch, i+1, totalBatch))
+	if load2D {
 
-			x, y := dataLoader.Load1D(i*batchSize, batchSize)
+		var x [][][]*rlwe.Ciphertext
+		x, y = dataLoader.Load2D(batch*batchSize, batchSize)
 
-			log.Log("Data loaded")
+		log.Log("Data loaded")
 
-			outputs2D, outputs1D := m.Forward([][][]*rlwe.Ciphertext{}, x)
+		outputs2D, outputs1D = m.Forward(x, []*rlwe.Ciphertext{})
 
-			log.Log("Forward complete")
+	} else {
 
-			gradients2D, gradients1D := m.Backward(outputs2D, outputs1D, y)
+		var x []*rlwe.Ciphertext
+		x, y = dataLoader.Load1D(batch*batchSize, batchSize)
 
-			log.Log("Backward complete")
+		log.Log("Data loaded")
 
-			m.UpdateGradient(gradients1D, gradients2D, learningRate)
+		outputs2D, outputs1D = m.Forward([][][]*rlwe.Ciphertext{}, x)
 
-		}
+	}
+
+	log.Log("Forward complete")
 
+	gradients2D, gradients1D := m.Backward(outputs2D, outputs1D, y)
+
+	log.Log("Backward complete")
+
+	event.ForwardLevels = m.forwardLevels(outputs2D, outputs1D)
+	event.BackwardLevels = m.backwardLevels(gradients2D, gradients1D)
+
+	// Loss costs a forward pass of the loss function so it's only computed when someone receives it
+	if len(m.Callbacks) != 0 || m.ComputeLoss {
+		event.Loss, event.HasLoss = m.decryptLoss(m.finalOutput(outputs1D), y, batchSize)
+	}
+
+	log.Log(fmt.Sprintf("Learning rate : %f", event.LearningRate))
+
+	m.UpdateGradient(gradients1D, gradients2D, event.LearningRate)
+
+	event.Duration = time.Since(start)
+
+	m.onBatchEnd(event)
+
+	return event.Loss, event.HasLoss
+
+}
+
+// Send epoch event with mean of decrypted batch losses to callbacks
+func (m Model) endEpoch(epoch int, totalEpoch int, start time.Time, batchLoss []float64) {
+
+	event := EpochEvent{Epoch: epoch, TotalEpoch: totalEpoch, Duration: time.Since(start), HasLoss: len(batchLoss) != 0}
+
+	for _, loss := range batchLoss {
+		event.Loss += loss / float64(len(batchLoss))
 	}
 
+	m.onEpochEnd(event)
+
+}
+
+func (m *Model) Train2D(dataLoader dataset.Loader, learningRate float64, batchSize int, epoch int) {
+	m.train(dataLoader, true, learningRate, batchSize, epoch, 0, 0, nil)
+}
+
+func (m *Model) Train1D(dataLoader dataset.Loader, learningRate float64, batchSize int, epoch int) {
+	m.train(dataLoader, false, learningRate, batchSize, epoch, 0, 0, nil)
 }
 
+// Resume training from batch startBatch of epoch startEpoch, eg. at the position returned by LoadCheckpoint
 func (m *Model) Train1DFrom(dataLoader dataset.Loader, learningRate float64, batchSize int, epoch int, startEpoch int, startBatch int) {
+	m.train(dataLoader, false, learningRate, batchSize, epoch, startEpoch, startBatch, nil)
+}
+
+// Train from batch startBatch of epoch startEpoch until epoch or until training is stopped. checkpoint, if not nil,
+// is called with the position training would resume from after every batch but the last of an epoch and after the
+// end of every complete epoch. Training stops with the error checkpoint returns
+func (m *Model) train(dataLoader dataset.Loader, load2D bool, learningRate float64, batchSize int, epoch int, startEpoch int, startBatch int, checkpoint func(epoch int, batch int) error) error {
 
 	totalBatch := int(dataLoader.GetLength() / batchSize)
 	log := logger.NewLogger(true)
+	m.stopTraining = false
+
+	for e := startEpoch; e < epoch && !m.stopTraining; e++ {
 
-	for e := 0; e < epoch; e++ {
+		epochStart := time.Now()
+		batchLoss := []float64{}
 
-		for i := 0; i < totalBatch; i++ {
+		batch := 0
+		if e == startEpoch {
+			batch = startBatch
+		}
+
+		for ; batch < totalBatch && !m.stopTraining; batch++ {
 
-			log.Log(fmt.Sprintf("Epoch : %d/%d\t\tBatch : %d/%d", e+1, epoch, i+1, totalBatch))
+			if loss, ok := m.trainBatch(dataLoader, load2D, learningRate, batchSize, e, epoch, batch, totalBatch, log); ok {
+				batchLoss = append(batchLoss, loss)
+			}
 
-			if e+1 <= startEpoch {
-				if i+1 <= startBatch {
-					continue
+			if checkpoint != nil && batch+1 != totalBatch {
+				if err := checkpoint(e, batch+1); err != nil {
+					return err
 				}
 			}
 
-			x, y := dataLoader.Load1D(i*batchSize, batchSize)
+		}
+
+		m.endEpoch(e, epoch, epochStart, batchLoss)
+
+		// Epoch stopped before its last batch resumes from the position saved after its last trained batch
+		if checkpoint != nil && batch == totalBatch {
+			if err := checkpoint(e+1, 0); err != nil {
+				return err
+			}
+		}
 
-			log.Log("Data loaded")
+	}
 
-			outputs2D, outputs1D := m.Forward([][][]*rlwe.Ciphertext{}, x)
+	return nil
 
-			log.Log("Forward complete")
+}
 
-			gradients2D, gradients1D := m.Backward(outputs2D, outputs1D, y)
+// Plan the exact rotations performed when training the model. Use planner.Rotations() with
+// key.GenerateKeysForRotations to generate only the galois keys the model needs. Rotations of the data loader
+// are added separately, e.g. with StandardLoader.PlanRotations
+func (m Model) PlanRotations() *utility.RotationPlanner {
 
-			log.Log("Backward complete")
+	planner := utility.NewRotationPlanner(m.utils.Params)
 
-			m.UpdateGradient(gradients1D, gradients2D, learningRate)
+	for i := range m.Layers2d {
+		m.Layers2d[i].PlanRotations(planner)
+	}
 
-		}
+	for i := range m.Layers1d {
+		m.Layers1d[i].PlanRotations(planner)
+	}
 
+	if m.Loss != nil {
+		m.Loss.PlanRotations(planner)
 	}
 
+	return planner
+
 }
 
-func (m Model) ExportModel1D(dirPath string) {
+func (m Model) ExportModel1D(dirPath string) error {
 
 	for layer := range m.Layers1d {
 		fmt.Printf("Exporting 1D weight layer %d/%d\n", layer+1, len(m.Layers1d))
-		m.Layers1d[layer].ExportWeights(path.Join(dirPath, fmt.Sprintf("layer_%d.json", layer)))
+		if err := m.Layers1d[layer].ExportWeights(path.Join(dirPath, fmt.Sprintf("layer_%d.json", layer))); err != nil {
+			return err
+		}
+	}
+
+	return nil
+
+}
+
+// Load weights exported by ExportModel1D as plaintext weights used by Predict
+func (m Model) LoadPlainModel1D(dirPath string) error {
+
+	for layer := range m.Layers1d {
+		if err := m.Layers1d[layer].LoadPlainWeights(path.Join(dirPath, fmt.Sprintf("layer_%d.json", layer))); err != nil {
+			return err
+		}
+	}
+
+	return nil
+
+}
+
+// Set layer to bootstrap its output, or its activation output when activation is set, in direction and remember it
+// so bootstrap events are sent after the layer ran
+func (m *Model) scheduleBootstrap(is2D bool, layer int, direction string, activation bool) {
+
+	var target interface {
+		SetBootstrapOutput(set bool, direction string)
+		SetBootstrapActivation(set bool, direction string)
+	}
+
+	if is2D {
+		target = m.Layers2d[layer]
+	} else {
+		target = m.Layers1d[layer]
 	}
 
+	if activation {
+		target.SetBootstrapActivation(true, direction)
+	} else {
+		target.SetBootstrapOutput(true, direction)
+	}
+
+	if m.bootstraps == nil {
+		m.bootstraps = make(map[bootstrapSite]bool)
+	}
+	m.bootstraps[bootstrapSite{is2D, layer, direction}] = true
+
 }
 
 func (m *Model) setForwardBootstrapping() {
@@ -339,13 +561,14 @@ func (m *Model) setForwardBootstrapping() {
 		// Calculate required level for this layer
 		requiredLevel := m.Layers2d[l].GetForwardLevelConsumption() + m.Layers2d[l].GetForwardActivationLevelConsumption()
 
-		if (inputLevel2D[l] - requiredLevel) < 1 {
+		// Input of the first layer is fresh and layers needing more than a bootstrap bootstrap their own input
+		if requiredLevel < 9 && l > 0 && (inputLevel2D[l]-requiredLevel) < 1 {
 
 			// If not enough level bootstrap output of previous layer
 			if m.Layers2d[l-1].HasActivation() {
-				m.Layers2d[l-1].SetBootstrapActivation(true, "forward")
+				m.scheduleBootstrap(true, l-1, "forward", true)
 			} else {
-				m.Layers2d[l-1].SetBootstrapOutput(true, "forward")
+				m.scheduleBootstrap(true, l-1, "forward", false)
 			}
 
 			// Set input to this layer to 9 (highest)
@@ -374,11 +597,12 @@ func (m *Model) setForwardBootstrapping() {
 
 			if (inputLevel1D[l] - requiredLevel) < 1 {
 
-				// If not enough level bootstrap output of previous layer
-				if m.Layers1d[l-1].HasActivation() {
-					m.Layers1d[l-1].SetBootstrapActivation(true, "forward")
-				} else {
-					m.Layers1d[l-1].SetBootstrapOutput(true, "forward")
+				// If not enough level bootstrap output of previous layer, which is the last 2D layer for the first 1D layer
+				if l > 0 {
+					m.scheduleBootstrap(false, l-1, "forward", m.Layers1d[l-1].HasActivation())
+				} else if last := len(m.Layers2d) - 1; last >= 0 {
+					m.scheduleBootstrap(true, last, "forward", m.Layers2d[last].HasActivation())
+					inputLevel2D[last+1] = 9
 				}
 
 				// Set input to this layer to 9 (highest)
@@ -395,11 +619,12 @@ func (m *Model) setForwardBootstrapping() {
 
 			if inputLevel1D[l]-m.Layers1d[l].GetForwardLevelConsumption() < 1 {
 
-				// If not enough level bootstrap output of previous layer
-				if m.Layers1d[l-1].HasActivation() {
-					m.Layers1d[l-1].SetBootstrapActivation(true, "forward")
-				} else {
-					m.Layers1d[l-1].SetBootstrapOutput(true, "forward")
+				// If not enough level bootstrap output of previous layer, which is the last 2D layer for the first 1D layer
+				if l > 0 {
+					m.scheduleBootstrap(false, l-1, "forward", m.Layers1d[l-1].HasActivation())
+				} else if last := len(m.Layers2d) - 1; last >= 0 {
+					m.scheduleBootstrap(true, last, "forward", m.Layers2d[last].HasActivation())
+					inputLevel2D[last+1] = 9
 				}
 
 				// Set input to this layer to 9 (highest)
@@ -407,7 +632,7 @@ func (m *Model) setForwardBootstrapping() {
 
 			}
 
-			m.Layers1d[len(m.Layers1d)-1].SetBootstrapOutput(true, "forward")
+			m.scheduleBootstrap(false, len(m.Layers1d)-1, "forward", false)
 
 			// Set output level of this layer (input of next layer)
 			inputLevel1D[l+1] = 9 - m.Layers1d[l].GetForwardActivationLevelConsumption()
@@ -417,9 +642,9 @@ func (m *Model) setForwardBootstrapping() {
 
 	// Set bootstrap output for last layer
 	if m.Layers1d[len(m.Layers1d)-1].HasActivation() {
-		m.Layers1d[len(m.Layers1d)-1].SetBootstrapActivation(true, "forward")
+		m.scheduleBootstrap(false, len(m.Layers1d)-1, "forward", true)
 	} else {
-		m.Layers1d[len(m.Layers1d)-1].SetBootstrapOutput(true, "forward")
+		m.scheduleBootstrap(false, len(m.Layers1d)-1, "forward", false)
 	}
 
 	inputLevel1D[len(inputLevel1D)-1] = 9
@@ -433,7 +658,11 @@ func (m *Model) setBackwardBootstrapping(inputLevel1D []int, inputLevel2D []int)
 	gradientLevel1D := make([]int, len(m.Layers1d)+1)
 	gradientLevel2D := make([]int, len(m.Layers2d)+1)
 
+	// Loss gradient is calculated from the final output
 	gradientLevel1D[len(gradientLevel1D)-1] = inputLevel1D[len(inputLevel1D)-1]
+	if m.Loss != nil {
+		gradientLevel1D[len(gradientLevel1D)-1] -= m.Loss.GetBackwardLevelConsumption()
+	}
 
 	// Loop throught each layer backward
 	for l := len(m.Layers1d) - 1; l >= 0; l-- {
@@ -451,7 +680,7 @@ func (m *Model) setBackwardBootstrapping(inputLevel1D []int, inputLevel2D []int)
 			// Check if loss gradient wrt input has enough level to multiply once with gradient of activation wrt output
 			if gradientLevel < 2 {
 				// if not enough bootstrap output of previous layer
-				m.Layers1d[l+1].SetBootstrapOutput(true, "backward")
+				m.scheduleBootstrap(false, l+1, "backward", false)
 				gradientLevel1D[l+1] = 9
 				gradientLevel = gradientLevel1D[l+1]
 			}
@@ -462,7 +691,7 @@ func (m *Model) setBackwardBootstrapping(inputLevel1D []int, inputLevel2D []int)
 				activationLossGradientLevel = int(math.Min(float64(gradientLevel), float64(activationLevel)) - 1)
 			} else {
 				// Bootstrap activation gradient wrt output before computing loss gradient wrt output if not enough level is reached
-				m.Layers1d[l].SetBootstrapActivation(true, "backward")
+				m.scheduleBootstrap(false, l, "backward", true)
 				activationLossGradientLevel = int(math.Min(float64(gradientLevel), 9.0) - 1)
 			}
 
@@ -472,7 +701,7 @@ func (m *Model) setBackwardBootstrapping(inputLevel1D []int, inputLevel2D []int)
 			// Check if loss gradient wrt input is less than one
 			if gradientLevel1D[l] < 1 && activationLossGradientLevel < inputLevel {
 				// Bootstrap loss gradient wrt output if it is responsible for making the level of loss wrt input less than 1
-				m.Layers1d[l].SetBootstrapActivation(true, "backward")
+				m.scheduleBootstrap(false, l, "backward", true)
 				activationLossGradientLevel = 9
 
 				// recalculate level of loss gradient wrt input
@@ -486,7 +715,7 @@ func (m *Model) setBackwardBootstrapping(inputLevel1D []int, inputLevel2D []int)
 
 			// Bootstrap loss gradient wrt input of next layer if level is not enough
 			if gradientLevel1D[l] < 1 {
-				m.Layers1d[l-1].SetBootstrapOutput(true, "backward")
+				m.scheduleBootstrap(false, l-1, "backward", false)
 				gradientLevel1D[l-1] = 9
 				gradientLevel1D[l] = int(math.Min(float64(inputLevel), float64(gradientLevel1D[l-1])) - float64(m.Layers1d[l].GetBackwardLevelConsumption()))
 			}
@@ -498,7 +727,7 @@ func (m *Model) setBackwardBootstrapping(inputLevel1D []int, inputLevel2D []int)
 
 			// Bootstrap loss gradient wrt input of next layer if level is not enough
 			if gradientLevel1D[l] < 1 {
-				m.Layers1d[l-1].SetBootstrapOutput(true, "backward")
+				m.scheduleBootstrap(false, l-1, "backward", false)
 				gradientLevel1D[l-1] = 9
 				gradientLevel1D[l] = int(math.Min(float64(inputLevel), float64(gradientLevel1D[l-1])) - float64(m.Layers1d[l].GetBackwardLevelConsumption()))
 			}
@@ -530,7 +759,7 @@ func (m *Model) setBackwardBootstrapping(inputLevel1D []int, inputLevel2D []int)
 			// Check if loss gradient wrt input has enough level to multiply once with gradient of activation wrt output
 			if gradientLevel < 2 {
 				// if not enough bootstrap output of previous layer
-				m.Layers2d[l+1].SetBootstrapOutput(true, "backward")
+				m.scheduleBootstrap(true, l+1, "backward", false)
 				gradientLevel2D[l+1] = 9
 				gradientLevel = gradientLevel2D[l+1]
 			}
@@ -541,7 +770,7 @@ func (m *Model) setBackwardBootstrapping(inputLevel1D []int, inputLevel2D []int)
 				activationLossGradientLevel = int(math.Min(float64(gradientLevel), float64(activationLevel)) - 1)
 			} else {
 				// Bootstrap activation gradient wrt output before computing loss gradient wrt output if not enough level is reached
-				m.Layers2d[l].SetBootstrapActivation(true, "backward")
+				m.scheduleBootstrap(true, l, "backward", true)
 				activationLossGradientLevel = int(math.Min(float64(gradientLevel), 9.0) - 1)
 			}
 
@@ -551,7 +780,7 @@ func (m *Model) setBackwardBootstrapping(inputLevel1D []int, inputLevel2D []int)
 			// Check if loss gradient wrt input is less than one
 			if gradientLevel2D[l] < 1 && activationLossGradientLevel < inputLevel {
 				// Bootstrap loss gradient wrt output if it is responsible for making the level of loss wrt input less than 1
-				m.Layers2d[l].SetBootstrapActivation(true, "backward")
+				m.scheduleBootstrap(true, l, "backward", true)
 				activationLossGradientLevel = 9
 
 				// recalculate level of loss gradient wrt input
@@ -565,7 +794,7 @@ func (m *Model) setBackwardBootstrapping(inputLevel1D []int, inputLevel2D []int)
 
 			// Bootstrap loss gradient wrt input of next layer if level is not enough
 			if gradientLevel2D[l] < 1 {
-				m.Layers2d[l-1].SetBootstrapOutput(true, "backward")
+				m.scheduleBootstrap(true, l-1, "backward", false)
 				gradientLevel2D[l-1] = 9
 				gradientLevel2D[l] = int(math.Min(float64(inputLevel), float64(gradientLevel2D[l-1])) - float64(m.Layers2d[l].GetBackwardLevelConsumption()))
 			}
@@ -575,9 +804,9 @@ func (m *Model) setBackwardBootstrapping(inputLevel1D []int, inputLevel2D []int)
 			// calculate level of loss gradient wrt input
 			gradientLevel2D[l] = gradientLevel - m.Layers2d[l].GetBackwardLevelConsumption()
 
-			// Bootstrap loss gradient wrt input of next layer if level is not enough
-			if gradientLevel2D[l] < 1 {
-				m.Layers2d[l-1].SetBootstrapOutput(true, "backward")
+			// Bootstrap loss gradient wrt input of next layer if level is not enough. Gradient wrt input of the first layer isn't used
+			if l > 0 && gradientLevel2D[l] < 1 {
+				m.scheduleBootstrap(true, l-1, "backward", false)
 				gradientLevel2D[l-1] = 9
 				gradientLevel2D[l] = int(math.Min(float64(inputLevel), float64(gradientLevel2D[l-1])) - float64(m.Layers2d[l].GetBackwardLevelConsumption()))
 			}
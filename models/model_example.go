@@ -48,7 +48,7 @@ func ModelCreationExample() {
 	tanh = activations.NewTanh(utils)
 
 	var relu activations.Activation
-	relu = activations.Relu{utils}
+	relu = activations.Relu{U: utils}
 
 	var smx activations.Activation
 	smx = activations.NewSoftmax(utils)
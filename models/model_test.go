@@ -0,0 +1,335 @@
+package models
+
+import (
+	"math"
+	"path"
+	"testing"
+
+	"github.com/perm-ai/go-cerebrum/dataset"
+	"github.com/perm-ai/go-cerebrum/internal/utilitytest"
+	"github.com/perm-ai/go-cerebrum/key"
+	"github.com/perm-ai/go-cerebrum/layers"
+	"github.com/perm-ai/go-cerebrum/logger"
+	"github.com/perm-ai/go-cerebrum/losses"
+	"github.com/perm-ai/go-cerebrum/utility"
+	"github.com/tuneinsight/lattigo/v4/ckks"
+	"github.com/tuneinsight/lattigo/v4/rlwe"
+)
+
+func TestTrain2D(t *testing.T) {
+
+	// Backward of a convolutional model needs more levels than the smaller parameters have. Batches of 3 data
+	// start at slot 3, which isn't a power of two
+	utils := utilitytest.NewUtils(key.NewParameters(ckks.PN15QP880, nil), math.Pow(2, 40), (*utility.RotationPlanner).AddSumElements, func(planner *utility.RotationPlanner) {
+		dataset.StandardLoader{Length: 4}.PlanRotations(planner, 3)
+	})
+
+	// 3x3 single channel images where label is the mean of the image
+	images := [][][]float64{
+		{{0.1, 0.2, 0.3}, {0.4, 0.5, 0.6}, {0.7, 0.8, 0.9}},
+		{{0.9, 0.1, 0.2}, {0.3, 0.4, 0.1}, {0.2, 0.5, 0.3}},
+		{{0.5, 0.5, 0.5}, {0.2, 0.2, 0.2}, {0.8, 0.1, 0.4}},
+		{{0.3, 0.7, 0.1}, {0.6, 0.2, 0.9}, {0.4, 0.3, 0.5}},
+	}
+
+	labels := make([]float64, len(images))
+	pixels := make([][][]float64, 3)
+	for r := range pixels {
+		pixels[r] = make([][]float64, 3)
+		for c := range pixels[r] {
+			pixels[r][c] = make([]float64, len(images))
+			for i := range images {
+				pixels[r][c][i] = images[i][r][c]
+				labels[i] += images[i][r][c] / 9
+			}
+		}
+	}
+
+	x := make([][][]*rlwe.Ciphertext, 3)
+	for r := range x {
+		x[r] = make([][]*rlwe.Ciphertext, 3)
+		for c := range x[r] {
+			x[r][c] = []*rlwe.Ciphertext{utils.EncryptToLevel(pixels[r][c], 9)}
+		}
+	}
+
+	loader := dataset.NewStandardLoader2D(x, []*rlwe.Ciphertext{utils.EncryptToLevel(labels, 9)}, utils, len(images))
+
+	// Second batch holds the last data and is moved to the first slot by both loaders
+	batchX, batchY := loader.Load2D(3, 3)
+	if value := utils.Decrypt(batchX[1][2][0])[0]; math.Abs(value-images[3][1][2]) > 1e-3 {
+		t.Errorf("Load2D: pixel of data 3 expected %f but got %f", images[3][1][2], value)
+	}
+	if value := utils.Decrypt(batchY[0])[0]; math.Abs(value-labels[3]) > 1e-3 {
+		t.Errorf("Load2D: label of data 3 expected %f but got %f", labels[3], value)
+	}
+	if value := utils.Decrypt(batchX[1][2][0])[1]; math.Abs(value) > 1e-3 {
+		t.Errorf("Load2D: data outside of batch should be masked but got %f", value)
+	}
+
+	loader1D := dataset.NewStandardLoader(map[string]*rlwe.Ciphertext{"x": x[1][2][0]}, []string{"x"}, []*rlwe.Ciphertext{utils.EncryptToLevel(labels, 9)}, utils, len(images))
+	batch1D, _ := loader1D.Load1D(3, 3)
+	if value := utils.Decrypt(batch1D[0])[0]; math.Abs(value-images[3][1][2]) > 1e-3 {
+		t.Errorf("Load1D: data 3 expected %f but got %f", images[3][1][2], value)
+	}
+
+	// Weights are only updated once so they don't need bootstrapping
+	batchSize := len(images)
+	conv := layers.NewConv2D(utils, 1, []int{2, 2}, []int{1, 1}, false, nil, true, []int{3, 3, 1}, batchSize)
+	pool := layers.NewPoolingLayer(utils, []int{2, 2, 1}, []int{2, 2}, []int{2, 2})
+	dense := layers.NewDense(utils, 1, 1, nil, true, batchSize, 0.1, 12)
+	conv.SetWeightLevel(1)
+	dense.SetWeightLevel(1)
+
+	// Large enough weight so gradient reaching the kernel isn't negligible
+	dense.Weights[0][0] = utils.EncryptToLevelScale(utils.GenerateFilledArraySize(0.8, batchSize), 12, math.Pow(2, 40))
+
+	model := NewModel(utils, []layers.Layer1D{&dense}, []layers.Layer2D{&conv, &pool}, losses.MSE{U: utils}, false)
+
+	kernel := make([][]float64, 2)
+	for r := range kernel {
+		kernel[r] = make([]float64, 2)
+		for c := range kernel[r] {
+			kernel[r][c] = utils.Decrypt(conv.Kernels[0].Data[r][c][0])[0]
+		}
+	}
+	convBias := utils.Decrypt(conv.Bias[0])[0]
+	weight := utils.Decrypt(dense.Weights[0][0])[0]
+	denseBias := utils.Decrypt(dense.Bias[0])[0]
+
+	// Plaintext reference of a single SGD step
+	lr := 0.5
+	kernelGradient := [][]float64{{0, 0}, {0, 0}}
+	convBiasGradient, weightGradient, denseBiasGradient := 0.0, 0.0, 0.0
+
+	for i, image := range images {
+
+		pooled := 0.0
+		for r := 0; r < 2; r++ {
+			for c := 0; c < 2; c++ {
+				pooled += (kernel[0][0]*image[r][c] + kernel[0][1]*image[r][c+1] + kernel[1][0]*image[r+1][c] + kernel[1][1]*image[r+1][c+1] + convBias) / 4
+			}
+		}
+
+		gradient := weight*pooled + denseBias - labels[i]
+		weightGradient += gradient * pooled
+		denseBiasGradient += gradient
+
+		// Every convolution output is averaged into the single pooled value
+		outputGradient := weight * gradient / 4
+		convBiasGradient += 4 * outputGradient
+		for kr := range kernelGradient {
+			for kc := range kernelGradient[kr] {
+				for r := 0; r < 2; r++ {
+					for c := 0; c < 2; c++ {
+						kernelGradient[kr][kc] += outputGradient * image[r+kr][c+kc]
+					}
+				}
+			}
+		}
+
+	}
+
+	model.Train2D(loader, lr, batchSize, 1)
+
+	scale := lr / float64(batchSize)
+
+	for kr := range kernel {
+		for kc := range kernel[kr] {
+			expected := kernel[kr][kc] - scale*kernelGradient[kr][kc]
+			if value := utils.Decrypt(conv.Kernels[0].Data[kr][kc][0])[0]; math.Abs(value-expected) > 1e-3 {
+				t.Errorf("Kernel [%d][%d] expected %f but got %f", kr, kc, expected, value)
+			}
+		}
+	}
+
+	expected := map[string][]float64{
+		"conv bias":    {convBias - scale*convBiasGradient, utils.Decrypt(conv.Bias[0])[0]},
+		"dense weight": {weight - scale*weightGradient, utils.Decrypt(dense.Weights[0][0])[0]},
+		"dense bias":   {denseBias - scale*denseBiasGradient, utils.Decrypt(dense.Bias[0])[0]},
+	}
+
+	for name, values := range expected {
+		if math.Abs(values[0]-values[1]) > 1e-3 {
+			t.Errorf("Updated %s expected %f but got %f", name, values[0], values[1])
+		}
+	}
+
+}
+
+// Callback that records events and re-encrypts weights after every batch in place of bootstrapping
+type recordingCallback struct {
+	BaseCallback
+	utils      utility.Utils
+	dense      *layers.Dense
+	batches    []BatchEvent
+	epochs     []EpochEvent
+	bootstraps []BootstrapEvent
+}
+
+func (c *recordingCallback) OnBatchEnd(event BatchEvent) {
+
+	c.batches = append(c.batches, event)
+
+	weight := c.utils.Decrypt(c.dense.Weights[0][0])[0]
+	bias := c.utils.Decrypt(c.dense.Bias[0])[0]
+	c.dense.Weights[0][0] = c.utils.EncryptToLevelScale(c.utils.GenerateFilledArraySize(weight, 4), 12, math.Pow(2, 40))
+	c.dense.Bias[0] = c.utils.EncryptToLevelScale(c.utils.GenerateFilledArraySize(bias, 4), 12, math.Pow(2, 40))
+
+}
+
+func (c *recordingCallback) OnEpochEnd(event EpochEvent) {
+	c.epochs = append(c.epochs, event)
+}
+
+func (c *recordingCallback) OnBootstrap(event BootstrapEvent) {
+	c.bootstraps = append(c.bootstraps, event)
+}
+
+func TestCallbacks(t *testing.T) {
+
+	utils := utilitytest.NewUtils(key.NewParameters(ckks.PN15QP880, nil), math.Pow(2, 40), (*utility.RotationPlanner).AddSumElements)
+
+	x := []float64{0.1, 0.4, 0.6, 0.9}
+	y := make([]float64, len(x))
+	for i := range x {
+		y[i] = 0.5*x[i] + 0.2
+	}
+
+	loader := dataset.NewStandardLoader(map[string]*rlwe.Ciphertext{"x": utils.EncryptToLevel(x, 9)}, []string{"x"}, []*rlwe.Ciphertext{utils.EncryptToLevel(y, 9)}, utils, len(x))
+
+	dense := layers.NewDense(utils, 1, 1, nil, true, len(x), 0.5, 12)
+	dense.SetWeightLevel(1)
+
+	model := NewModel(utils, []layers.Layer1D{&dense}, []layers.Layer2D{}, losses.MSE{U: utils}, false)
+
+	recorder := &recordingCallback{utils: utils, dense: &dense}
+	history := NewLossHistory()
+
+	// Improvement of loss is always smaller than MinDelta so training stops after Patience epochs without improvement
+	model.AddCallback(recorder, history, NewEarlyStopping(&model, 2, 1))
+	model.Train1D(loader, 0.5, len(x), 10)
+
+	if len(recorder.epochs) != 3 || len(recorder.batches) != 3 {
+		t.Fatalf("Early stopping should stop training after 3 epochs but %d epochs and %d batches were trained", len(recorder.epochs), len(recorder.batches))
+	}
+
+	// Input loses a level to batch masking and another to the dense layer
+	for i, event := range recorder.batches {
+		if event.Epoch != i || event.Batch != 0 || event.TotalBatch != 1 || event.LearningRate != 0.5 {
+			t.Errorf("Batch event %d has unexpected position %+v", i, event)
+		}
+		if !event.HasLoss || len(event.ForwardLevels) != 1 || event.ForwardLevels[0] != 7 || event.BackwardLevels[0] != -1 {
+			t.Errorf("Batch event %d has unexpected levels or loss %+v", i, event)
+		}
+	}
+
+	if len(history.EpochLoss) != 3 || history.EpochLoss[2] >= history.EpochLoss[0] {
+		t.Errorf("Loss should decrease over epochs but got %v", history.EpochLoss)
+	}
+
+	if len(recorder.bootstraps) != 0 {
+		t.Errorf("Model without bootstrapping shouldn't send bootstrap event but got %d", len(recorder.bootstraps))
+	}
+
+	// Loss costs a forward pass of the loss function so it's skipped when nobody receives it
+	quiet := NewModel(utils, []layers.Layer1D{&dense}, []layers.Layer2D{}, losses.MSE{U: utils}, false)
+	if _, ok := quiet.trainBatch(loader, false, 0.5, len(x), 0, 1, 0, 1, logger.NewLogger(false)); ok {
+		t.Error("Model without callbacks shouldn't compute loss unless ComputeLoss is set")
+	}
+
+}
+
+func TestTrainResume(t *testing.T) {
+
+	utils := utilitytest.NewUtils(key.NewParameters(ckks.PN15QP880, nil), math.Pow(2, 40), (*utility.RotationPlanner).AddSumElements)
+
+	// 2 batches of 4 data per epoch
+	x := []float64{0.1, 0.4, 0.6, 0.9, 0.2, 0.3, 0.7, 0.8}
+	y := make([]float64, len(x))
+	for i := range x {
+		y[i] = 0.5*x[i] + 0.2
+	}
+
+	loader := dataset.NewStandardLoader(map[string]*rlwe.Ciphertext{"x": utils.EncryptToLevel(x, 9)}, []string{"x"}, []*rlwe.Ciphertext{utils.EncryptToLevel(y, 9)}, utils, len(x))
+
+	dense := layers.NewDense(utils, 1, 1, nil, true, 4, 0.5, 12)
+	dense.SetWeightLevel(1)
+
+	model := NewModel(utils, []layers.Layer1D{&dense}, []layers.Layer2D{}, losses.MSE{U: utils}, false)
+	recorder := &recordingCallback{utils: utils, dense: &dense}
+	model.AddCallback(recorder)
+
+	// Only the rest of the start epoch and the following epochs are trained
+	model.Train1DFrom(loader, 0.5, 4, 3, 1, 1)
+
+	expected := [][2]int{{1, 1}, {2, 0}, {2, 1}}
+	if len(recorder.batches) != len(expected) {
+		t.Fatalf("Expected %d batches after resuming but got %d", len(expected), len(recorder.batches))
+	}
+	for i, event := range recorder.batches {
+		if event.Epoch != expected[i][0] || event.Batch != expected[i][1] {
+			t.Errorf("Batch %d expected at epoch %d batch %d but got epoch %d batch %d", i, expected[i][0], expected[i][1], event.Epoch, event.Batch)
+		}
+	}
+
+	// Training with checkpoint shares the loop and saves the position after the last epoch
+	checkpointPath := path.Join(t.TempDir(), "model.ckpt")
+	if err := model.SaveCheckpoint(checkpointPath, 2, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	recorder.batches = nil
+	if err := model.TrainWithCheckpoint(loader, 0.5, 4, 3, checkpointPath, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(recorder.batches) != 1 || recorder.batches[0].Epoch != 2 || recorder.batches[0].Batch != 1 {
+		t.Errorf("Training with checkpoint should resume at epoch 2 batch 1 but trained %+v", recorder.batches)
+	}
+
+	checkpoint, err := ReadCheckpoint(checkpointPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if checkpoint.Epoch != 3 || checkpoint.Batch != 0 {
+		t.Errorf("Checkpoint should be saved at epoch 3 batch 0 but got epoch %d batch %d", checkpoint.Epoch, checkpoint.Batch)
+	}
+
+}
+
+func TestMaxPoolingBootstrapping(t *testing.T) {
+
+	utils := utilitytest.NewUtils(key.NewParameters(ckks.PN15QP880, nil), math.Pow(2, 40), (*utility.RotationPlanner).AddSumElements)
+
+	// Rounds of 2x2 pool with bound 3 consume 5 levels and fit in one stage, leaving 4 levels for the dense layer.
+	// Rounds of 3x3 pool with bound 1 consume 4 levels and the tournament bootstraps between its 2 stages, leaving
+	// 1 level so its output is bootstrapped before the dense layer
+	for _, test := range []struct {
+		size         int
+		bound        float64
+		bootstrapped bool
+	}{{2, 3, false}, {3, 1, true}} {
+
+		pool := layers.NewMaxPoolingLayer(utils, []int{test.size, test.size, 1}, []int{test.size, test.size}, []int{1, 1}, test.bound)
+		dense := layers.NewDense(utils, 1, 1, nil, true, 1, 0.1, 9)
+
+		model := NewModel(utils, []layers.Layer1D{&dense}, []layers.Layer2D{&pool}, losses.MSE{U: utils}, true)
+
+		if bootstrapped := model.bootstraps[bootstrapSite{true, 0, "forward"}]; bootstrapped != test.bootstrapped {
+			t.Errorf("%dx%d pool: output bootstrapped expected %t but got %t", test.size, test.size, test.bootstrapped, bootstrapped)
+		}
+
+		expected := 9 - pool.GetForwardLevelConsumption()
+		if test.bootstrapped {
+			expected = 9
+		}
+
+		if level := model.ForwardLevel[0][0]; level != expected {
+			t.Errorf("%dx%d pool: input level of dense layer expected %d but got %d", test.size, test.size, expected, level)
+		}
+
+	}
+
+}
@@ -0,0 +1,605 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/perm-ai/go-cerebrum/activations"
+	"github.com/perm-ai/go-cerebrum/layers"
+	"github.com/perm-ai/go-cerebrum/losses"
+	"github.com/perm-ai/go-cerebrum/utility"
+	"gopkg.in/yaml.v3"
+)
+
+//=================================================
+//				 MODEL SPECIFICATION
+//=================================================
+
+// ModelSpec describes the architecture and training settings of a model so it can be defined in a JSON or YAML file
+//
+//	batch_size: 64
+//	learning_rate: 0.1
+//	weight_level: 9
+//	loss: cross_entropy
+//	auto_bootstrap: true
+//	input_shape: [28, 28, 1]
+//	layers:
+//	  - type: conv2d
+//	    filters: 4
+//	    kernel_size: [3, 3]
+//	    activation: relu
+//	  - type: average_pooling2d
+//	    pool_size: [2, 2]
+//	  - type: dense
+//	    units: 10
+//	    activation: softmax
+type ModelSpec struct {
+	BatchSize     int         `json:"batch_size" yaml:"batch_size"`
+	LearningRate  float64     `json:"learning_rate" yaml:"learning_rate"`
+	WeightLevel   int         `json:"weight_level" yaml:"weight_level"` // Level of encrypted weights, can be overridden by each layer
+	Loss          string      `json:"loss" yaml:"loss"`
+	LossParams    *LossSpec   `json:"loss_params,omitempty" yaml:"loss_params,omitempty"` // Parameters of huber, hinge and binary_cross_entropy
+	AutoBootstrap bool        `json:"auto_bootstrap" yaml:"auto_bootstrap"`
+	InputShape    []int       `json:"input_shape" yaml:"input_shape,flow"` // [row, column, channel] for 2D input or [units] for 1D input
+	Layers        []LayerSpec `json:"layers" yaml:"layers"`
+}
+
+// LossSpec holds parameters of the loss of ModelSpec. Only the fields used by the loss are read and 0 uses its default
+type LossSpec struct {
+	Delta      float64 `json:"delta,omitempty" yaml:"delta,omitempty"`             // huber
+	Bound      float64 `json:"bound,omitempty" yaml:"bound,omitempty"`             // huber and hinge
+	Steepness  float64 `json:"steepness,omitempty" yaml:"steepness,omitempty"`     // hinge
+	LowerBound float64 `json:"lower_bound,omitempty" yaml:"lower_bound,omitempty"` // binary_cross_entropy
+}
+
+// LayerSpec describes a single layer of ModelSpec. Type is one of "dense", "conv2d", "average_pooling2d", "max_pooling2d" or "flatten"
+// and only the fields used by that type are read. 2D layers must come before every dense layer and a flatten layer
+// between them is optional as the model always flattens the output of the last 2D layer
+type LayerSpec struct {
+	Type        string  `json:"type" yaml:"type"`
+	Units       int     `json:"units,omitempty" yaml:"units,omitempty"`     // dense
+	Filters     int     `json:"filters,omitempty" yaml:"filters,omitempty"` // conv2d
+	KernelSize  []int   `json:"kernel_size,omitempty" yaml:"kernel_size,omitempty,flow"`
+	PoolSize    []int   `json:"pool_size,omitempty" yaml:"pool_size,omitempty,flow"` // average_pooling2d and max_pooling2d
+	Strides     []int   `json:"strides,omitempty" yaml:"strides,omitempty,flow"`     // Default to [1, 1] for conv2d and pool_size for pooling
+	Bound       float64 `json:"bound,omitempty" yaml:"bound,omitempty"`              // max_pooling2d, default to 1
+	Padding     bool    `json:"padding,omitempty" yaml:"padding,omitempty"`
+	Activation  string  `json:"activation,omitempty" yaml:"activation,omitempty"` // relu, sigmoid, tanh, softmax or empty for no activation
+	UseBias     *bool   `json:"use_bias,omitempty" yaml:"use_bias,omitempty"`     // Default to true
+	WeightLevel int     `json:"weight_level,omitempty" yaml:"weight_level,omitempty"`
+}
+
+var supportedActivations = []string{"relu", "sigmoid", "tanh", "softmax"}
+var supportedLosses = []string{"mse", "cross_entropy", "binary_cross_entropy", "huber", "hinge"}
+
+// Read model specification from JSON or YAML file. The format is chosen from the file extension
+func ReadModelSpec(filename string) (ModelSpec, error) {
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return ModelSpec{}, err
+	}
+
+	var spec ModelSpec
+
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".json":
+		err = json.Unmarshal(data, &spec)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &spec)
+	default:
+		return ModelSpec{}, fmt.Errorf("unsupported model spec file %s, expected .json, .yaml or .yml", filename)
+	}
+
+	if err != nil {
+		return ModelSpec{}, fmt.Errorf("parse model spec %s: %w", filename, err)
+	}
+
+	return spec, nil
+
+}
+
+// Write model specification to JSON or YAML file. The format is chosen from the file extension
+func (s ModelSpec) Write(filename string) error {
+
+	var data []byte
+	var err error
+
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".json":
+		data, err = json.MarshalIndent(s, "", "  ")
+	case ".yaml", ".yml":
+		data, err = yaml.Marshal(s)
+	default:
+		return fmt.Errorf("unsupported model spec file %s, expected .json, .yaml or .yml", filename)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(filename, data, 0644)
+
+}
+
+// Validate settings of every layer and that the output shape of each layer is a valid input of the next one.
+// All problems found are returned joined in a single error
+func (s ModelSpec) Validate() error {
+
+	problems := []string{}
+	addProblem := func(format string, a ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, a...))
+	}
+
+	if s.BatchSize <= 0 {
+		addProblem("batch_size must be positive")
+	}
+
+	if !contains(supportedLosses, s.Loss) {
+		addProblem("unsupported loss %q, expected one of %v", s.Loss, supportedLosses)
+	}
+
+	if p := s.LossParams; p != nil && (p.Delta < 0 || p.Bound < 0 || p.Steepness < 0 || p.LowerBound < 0 || p.LowerBound >= 0.5) {
+		addProblem("loss_params must not be negative and lower_bound must be below 0.5")
+	}
+
+	if len(s.InputShape) != 1 && len(s.InputShape) != 3 {
+		addProblem("input_shape must be [units] or [row, column, channel] but got %v", s.InputShape)
+		return errors.New("invalid model spec: " + strings.Join(problems, "; "))
+	}
+
+	for i := range s.InputShape {
+		if s.InputShape[i] <= 0 {
+			addProblem("input_shape must be positive but got %v", s.InputShape)
+			return errors.New("invalid model spec: " + strings.Join(problems, "; "))
+		}
+	}
+
+	if len(s.Layers) == 0 {
+		addProblem("model has no layer")
+	}
+
+	// Track shape of the output of the previous layer
+	shape := append([]int{}, s.InputShape...)
+	denseCount := 0
+
+	for i, layer := range s.Layers {
+
+		name := fmt.Sprintf("layer %d (%s)", i, layer.Type)
+
+		if layer.Activation != "" && !contains(supportedActivations, layer.Activation) {
+			addProblem("%s: unsupported activation %q, expected one of %v", name, layer.Activation, supportedActivations)
+		}
+
+		if layer.WeightLevel < 0 {
+			addProblem("%s: weight_level can't be negative", name)
+		}
+
+		switch layer.Type {
+
+		case "dense":
+
+			if layer.Units <= 0 {
+				addProblem("%s: units must be positive", name)
+			}
+
+			if layer.Units > 0 {
+				shape = []int{layer.Units}
+			}
+			denseCount++
+
+		case "flatten":
+
+			if len(shape) != 3 {
+				addProblem("%s: input is already flat", name)
+			}
+			shape = []int{flatSize(shape)}
+
+		case "conv2d", "average_pooling2d", "max_pooling2d":
+
+			if len(shape) != 3 {
+				addProblem("%s: 2D layer must come before flatten and dense layers", name)
+				continue
+			}
+
+			window := layer.KernelSize
+			strides := layer.Strides
+			padding := 0
+			depth := shape[2]
+
+			if layer.Type == "conv2d" {
+
+				if layer.Filters <= 0 {
+					addProblem("%s: filters must be positive", name)
+				}
+				if len(window) != 2 {
+					addProblem("%s: kernel_size must be [row, column] but got %v", name, window)
+					continue
+				}
+				if len(strides) == 0 {
+					strides = []int{1, 1}
+				}
+				if layer.Padding {
+					padding = 1
+				}
+				depth = layer.Filters
+
+			} else {
+
+				window = layer.PoolSize
+				if len(window) != 2 {
+					addProblem("%s: pool_size must be [row, column] but got %v", name, window)
+					continue
+				}
+				if len(strides) == 0 {
+					strides = window
+				}
+				if layer.Activation != "" {
+					addProblem("%s: pooling layer doesn't support activation", name)
+				}
+				if layer.Bound < 0 {
+					addProblem("%s: bound must be positive but got %v", name, layer.Bound)
+				}
+
+			}
+
+			if len(strides) != 2 || strides[0] <= 0 || strides[1] <= 0 {
+				addProblem("%s: strides must be 2 positive values but got %v", name, strides)
+				continue
+			}
+
+			if window[0] <= 0 || window[1] <= 0 || window[0] > shape[0]+2*padding || window[1] > shape[1]+2*padding {
+				addProblem("%s: window %v doesn't fit input of shape %v", name, window, shape)
+				continue
+			}
+
+			shape = []int{
+				(shape[0]-window[0]+2*padding)/strides[0] + 1,
+				(shape[1]-window[1]+2*padding)/strides[1] + 1,
+				depth,
+			}
+
+		default:
+			addProblem("%s: unsupported layer type, expected dense, conv2d, average_pooling2d, max_pooling2d or flatten", name)
+		}
+
+	}
+
+	if s.AutoBootstrap && denseCount == 0 {
+		addProblem("auto_bootstrap requires at least one dense layer")
+	}
+
+	if len(problems) != 0 {
+		return errors.New("invalid model spec: " + strings.Join(problems, "; "))
+	}
+
+	return nil
+
+}
+
+// Validate spec and create a model with randomly initialized encrypted weights following it
+func NewModelFromSpec(utils utility.Utils, spec ModelSpec) (Model, error) {
+
+	if err := spec.Validate(); err != nil {
+		return Model{}, err
+	}
+
+	layer1d := []layers.Layer1D{}
+	layer2d := []layers.Layer2D{}
+
+	shape := append([]int{}, spec.InputShape...)
+
+	for _, layer := range spec.Layers {
+
+		weightLevel := spec.WeightLevel
+		if layer.WeightLevel != 0 {
+			weightLevel = layer.WeightLevel
+		}
+
+		useBias := layer.UseBias == nil || *layer.UseBias
+		activation := newActivation(layer.Activation, utils)
+
+		switch layer.Type {
+
+		case "dense":
+
+			dense := layers.NewDense(utils, flatSize(shape), layer.Units, activation, useBias, spec.BatchSize, spec.LearningRate, weightLevel)
+			layer1d = append(layer1d, &dense)
+			shape = []int{dense.GetOutputSize()}
+
+		case "flatten":
+
+			shape = []int{flatSize(shape)}
+
+		case "conv2d":
+
+			strides := layer.Strides
+			if len(strides) == 0 {
+				strides = []int{1, 1}
+			}
+
+			conv := layers.NewConv2D(utils, layer.Filters, layer.KernelSize, strides, layer.Padding, activation, useBias, shape, spec.BatchSize)
+			conv.SetWeightLevel(weightLevel)
+			layer2d = append(layer2d, &conv)
+			shape = conv.GetOutputSize()
+
+		case "average_pooling2d":
+
+			strides := layer.Strides
+			if len(strides) == 0 {
+				strides = layer.PoolSize
+			}
+
+			pool := layers.NewPoolingLayer(utils, shape, layer.PoolSize, strides)
+			layer2d = append(layer2d, &pool)
+			shape = pool.GetOutputSize()
+
+		case "max_pooling2d":
+
+			strides := layer.Strides
+			if len(strides) == 0 {
+				strides = layer.PoolSize
+			}
+
+			bound := layer.Bound
+			if bound == 0 {
+				bound = 1
+			}
+
+			pool := layers.NewMaxPoolingLayer(utils, shape, layer.PoolSize, strides, bound)
+			layer2d = append(layer2d, &pool)
+			shape = pool.GetOutputSize()
+
+		}
+
+	}
+
+	return NewModel(utils, layer1d, layer2d, newLoss(spec.Loss, spec.LossParams, utils), spec.AutoBootstrap), nil
+
+}
+
+// Read model specification from JSON or YAML file and create a model following it
+func LoadModelFromSpec(utils utility.Utils, filename string) (Model, error) {
+
+	spec, err := ReadModelSpec(filename)
+	if err != nil {
+		return Model{}, err
+	}
+
+	return NewModelFromSpec(utils, spec)
+
+}
+
+// Describe the architecture of the model as ModelSpec so it can be written to file and rebuilt with NewModelFromSpec.
+// Batch size, learning rate and weight level are taken from the first layer that has them
+func (m Model) Spec() (ModelSpec, error) {
+
+	spec := ModelSpec{AutoBootstrap: m.ForwardLevel != nil, Layers: []LayerSpec{}}
+
+	// Parameters are only written when they aren't all default
+	params := LossSpec{}
+
+	switch loss := m.Loss.(type) {
+	case losses.MSE:
+		spec.Loss = "mse"
+	case losses.CrossEntropy:
+		spec.Loss = "cross_entropy"
+	case losses.BinaryCrossEntropy:
+		spec.Loss = "binary_cross_entropy"
+		params.LowerBound = loss.LowerBound
+	case losses.Huber:
+		spec.Loss = "huber"
+		params.Delta, params.Bound = loss.Delta, loss.Bound
+	case losses.Hinge:
+		spec.Loss = "hinge"
+		params.Bound, params.Steepness = loss.Bound, loss.Steepness
+	default:
+		return ModelSpec{}, fmt.Errorf("loss %T can't be described in model spec", m.Loss)
+	}
+
+	if params != (LossSpec{}) {
+		spec.LossParams = &params
+	}
+
+	setDefaults := func(batchSize int, weightLevel int) {
+		if spec.BatchSize == 0 {
+			spec.BatchSize = batchSize
+			spec.WeightLevel = weightLevel
+		}
+	}
+
+	for i := range m.Layers2d {
+
+		switch layer := m.Layers2d[i].(type) {
+
+		case *layers.Conv2D:
+
+			if i == 0 {
+				spec.InputShape = append([]int{}, layer.InputSize...)
+			}
+
+			setDefaults(layer.GetBatchSize(), layer.GetWeightLevel())
+			useBias := layer.HasBias()
+
+			activation, err := activationName(layer.Activation)
+			if err != nil {
+				return ModelSpec{}, fmt.Errorf("2D layer %d: %w", i, err)
+			}
+
+			spec.Layers = append(spec.Layers, LayerSpec{
+				Type:        "conv2d",
+				Filters:     len(layer.Kernels),
+				KernelSize:  []int{layer.Kernels[0].Row, layer.Kernels[0].Column},
+				Strides:     append([]int{}, layer.Strides...),
+				Padding:     layer.Padding,
+				Activation:  activation,
+				UseBias:     &useBias,
+				WeightLevel: layer.GetWeightLevel(),
+			})
+
+		case *layers.AveragePooling2D:
+
+			if i == 0 {
+				spec.InputShape = append([]int{}, layer.InputSize...)
+			}
+
+			spec.Layers = append(spec.Layers, LayerSpec{
+				Type:     "average_pooling2d",
+				PoolSize: append([]int{}, layer.Size...),
+				Strides:  append([]int{}, layer.Strides...),
+			})
+
+		case *layers.MaxPooling2D:
+
+			if i == 0 {
+				spec.InputShape = append([]int{}, layer.InputSize...)
+			}
+
+			spec.Layers = append(spec.Layers, LayerSpec{
+				Type:     "max_pooling2d",
+				PoolSize: append([]int{}, layer.Size...),
+				Strides:  append([]int{}, layer.Strides...),
+				Bound:    layer.Bound,
+			})
+
+		default:
+			return ModelSpec{}, fmt.Errorf("2D layer %d of type %T can't be described in model spec", i, layer)
+		}
+
+	}
+
+	if len(m.Layers2d) != 0 {
+		spec.Layers = append(spec.Layers, LayerSpec{Type: "flatten"})
+	}
+
+	for i := range m.Layers1d {
+
+		layer, ok := m.Layers1d[i].(*layers.Dense)
+		if !ok {
+			return ModelSpec{}, fmt.Errorf("1D layer %d of type %T can't be described in model spec", i, m.Layers1d[i])
+		}
+
+		if i == 0 && len(m.Layers2d) == 0 {
+			spec.InputShape = []int{layer.InputUnit}
+		}
+
+		if spec.LearningRate == 0 {
+			spec.LearningRate = layer.GetLearningRate()
+		}
+
+		setDefaults(layer.GetBatchSize(), layer.GetWeightLevel())
+		useBias := layer.HasBias()
+
+		activation, err := activationName(layer.Activation)
+		if err != nil {
+			return ModelSpec{}, fmt.Errorf("1D layer %d: %w", i, err)
+		}
+
+		spec.Layers = append(spec.Layers, LayerSpec{
+			Type:        "dense",
+			Units:       layer.OutputUnit,
+			Activation:  activation,
+			UseBias:     &useBias,
+			WeightLevel: layer.GetWeightLevel(),
+		})
+
+	}
+
+	// Layers using the model wide weight level don't need to repeat it
+	for i := range spec.Layers {
+		if spec.Layers[i].WeightLevel == spec.WeightLevel {
+			spec.Layers[i].WeightLevel = 0
+		}
+	}
+
+	return spec, nil
+
+}
+
+func newActivation(name string, utils utility.Utils) *activations.Activation {
+
+	var activation activations.Activation
+
+	switch name {
+	case "relu":
+		activation = activations.Relu{U: utils}
+	case "sigmoid":
+		activation = activations.Sigmoid{U: utils}
+	case "tanh":
+		activation = activations.NewTanh(utils)
+	case "softmax":
+		activation = activations.NewSoftmax(utils)
+	default:
+		return nil
+	}
+
+	return &activation
+
+}
+
+// Name of activation in model spec. Activations that newActivation can't rebuild, like fitted polynomials, return error
+func activationName(activation *activations.Activation) (string, error) {
+
+	if activation == nil {
+		return "", nil
+	}
+
+	name := (*activation).GetType()
+	if !contains(supportedActivations, name) {
+		return "", fmt.Errorf("activation %q can't be described in model spec, expected one of %v", name, supportedActivations)
+	}
+
+	return name, nil
+
+}
+
+// Create loss of name with params, which can be nil to use defaults
+func newLoss(name string, params *LossSpec, utils utility.Utils) losses.Loss {
+
+	if params == nil {
+		params = &LossSpec{}
+	}
+
+	switch name {
+	case "mse":
+		return losses.MSE{U: utils}
+	case "cross_entropy":
+		return losses.CrossEntropy{U: utils}
+	case "binary_cross_entropy":
+		return losses.BinaryCrossEntropy{U: utils, LowerBound: params.LowerBound}
+	case "huber":
+		return losses.Huber{U: utils, Delta: params.Delta, Bound: params.Bound}
+	case "hinge":
+		return losses.Hinge{U: utils, Bound: params.Bound, Steepness: params.Steepness}
+	}
+
+	return nil
+
+}
+
+func flatSize(shape []int) int {
+
+	size := 1
+	for i := range shape {
+		size *= shape[i]
+	}
+
+	return size
+
+}
+
+func contains(values []string, value string) bool {
+
+	for i := range values {
+		if values[i] == value {
+			return true
+		}
+	}
+
+	return false
+
+}
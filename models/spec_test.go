@@ -0,0 +1,164 @@
+package models
+
+import (
+	"math"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+
+	"github.com/perm-ai/go-cerebrum/activations"
+	"github.com/perm-ai/go-cerebrum/internal/utilitytest"
+	"github.com/perm-ai/go-cerebrum/key"
+	"github.com/perm-ai/go-cerebrum/layers"
+	"github.com/perm-ai/go-cerebrum/losses"
+	"github.com/tuneinsight/lattigo/v4/ckks"
+)
+
+func TestModelSpec(t *testing.T) {
+
+	utils := utilitytest.NewUtils(key.NewParameters(ckks.PN13QP218, nil), math.Pow(2, 30))
+
+	specYAML := `
+batch_size: 4
+learning_rate: 0.1
+weight_level: 3
+loss: mse
+input_shape: [3]
+layers:
+  - type: dense
+    units: 2
+    activation: sigmoid
+  - type: dense
+    units: 1
+    use_bias: false
+    weight_level: 2
+`
+
+	specPath := path.Join(t.TempDir(), "model.yaml")
+	if err := os.WriteFile(specPath, []byte(specYAML), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	model, err := LoadModelFromSpec(utils, specPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(model.Layers1d) != 2 || len(model.Layers2d) != 0 {
+		t.Fatalf("Expected 2 dense layers but got %d 1D and %d 2D layers", len(model.Layers1d), len(model.Layers2d))
+	}
+
+	first := model.Layers1d[0].(*layers.Dense)
+	second := model.Layers1d[1].(*layers.Dense)
+
+	if first.InputUnit != 3 || first.OutputUnit != 2 || second.InputUnit != 2 || second.OutputUnit != 1 {
+		t.Errorf("Dense layers have wrong shape [%d %d] [%d %d]", first.InputUnit, first.OutputUnit, second.InputUnit, second.OutputUnit)
+	}
+
+	if first.Weights[0][0].Level() != 3 || second.Weights[0][0].Level() != 2 {
+		t.Errorf("Weights should be encrypted at level 3 and 2 but got %d and %d", first.Weights[0][0].Level(), second.Weights[0][0].Level())
+	}
+
+	if !first.HasActivation() || (*first.Activation).GetType() != "sigmoid" || second.HasActivation() || second.HasBias() {
+		t.Error("Activation and bias weren't set following the spec")
+	}
+
+	// Write model back out and read it as JSON
+	spec, err := model.Spec()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jsonPath := path.Join(t.TempDir(), "model.json")
+	if err = spec.Write(jsonPath); err != nil {
+		t.Fatal(err)
+	}
+
+	written, err := ReadModelSpec(jsonPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected, _ := ReadModelSpec(specPath)
+	yes := true
+	expected.Layers[0].UseBias = &yes
+
+	if !reflect.DeepEqual(written, expected) {
+		t.Errorf("Written spec %+v doesn't match the original spec %+v", written, expected)
+	}
+
+	// Loss parameters that aren't default are written and rebuilt
+	model.Loss = losses.Huber{U: utils, Delta: 0.5, Bound: 2}
+	if spec, err = model.Spec(); err != nil {
+		t.Fatal(err)
+	}
+
+	if spec.LossParams == nil || spec.LossParams.Delta != 0.5 || spec.LossParams.Bound != 2 {
+		t.Errorf("Huber parameters should be written but got %+v", spec.LossParams)
+	}
+
+	rebuilt, err := NewModelFromSpec(utils, spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if huber, ok := rebuilt.Loss.(losses.Huber); !ok || huber.Delta != 0.5 || huber.Bound != 2 {
+		t.Errorf("Huber loss should be rebuilt with its parameters but got %T", rebuilt.Loss)
+	}
+
+	// Fitted polynomial can't be rebuilt from its name so it isn't written
+	var polynomial activations.Activation = activations.NewPolynomialTanh(utils, 4, 3)
+	first.Activation = &polynomial
+	if _, err = model.Spec(); err == nil {
+		t.Error("Spec of a model with polynomial activation should return error")
+	}
+
+}
+
+func TestModelSpecValidate(t *testing.T) {
+
+	valid := ModelSpec{
+		BatchSize:  8,
+		Loss:       "cross_entropy",
+		InputShape: []int{8, 8, 1},
+		Layers: []LayerSpec{
+			{Type: "conv2d", Filters: 2, KernelSize: []int{3, 3}, Activation: "relu"},
+			{Type: "average_pooling2d", PoolSize: []int{2, 2}},
+			{Type: "flatten"},
+			{Type: "dense", Units: 10, Activation: "softmax"},
+		},
+	}
+
+	if err := valid.Validate(); err != nil {
+		t.Errorf("Valid spec returned error: %v", err)
+	}
+
+	invalid := map[string]func(s *ModelSpec){
+		"kernel larger than input": func(s *ModelSpec) { s.Layers[0].KernelSize = []int{9, 9} },
+		"2D layer after dense": func(s *ModelSpec) {
+			s.Layers = append(s.Layers, LayerSpec{Type: "conv2d", Filters: 1, KernelSize: []int{1, 1}})
+		},
+		"unknown activation": func(s *ModelSpec) { s.Layers[3].Activation = "gelu" },
+		"unknown loss":       func(s *ModelSpec) { s.Loss = "kl" },
+		"unknown layer":      func(s *ModelSpec) { s.Layers[1].Type = "global_pooling2d" },
+		"missing units":      func(s *ModelSpec) { s.Layers[3].Units = 0 },
+		"zero stride":        func(s *ModelSpec) { s.Layers[1].Strides = []int{0, 1} },
+		"negative bound": func(s *ModelSpec) {
+			s.Layers[1] = LayerSpec{Type: "max_pooling2d", PoolSize: []int{2, 2}, Bound: -1}
+		},
+	}
+
+	for name, modify := range invalid {
+
+		spec := valid
+		spec.Layers = append([]LayerSpec{}, valid.Layers...)
+		modify(&spec)
+
+		if err := spec.Validate(); err == nil {
+			t.Errorf("Spec with %s should return an error", name)
+		}
+
+	}
+
+}
@@ -0,0 +1,121 @@
+package optimizers
+
+import (
+	"math"
+
+	"github.com/perm-ai/go-cerebrum/utility"
+	"github.com/tuneinsight/lattigo/v4/rlwe"
+)
+
+//=================================================
+//						ADAM
+//=================================================
+
+// Levels consumed by SqrtApprox of degree 3 with scaling back and by InverseNew
+const sqrtLevelConsumption = 3
+const inverseLevelConsumption = 4
+
+// Adam style optimizer with encrypted first and second moment for every weight.
+// Square root and inverse are evaluated with SqrtApprox and InverseNew polynomial approximations so the update
+// is only accurate when the bias corrected second moment stays within (0, GradientBound^2]. The update consumes many
+// levels and intermediate values are bootstrapped when they run out of level
+type Adam struct {
+	Beta1         float64
+	Beta2         float64
+	Epsilon       float64
+	GradientBound float64 // Upper bound of the absolute value of averaged gradients
+	state         *weightState
+}
+
+func NewAdam(beta1 float64, beta2 float64, epsilon float64, gradientBound float64) Adam {
+	return Adam{Beta1: beta1, Beta2: beta2, Epsilon: epsilon, GradientBound: gradientBound, state: newWeightState()}
+}
+
+// Create Adam with beta1 = 0.9, beta2 = 0.999, epsilon = 1e-3 and gradient bound of 1
+func NewDefaultAdam() Adam {
+	return NewAdam(0.9, 0.999, 1e-3, 1)
+}
+
+func (o Adam) New() Optimizer {
+	return NewAdam(o.Beta1, o.Beta2, o.Epsilon, o.GradientBound)
+}
+
+// Adam needs the raw average of the gradient, learning rate is applied in Update
+func (o Adam) GradientScale(lr float64) float64 {
+	return 1
+}
+
+func (o Adam) Step() {
+	o.state.nextStep()
+}
+
+func (o Adam) Update(key string, weight *rlwe.Ciphertext, gradient *rlwe.Ciphertext, lr float64, utils utility.Utils) {
+
+	step := o.state.currentStep()
+	if step == 0 {
+		step = 1
+	}
+
+	// m = beta1 * m + (1 - beta1) * g
+	firstMoment := utils.MultiplyConstNew(gradient, 1-o.Beta1, true, false)
+
+	// v = beta2 * v + (1 - beta2) * g^2
+	squared := gradient.CopyNew()
+	ensureLevel(squared, weight, 2, utils)
+	utils.Multiply(squared, squared, squared, true, false)
+	secondMoment := utils.MultiplyConstNew(squared, 1-o.Beta2, true, false)
+
+	if previous := o.state.get(key); previous != nil {
+
+		ensureLevel(previous[0], weight, 1, utils)
+		ensureLevel(previous[1], weight, 1, utils)
+
+		utils.Add(firstMoment, utils.MultiplyConstNew(previous[0], o.Beta1, true, false), firstMoment)
+		utils.Add(secondMoment, utils.MultiplyConstNew(previous[1], o.Beta2, true, false), secondMoment)
+
+	}
+
+	o.state.set(key, []*rlwe.Ciphertext{firstMoment, secondMoment})
+
+	// Bias corrected second moment
+	denominator := secondMoment.CopyNew()
+	ensureLevel(denominator, weight, 1+sqrtLevelConsumption+inverseLevelConsumption+1, utils)
+	utils.MultiplyConst(denominator, 1/(1-math.Pow(o.Beta2, float64(step))), denominator, true, false)
+
+	// sqrt(v) + epsilon
+	bound := o.GradientBound * o.GradientBound
+	denominator = utils.SqrtApprox(denominator, 3, bound, true)
+	utils.Evaluator.AddConst(denominator, o.Epsilon, denominator)
+
+	// SqrtApprox returns at a lower scale than the polynomial evaluation of InverseNew expects
+	if ratio := math.Round(utils.Scale / denominator.Scale.Float64()); ratio > 1 {
+		utils.Evaluator.ScaleUp(denominator, rlwe.NewScale(ratio), denominator)
+	}
+
+	// 1 / (sqrt(v) + epsilon) with input stretched into (0, 1]
+	ensureLevel(denominator, weight, inverseLevelConsumption+1, utils)
+	inverse := utils.InverseNew(denominator, 1/(o.GradientBound+o.Epsilon), utils.Params.Slots())
+
+	// lr * m / (1 - beta1^t) is computed alongside the inverse
+	update := firstMoment.CopyNew()
+	ensureLevel(update, weight, 2, utils)
+	utils.MultiplyConst(update, lr/(1-math.Pow(o.Beta1, float64(step))), update, true, false)
+
+	ensureLevel(inverse, weight, 1, utils)
+	utils.Multiply(update, inverse, update, true, false)
+
+	utils.Sub(weight, update, weight)
+
+}
+
+func (o Adam) GetType() string {
+	return "adam"
+}
+
+func (o Adam) State() (int, map[string][]*rlwe.Ciphertext) {
+	return o.state.export()
+}
+
+func (o Adam) LoadState(step int, state map[string][]*rlwe.Ciphertext) {
+	o.state.load(step, state)
+}
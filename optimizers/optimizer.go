@@ -0,0 +1,112 @@
+package optimizers
+
+import (
+	"sync"
+
+	"github.com/perm-ai/go-cerebrum/utility"
+	"github.com/tuneinsight/lattigo/v4/rlwe"
+)
+
+//=================================================
+//					OPTIMIZER
+//=================================================
+
+// Optimizer applies gradients to encrypted weights. Layers average the gradient over the batch, multiply it by
+// GradientScale and call Update once for every weight. Optimizers with state keep it encrypted for each key
+// so every layer must use its own optimizer created with New
+type Optimizer interface {
+	New() Optimizer                   // Create optimizer with the same settings and empty state
+	GradientScale(lr float64) float64 // Factor multiplied into the batch average of the gradient before Update
+	Step()                            // Start a new training step, called by layer once before updating its weights
+	// Update weight in place. gradient is shared between calls and must not be modified. key identifies the weight
+	// within the layer and utils must be safe to use concurrently with other calls
+	Update(key string, weight *rlwe.Ciphertext, gradient *rlwe.Ciphertext, lr float64, utils utility.Utils)
+	GetType() string
+
+	State() (int, map[string][]*rlwe.Ciphertext) // Training step and encrypted state of every weight for checkpoints
+	LoadState(step int, state map[string][]*rlwe.Ciphertext)
+}
+
+// Encrypted per weight state shared by copies of an optimizer
+type weightState struct {
+	lock  sync.Mutex
+	step  int
+	state map[string][]*rlwe.Ciphertext
+}
+
+func newWeightState() *weightState {
+	return &weightState{state: make(map[string][]*rlwe.Ciphertext)}
+}
+
+func (s *weightState) get(key string) []*rlwe.Ciphertext {
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.state[key]
+
+}
+
+func (s *weightState) set(key string, state []*rlwe.Ciphertext) {
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.state[key] = state
+
+}
+
+func (s *weightState) nextStep() {
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.step++
+
+}
+
+func (s *weightState) currentStep() int {
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.step
+
+}
+
+func (s *weightState) export() (int, map[string][]*rlwe.Ciphertext) {
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	state := make(map[string][]*rlwe.Ciphertext, len(s.state))
+	for key := range s.state {
+		state[key] = append([]*rlwe.Ciphertext{}, s.state[key]...)
+	}
+
+	return s.step, state
+
+}
+
+func (s *weightState) load(step int, state map[string][]*rlwe.Ciphertext) {
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.step = step
+	s.state = make(map[string][]*rlwe.Ciphertext, len(state))
+	for key := range state {
+		s.state[key] = append([]*rlwe.Ciphertext{}, state[key]...)
+	}
+
+}
+
+// Bootstrap ct if it doesn't have level left for the given number of multiplications
+// while staying at or above the level of weight
+func ensureLevel(ct *rlwe.Ciphertext, weight *rlwe.Ciphertext, consumption int, utils utility.Utils) {
+
+	if ct.Level()-consumption < weight.Level() {
+		utils.BootstrapInPlace(ct)
+	}
+
+}
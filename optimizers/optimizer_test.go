@@ -0,0 +1,128 @@
+package optimizers
+
+import (
+	"math"
+	"testing"
+
+	"github.com/perm-ai/go-cerebrum/internal/utilitytest"
+	"github.com/perm-ai/go-cerebrum/key"
+	"github.com/perm-ai/go-cerebrum/utility"
+	"github.com/tuneinsight/lattigo/v4/ckks"
+	"github.com/tuneinsight/lattigo/v4/rlwe"
+)
+
+// Run optimizer for each gradient on a single weight and return the decrypted weight after every step
+func runSteps(optimizer Optimizer, utils utility.Utils, weight *rlwe.Ciphertext, gradients []float64, gradientLevel int, lr float64) []float64 {
+
+	weights := make([]float64, len(gradients))
+
+	for i := range gradients {
+
+		gradient := utils.EncryptToLevel(utils.GenerateFilledArraySize(gradients[i]*optimizer.GradientScale(lr), 4), gradientLevel)
+
+		optimizer.Step()
+		optimizer.Update("weight/0/0", weight, gradient, lr, utils)
+
+		weights[i] = utils.Decrypt(weight)[0]
+
+	}
+
+	return weights
+
+}
+
+func TestMomentum(t *testing.T) {
+
+	utils := utilitytest.NewUtils(key.NewParameters(ckks.PN13QP218, nil), math.Pow(2, 30))
+	gradients := []float64{0.5, 0.5, -1}
+	lr := 0.1
+
+	expected := map[bool][]float64{}
+
+	for _, nesterov := range []bool{false, true} {
+
+		weight, velocity := 1.0, 0.0
+		for _, g := range gradients {
+			velocity = 0.9*velocity + lr*g
+			if nesterov {
+				weight -= 0.9*velocity + lr*g
+			} else {
+				weight -= velocity
+			}
+			expected[nesterov] = append(expected[nesterov], weight)
+		}
+
+	}
+
+	for _, nesterov := range []bool{false, true} {
+
+		optimizer := NewMomentum(0.9, nesterov)
+		weight := utils.EncryptToLevel(utils.GenerateFilledArraySize(1, 4), 1)
+
+		result := runSteps(optimizer, utils, weight, gradients[:2], 4, lr)
+
+		// Continue from exported state with a new optimizer
+		step, state := optimizer.State()
+		if step != 2 || len(state["weight/0/0"]) != 1 {
+			t.Fatalf("%s: expected state after 2 steps with 1 velocity but got step %d with %d ciphertexts", optimizer.GetType(), step, len(state["weight/0/0"]))
+		}
+
+		restored := optimizer.New()
+		restored.LoadState(step, state)
+		result = append(result, runSteps(restored, utils, weight, gradients[2:], 4, lr)...)
+
+		for i := range result {
+			if math.Abs(result[i]-expected[nesterov][i]) > 1e-3 {
+				t.Errorf("%s: weight after step %d expected %f but got %f", optimizer.GetType(), i+1, expected[nesterov][i], result[i])
+			}
+		}
+
+		if weight.Level() < 1 {
+			t.Errorf("%s: weight should stay at level 1 but is at level %d", optimizer.GetType(), weight.Level())
+		}
+
+	}
+
+	weight := utils.EncryptToLevel(utils.GenerateFilledArraySize(1, 4), 1)
+	result := runSteps(NewSGD(), utils, weight, gradients, 4, lr)
+
+	expectedWeight := 1.0
+	for i, g := range gradients {
+		expectedWeight -= lr * g
+		if math.Abs(result[i]-expectedWeight) > 1e-3 {
+			t.Errorf("sgd: weight after step %d expected %f but got %f", i+1, expectedWeight, result[i])
+		}
+	}
+
+}
+
+func TestAdam(t *testing.T) {
+
+	// Square root and inverse approximation need more levels than the smaller parameters have
+	utils := utilitytest.NewUtils(key.NewParameters(ckks.PN15QP880, nil), math.Pow(2, 40))
+	gradients := []float64{0.5, 0.5, 0.25}
+	lr := 0.1
+
+	optimizer := NewDefaultAdam()
+	weight := utils.EncryptToLevel(utils.GenerateFilledArraySize(1, 4), 0)
+
+	result := runSteps(optimizer, utils, weight, gradients, utils.Params.MaxLevel(), lr)
+
+	expected := 1.0
+	firstMoment, secondMoment := 0.0, 0.0
+
+	for i, g := range gradients {
+
+		firstMoment = 0.9*firstMoment + 0.1*g
+		secondMoment = 0.999*secondMoment + 0.001*g*g
+		step := float64(i + 1)
+		expected -= lr * (firstMoment / (1 - math.Pow(0.9, step))) / (math.Sqrt(secondMoment/(1-math.Pow(0.999, step))) + 1e-3)
+
+		// Polynomial approximations are only accurate to a few percent of the step
+		if math.Abs(result[i]-expected) > 0.01*step {
+			t.Errorf("adam: weight after step %d expected %f but got %f", i+1, expected, result[i])
+		}
+
+	}
+
+}
@@ -0,0 +1,134 @@
+package optimizers
+
+import (
+	"fmt"
+	"math"
+)
+
+//=================================================
+//			  LEARNING RATE SCHEDULE
+//=================================================
+
+// Schedule gives the learning rate used for a batch. It is computed in plaintext and folded into the plaintext
+// gradient averaging of each layer, so changing it doesn't cost any level. epoch and batch are 0-indexed
+type Schedule interface {
+	LearningRate(epoch int, batch int, batchPerEpoch int) float64
+}
+
+func trainingStep(epoch int, batch int, batchPerEpoch int) int {
+	return epoch*batchPerEpoch + batch
+}
+
+// Same learning rate for every batch
+type ConstantSchedule struct {
+	Rate float64
+}
+
+func NewConstantSchedule(rate float64) ConstantSchedule {
+	return ConstantSchedule{rate}
+}
+
+func (s ConstantSchedule) LearningRate(epoch int, batch int, batchPerEpoch int) float64 {
+	return s.Rate
+}
+
+// Multiply learning rate by Factor every EpochPerDrop epochs
+type StepDecay struct {
+	Initial      float64
+	Factor       float64
+	EpochPerDrop int
+}
+
+// Create step decay. epochPerDrop must be positive
+func NewStepDecay(initial float64, factor float64, epochPerDrop int) (StepDecay, error) {
+
+	if epochPerDrop <= 0 {
+		return StepDecay{}, fmt.Errorf("epoch per drop of step decay must be positive but got %d", epochPerDrop)
+	}
+
+	return StepDecay{initial, factor, epochPerDrop}, nil
+
+}
+
+func (s StepDecay) LearningRate(epoch int, batch int, batchPerEpoch int) float64 {
+	return s.Initial * math.Pow(s.Factor, float64(epoch/s.EpochPerDrop))
+}
+
+// Decay learning rate by DecayRate every DecayStep batches. Decay is applied continuously unless Staircase is set
+type ExponentialDecay struct {
+	Initial   float64
+	DecayRate float64
+	DecayStep int
+	Staircase bool
+}
+
+// Create exponential decay. decayStep must be positive
+func NewExponentialDecay(initial float64, decayRate float64, decayStep int, staircase bool) (ExponentialDecay, error) {
+
+	if decayStep <= 0 {
+		return ExponentialDecay{}, fmt.Errorf("decay step of exponential decay must be positive but got %d", decayStep)
+	}
+
+	return ExponentialDecay{initial, decayRate, decayStep, staircase}, nil
+
+}
+
+func (s ExponentialDecay) LearningRate(epoch int, batch int, batchPerEpoch int) float64 {
+
+	exponent := float64(trainingStep(epoch, batch, batchPerEpoch)) / float64(s.DecayStep)
+	if s.Staircase {
+		exponent = math.Floor(exponent)
+	}
+
+	return s.Initial * math.Pow(s.DecayRate, exponent)
+
+}
+
+// Anneal learning rate from Initial to Minimum along half a cosine over TotalStep batches and stay at Minimum afterward
+type CosineDecay struct {
+	Initial   float64
+	Minimum   float64
+	TotalStep int
+}
+
+// Create cosine decay. totalStep must be positive
+func NewCosineDecay(initial float64, minimum float64, totalStep int) (CosineDecay, error) {
+
+	if totalStep <= 0 {
+		return CosineDecay{}, fmt.Errorf("total step of cosine decay must be positive but got %d", totalStep)
+	}
+
+	return CosineDecay{initial, minimum, totalStep}, nil
+
+}
+
+func (s CosineDecay) LearningRate(epoch int, batch int, batchPerEpoch int) float64 {
+
+	progress := math.Min(float64(trainingStep(epoch, batch, batchPerEpoch))/float64(s.TotalStep), 1)
+
+	return s.Minimum + 0.5*(s.Initial-s.Minimum)*(1+math.Cos(math.Pi*progress))
+
+}
+
+// Linearly increase learning rate of After from 0 over the first WarmupStep batches. After is evaluated at the same
+// epoch and batch so warmup only scales the beginning of its schedule
+type Warmup struct {
+	WarmupStep int
+	After      Schedule
+}
+
+func NewWarmup(warmupStep int, after Schedule) Warmup {
+	return Warmup{warmupStep, after}
+}
+
+func (s Warmup) LearningRate(epoch int, batch int, batchPerEpoch int) float64 {
+
+	lr := s.After.LearningRate(epoch, batch, batchPerEpoch)
+
+	if step := trainingStep(epoch, batch, batchPerEpoch); step < s.WarmupStep {
+		return lr * float64(step+1) / float64(s.WarmupStep)
+	}
+
+	return lr
+
+}
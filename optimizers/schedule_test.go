@@ -0,0 +1,59 @@
+package optimizers
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSchedules(t *testing.T) {
+
+	batchPerEpoch := 10
+
+	stepDecay, _ := NewStepDecay(0.1, 0.5, 2)
+	exponential, _ := NewExponentialDecay(0.1, 0.5, 20, false)
+	staircase, _ := NewExponentialDecay(0.1, 0.5, 20, true)
+	cosine, _ := NewCosineDecay(0.1, 0.01, 40)
+
+	tests := []struct {
+		name     string
+		schedule Schedule
+		epoch    int
+		batch    int
+		expected float64
+	}{
+		{"constant", NewConstantSchedule(0.1), 3, 4, 0.1},
+		{"step decay before drop", stepDecay, 1, 9, 0.1},
+		{"step decay after 2 drops", stepDecay, 4, 0, 0.025},
+		{"exponential decay", exponential, 1, 0, 0.1 * math.Sqrt(0.5)},
+		{"exponential staircase", staircase, 1, 9, 0.1},
+		{"cosine start", cosine, 0, 0, 0.1},
+		{"cosine halfway", cosine, 2, 0, 0.055},
+		{"cosine after end", cosine, 5, 0, 0.01},
+		{"warmup first batch", NewWarmup(5, NewConstantSchedule(0.1)), 0, 0, 0.02},
+		{"warmup done", NewWarmup(5, NewConstantSchedule(0.1)), 0, 5, 0.1},
+	}
+
+	for _, test := range tests {
+		if lr := test.schedule.LearningRate(test.epoch, test.batch, batchPerEpoch); math.Abs(lr-test.expected) > 1e-9 {
+			t.Errorf("%s: expected learning rate %f but got %f", test.name, test.expected, lr)
+		}
+	}
+
+}
+
+func TestScheduleValidation(t *testing.T) {
+
+	// Each of these would divide by zero when computing the learning rate
+	if _, err := NewStepDecay(0.1, 0.5, 0); err == nil {
+		t.Error("Step decay with 0 epoch per drop should return error")
+	}
+
+	if _, err := NewExponentialDecay(0.1, 0.5, 0, false); err == nil {
+		t.Error("Exponential decay with 0 decay step should return error")
+	}
+
+	if _, err := NewCosineDecay(0.1, 0.01, 0); err == nil {
+		t.Error("Cosine decay with 0 total step should return error")
+	}
+
+}
@@ -0,0 +1,122 @@
+package optimizers
+
+import (
+	"github.com/perm-ai/go-cerebrum/utility"
+	"github.com/tuneinsight/lattigo/v4/rlwe"
+)
+
+//=================================================
+//						SGD
+//=================================================
+
+// Plain stochastic gradient descent w -= lr * g. Learning rate is folded into gradient averaging so the update
+// doesn't consume any level
+type SGD struct{}
+
+func NewSGD() SGD {
+	return SGD{}
+}
+
+func (o SGD) New() Optimizer {
+	return SGD{}
+}
+
+func (o SGD) GradientScale(lr float64) float64 {
+	return lr
+}
+
+func (o SGD) Step() {}
+
+func (o SGD) Update(key string, weight *rlwe.Ciphertext, gradient *rlwe.Ciphertext, lr float64, utils utility.Utils) {
+	utils.Sub(weight, gradient, weight)
+}
+
+func (o SGD) GetType() string {
+	return "sgd"
+}
+
+func (o SGD) State() (int, map[string][]*rlwe.Ciphertext) {
+	return 0, map[string][]*rlwe.Ciphertext{}
+}
+
+func (o SGD) LoadState(step int, state map[string][]*rlwe.Ciphertext) {}
+
+//=================================================
+//					MOMENTUM SGD
+//=================================================
+
+// SGD with momentum v = momentum * v + lr * g, w -= v. With Nesterov set, w -= momentum * v + lr * g instead.
+// Velocity is kept encrypted for every weight and consumes 1 level per step, it is bootstrapped whenever
+// it would fall below the level of the weight
+type Momentum struct {
+	Momentum float64
+	Nesterov bool
+	state    *weightState
+}
+
+func NewMomentum(momentum float64, nesterov bool) Momentum {
+	return Momentum{Momentum: momentum, Nesterov: nesterov, state: newWeightState()}
+}
+
+func (o Momentum) New() Optimizer {
+	return NewMomentum(o.Momentum, o.Nesterov)
+}
+
+func (o Momentum) GradientScale(lr float64) float64 {
+	return lr
+}
+
+func (o Momentum) Step() {
+	o.state.nextStep()
+}
+
+func (o Momentum) Update(key string, weight *rlwe.Ciphertext, gradient *rlwe.Ciphertext, lr float64, utils utility.Utils) {
+
+	var velocity *rlwe.Ciphertext
+
+	if previous := o.state.get(key); previous == nil {
+
+		// Velocity starts at zero so the first velocity is the gradient itself
+		velocity = gradient.CopyNew()
+
+	} else {
+
+		velocity = previous[0]
+		ensureLevel(velocity, weight, 1, utils)
+
+		velocity = utils.MultiplyConstNew(velocity, o.Momentum, true, false)
+		utils.Add(velocity, gradient, velocity)
+
+	}
+
+	o.state.set(key, []*rlwe.Ciphertext{velocity})
+
+	if o.Nesterov {
+
+		lookahead := velocity.CopyNew()
+		ensureLevel(lookahead, weight, 1, utils)
+
+		lookahead = utils.MultiplyConstNew(lookahead, o.Momentum, true, false)
+		utils.Add(lookahead, gradient, lookahead)
+		utils.Sub(weight, lookahead, weight)
+
+	} else {
+		utils.Sub(weight, velocity, weight)
+	}
+
+}
+
+func (o Momentum) GetType() string {
+	if o.Nesterov {
+		return "nesterov"
+	}
+	return "momentum"
+}
+
+func (o Momentum) State() (int, map[string][]*rlwe.Ciphertext) {
+	return o.state.export()
+}
+
+func (o Momentum) LoadState(step int, state map[string][]*rlwe.Ciphertext) {
+	o.state.load(step, state)
+}
@@ -1,18 +1,42 @@
 package regression
 
 import (
+	"errors"
 	"fmt"
 	"math"
 
 	"github.com/tuneinsight/lattigo/v4/rlwe"
+	"github.com/perm-ai/go-cerebrum/dataset"
 	"github.com/perm-ai/go-cerebrum/logger"
 	"github.com/perm-ai/go-cerebrum/utility"
 )
 
+// Degree of Chebyshev approximation of the derivative of |w| used by L1 penalty
+const l1Degree = 15
+
+// Steepness of tanh(l1Sharpness * w / bound) approximating sign(w), low enough for l1Degree to fit it within about 1e-2
+const l1Sharpness = 5
+
+// Variance of holdout labels below which they are considered constant and R² is undefined
+const constantVariance = 1e-6
+
 type LinearRegression struct {
-	utils  utility.Utils
-	Weight []*rlwe.Ciphertext
-	Bias   *rlwe.Ciphertext
+	utils       utility.Utils
+	Weight      []*rlwe.Ciphertext
+	Bias        *rlwe.Ciphertext
+	PlainWeight []float64 // Used by Predict instead of Weight and Bias when set
+	PlainBias   float64
+	L1          float64 // Strength of L1 penalty on weights, set with SetL1
+	L2          float64 // Strength of L2 penalty on weights, bias is not penalized
+	sign        utility.Polynomial
+}
+
+// Encrypted sums over holdout data. The key holder decrypts them to compute MSE and R² without seeing any prediction
+type LinearRegressionEvaluation struct {
+	SquaredError *rlwe.Ciphertext // Sum of (y - prediction)^2
+	Sum          *rlwe.Ciphertext // Sum of y
+	SquaredSum   *rlwe.Ciphertext // Sum of y^2
+	Size         int
 }
 
 type LinearRegressionGradient struct {
@@ -30,7 +54,21 @@ func NewLinearRegression(u utility.Utils, numOfFeatures int) LinearRegression {
 	}
 	b := u.EncryptToPointer(zeros)
 
-	return LinearRegression{u, m, b}
+	return LinearRegression{utils: u, Weight: m, Bias: b}
+
+}
+
+// Create model for encrypted inference from plaintext weights trained elsewhere
+func NewLinearRegressionFromWeights(u utility.Utils, weight []float64, bias float64) LinearRegression {
+	return LinearRegression{utils: u, PlainWeight: weight, PlainBias: bias}
+}
+
+// Penalize the sum of |w| by strength. The derivative sign(w) is approximated by a Chebyshev interpolant of
+// tanh(l1Sharpness * w / bound), so weights must stay within [-bound, bound] and those close to 0 shrink more slowly
+func (l *LinearRegression) SetL1(strength float64, bound float64) {
+
+	l.L1 = strength
+	l.sign = utility.FitChebyshev(func(w float64) float64 { return math.Tanh(l1Sharpness * w / bound) }, -bound, bound, l1Degree, l.utils)
 
 }
 
@@ -44,16 +82,32 @@ func (l LinearRegression) Forward(input []*rlwe.Ciphertext) *rlwe.Ciphertext {
 
 }
 
+// Predict encrypted target of input for inference only. Plaintext weights are used if the model was created with
+// NewLinearRegressionFromWeights, in which case the products are rescaled once for the whole dot product
+func (l LinearRegression) Predict(input []*rlwe.Ciphertext) *rlwe.Ciphertext {
+
+	if l.PlainWeight == nil {
+		return l.Forward(input)
+	}
+
+	result := l.utils.PlainDotProduct(input, l.PlainWeight)
+	l.utils.Evaluator.AddConst(result, l.PlainBias, result)
+
+	return result
+
+}
+
 func (l LinearRegression) Backward(input []*rlwe.Ciphertext, output *rlwe.Ciphertext, y *rlwe.Ciphertext, size int, learningRate float64) LinearRegressionGradient {
 
 	// Calculate backward gradient using the following equation
 	// dM = (-2/n) * sum(input * (label - prediction)) * learning_rate
 	// dB = (-2/n) * sum(label - prediction) * learning_rate
+	// Penalties add (l1 * sign(m) + 2 * l2 * m) * learning_rate to dM. Prediction must be 0 outside of the data
 
 	err := l.utils.SubNew(y, output)
 
 	dM := make([]*rlwe.Ciphertext, len(input))
-	multiplier := l.utils.EncodePlaintextFromArray(l.utils.GenerateFilledArraySize((-2.0/float64(size))*learningRate, size))
+	multiplier := l.utils.EncodePlaintextFromArray(l.utils.GenerateFilledArray((-2.0 / float64(size)) * learningRate))
 
 	// for i := range input {
 	// 	dM[i] = l.utils.MultiplyNew(*input[i], *err.CopyNew(), true, false)
@@ -70,6 +124,15 @@ func (l LinearRegression) Backward(input []*rlwe.Ciphertext, output *rlwe.Cipher
 			utils.SumElementsInPlace(product)
 			result := utils.MultiplyPlainNew(product, multiplier, true, false)
 
+			if l.L1 != 0 {
+				sign := l.sign.Evaluate(l.Weight[index], utils.Params.Slots())
+				utils.Add(result, multiplyToScale(utils, sign, l.L1*learningRate, result.Scale), result)
+			}
+
+			if l.L2 != 0 {
+				utils.Add(result, multiplyToScale(utils, l.Weight[index], 2*l.L2*learningRate, result.Scale), result)
+			}
+
 			channel <- result
 		}(i, l.utils.CopyWithClonedEval(), channels[i])
 	}
@@ -102,10 +165,14 @@ func (model *LinearRegression) Train(x []*rlwe.Ciphertext, y *rlwe.Ciphertext, l
 
 	log.Log("Starting Linear Regression Training on encrypted data")
 
+	// Prediction is masked to the data so bias outside of it doesn't add to the gradient
+	mask := model.utils.EncodePlaintextFromArray(model.utils.GenerateFilledArraySize(1, size))
+
 	for i := 0; i < epoch; i++ {
 
 		log.Log(fmt.Sprintf("Forward propagating %d/%d (current lvl: %d)", i+1, epoch, x[0].Level()))
 		fwd := model.Forward(utility.Clone1dCiphertext(x))
+		model.utils.MultiplyPlain(fwd, mask, fwd, true, false)
 
 		log.Log(fmt.Sprintf("Backward propagating %d/%d(current lvl: %d)", i+1, epoch, fwd.Level()))
 		grad := model.Backward(utility.Clone1dCiphertext(x), fwd, y.CopyNew(), size, learningRate)
@@ -113,20 +180,138 @@ func (model *LinearRegression) Train(x []*rlwe.Ciphertext, y *rlwe.Ciphertext, l
 		log.Log(fmt.Sprintf("Updating gradient %d/%d(current lvl: %d)\n", i+1, epoch, grad.DM[0].Level()))
 		model.UpdateGradient(grad)
 
-		if model.Weight[0].Level() < 4 || model.Bias.Level() < 4 {
+		model.refresh()
+
+	}
+
+}
+
+// Train with mini-batch gradient descent on data of loader. Each batch is selected with the slot filter of
+// loader.Load1D, which must move the batch to the first slots like StandardLoader.Load1D, and the first label
+// ciphertext it returns is the label
+func (model *LinearRegression) TrainMiniBatch(loader dataset.Loader, batchSize int, learningRate float64, epoch int) {
+
+	log := logger.NewLogger(true)
+
+	log.Log("Starting Linear Regression Mini-batch Training on encrypted data")
+
+	length := loader.GetLength()
+
+	for i := 0; i < epoch; i++ {
 
-			for i := range x {
-				model.utils.BootstrapInPlace(model.Weight[i])
+		for start := 0; start < length; start += batchSize {
+
+			size := batchSize
+			if start+size > length {
+				size = length - start
 			}
 
-			if model.Bias.Scale.Float64() < math.Pow(2,50){
-				model.utils.Evaluator.ScaleUp(model.Bias, rlwe.NewScale(math.Pow(2, 60)/model.Bias.Scale.Float64()), model.Bias)
+			x, y := loader.Load1D(start, batchSize)
+
+			filter := make([]float64, size)
+			for j := range filter {
+				filter[j] = 1
 			}
 
-			model.utils.BootstrapInPlace(model.Bias)
-			
+			log.Log(fmt.Sprintf("Forward propagating batch %d of epoch %d/%d (current lvl: %d)", start/batchSize+1, i+1, epoch, x[0].Level()))
+			fwd := model.Forward(utility.Clone1dCiphertext(x))
+			model.utils.MultiplyPlain(fwd, model.utils.EncodePlaintextFromArray(filter), fwd, true, false)
+
+			log.Log(fmt.Sprintf("Backward propagating batch %d of epoch %d/%d (current lvl: %d)", start/batchSize+1, i+1, epoch, fwd.Level()))
+			grad := model.Backward(x, fwd, y[0], size, learningRate)
+
+			model.UpdateGradient(grad)
+
+			model.refresh()
+
+		}
+
+	}
+
+}
+
+// Bootstrap weight and bias when they don't have enough levels for the next update
+func (model *LinearRegression) refresh() {
+
+	minLevel := 4
+	if model.L1 != 0 {
+		minLevel += model.sign.GetLevelConsumption() + 1
+	} else if model.L2 != 0 {
+		minLevel++
+	}
+
+	if model.Weight[0].Level() < minLevel || model.Bias.Level() < minLevel {
+
+		for i := range model.Weight {
+			model.utils.BootstrapInPlace(model.Weight[i])
+		}
+
+		if model.Bias.Scale.Float64() < math.Pow(2,50){
+			model.utils.Evaluator.ScaleUp(model.Bias, rlwe.NewScale(math.Pow(2, 60)/model.Bias.Scale.Float64()), model.Bias)
 		}
 
+		model.utils.BootstrapInPlace(model.Bias)
+
 	}
 
 }
+
+// Compute encrypted sums needed for MSE and R² of predictions on the first size slots of holdout data x and y
+func (model LinearRegression) Evaluate(x []*rlwe.Ciphertext, y *rlwe.Ciphertext, size int) LinearRegressionEvaluation {
+
+	// Prediction is masked to the data since bias is added to every slot, and labels so slots after size aren't summed
+	mask := model.utils.EncodePlaintextFromArray(model.utils.GenerateFilledArraySize(1, size))
+
+	prediction := model.Predict(x)
+	model.utils.MultiplyPlain(prediction, mask, prediction, true, false)
+
+	y = model.utils.MultiplyPlainNew(y, mask, true, false)
+
+	err := model.utils.SubNew(y, prediction)
+	squaredError := model.utils.MultiplyNew(err, err, true, false)
+	model.utils.SumElementsInPlace(squaredError)
+
+	squaredSum := model.utils.MultiplyNew(y, y, true, false)
+	model.utils.SumElementsInPlace(squaredSum)
+
+	return LinearRegressionEvaluation{
+		SquaredError: squaredError,
+		Sum:          model.utils.SumElementsNew(*y),
+		SquaredSum:   squaredSum,
+		Size:         size,
+	}
+
+}
+
+// Decrypt MSE and R² of evaluated predictions. Only available to the key holder. R² is undefined when y of the
+// holdout data is constant, in which case error is returned along with the MSE
+func (e LinearRegressionEvaluation) Decrypt(utils utility.Utils) (mse float64, r2 float64, err error) {
+
+	n := float64(e.Size)
+
+	squaredError := utils.Decrypt(e.SquaredError)[0]
+	sum := utils.Decrypt(e.Sum)[0]
+	squaredSum := utils.Decrypt(e.SquaredSum)[0]
+
+	// Total sum of squares around the mean of y. Variance below constantVariance is only decryption noise
+	total := squaredSum - sum*sum/n
+	if total <= constantVariance*n {
+		return squaredError / n, 0, errors.New("R² is undefined since y of holdout data is constant")
+	}
+
+	return squaredError / n, 1 - squaredError/total, nil
+
+}
+
+// Multiply ct by constant encoded so the product has the given scale after rescaling, consuming 1 level.
+// Penalties can only be added to gradient without error this way
+func multiplyToScale(utils utility.Utils, ct *rlwe.Ciphertext, constant float64, scale rlwe.Scale) *rlwe.Ciphertext {
+
+	constantScale := scale.Float64() * utils.Params.QiFloat64(ct.Level()) / ct.Scale.Float64()
+
+	result := utils.MultiplyPlainNew(ct, utils.EncodePlaintextFromArrayScale(utils.GenerateFilledArray(constant), constantScale), true, false)
+	result.Scale = scale
+
+	return result
+
+}
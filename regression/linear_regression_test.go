@@ -5,8 +5,11 @@ import (
 	"math"
 	"testing"
 
+	"github.com/tuneinsight/lattigo/v4/ckks"
 	"github.com/tuneinsight/lattigo/v4/rlwe"
+	"github.com/perm-ai/go-cerebrum/dataset"
 	"github.com/perm-ai/go-cerebrum/importer"
+	"github.com/perm-ai/go-cerebrum/internal/utilitytest"
 	"github.com/perm-ai/go-cerebrum/key"
 	"github.com/perm-ai/go-cerebrum/utility"
 )
@@ -44,3 +47,168 @@ func TestLinearRegression(t *testing.T) {
 	fmt.Printf("The weights are biases are %f and %f", slope, bias[0])
 
 }
+
+func TestLinearRegressionPredict(t *testing.T) {
+
+	utils := utilitytest.NewUtils(key.NewParameters(ckks.PN13QP218, nil), math.Pow(2, 30))
+
+	x := [][]float64{{0.5, -1, 0.25}, {1, 0.5, -0.5}}
+	weight := []float64{0.7, -0.3}
+	bias := 0.2
+
+	input := []*rlwe.Ciphertext{utils.EncryptToPointer(x[0]), utils.EncryptToPointer(x[1])}
+
+	plainModel := NewLinearRegressionFromWeights(utils, weight, bias)
+
+	encryptedModel := NewLinearRegression(utils, 2)
+	for i := range weight {
+		encryptedModel.Weight[i] = utils.EncryptToPointer(utils.GenerateFilledArray(weight[i]))
+	}
+	encryptedModel.Bias = utils.EncryptToPointer(utils.GenerateFilledArray(bias))
+
+	for name, model := range map[string]LinearRegression{"plaintext": plainModel, "encrypted": encryptedModel} {
+
+		result := utils.Decrypt(model.Predict(input))
+
+		for i := range x[0] {
+			expected := weight[0]*x[0][i] + weight[1]*x[1][i] + bias
+			if math.Abs(result[i]-expected) > 1e-3 {
+				t.Errorf("Prediction with %s weights of data %d expected %f but got %f", name, i, expected, result[i])
+			}
+		}
+
+	}
+
+}
+
+var trainX = [][]float64{{0.2, -0.5, 0.9, 0.4, -0.8, 0.1}, {0.6, 0.3, -0.2, -0.7, 0.5, 0.8}}
+var trainY = []float64{0.1, -0.5, 0.8, 0.6, -0.3, -0.1}
+
+// Gradient descent in plaintext over batches of data, with sign approximating the derivative of |w|
+func plainGradientDescent(batchSize int, epoch int, learningRate float64, l1 float64, l2 float64, sign func(float64) float64) ([]float64, float64) {
+
+	weight := make([]float64, len(trainX))
+	bias := 0.0
+
+	for e := 0; e < epoch; e++ {
+		for start := 0; start < len(trainY); start += batchSize {
+
+			end := int(math.Min(float64(start+batchSize), float64(len(trainY))))
+			multiplier := -2.0 / float64(end-start) * learningRate
+
+			dM := make([]float64, len(weight))
+			dB := 0.0
+
+			for j := start; j < end; j++ {
+
+				err := trainY[j] - bias
+				for f := range weight {
+					err -= weight[f] * trainX[f][j]
+				}
+
+				for f := range weight {
+					dM[f] += multiplier * trainX[f][j] * err
+				}
+				dB += multiplier * err
+
+			}
+
+			for f := range weight {
+				weight[f] -= dM[f] + learningRate*(l1*sign(weight[f])+2*l2*weight[f])
+			}
+			bias -= dB
+
+		}
+	}
+
+	return weight, bias
+
+}
+
+func TestLinearRegressionTrain(t *testing.T) {
+
+	utils := utilitytest.NewUtils(utilitytest.InsecureParameters(), math.Pow(2, 40), (*utility.RotationPlanner).AddSumElements)
+
+	x := []*rlwe.Ciphertext{utils.EncryptToPointer(trainX[0]), utils.EncryptToPointer(trainX[1])}
+	y := utils.EncryptToPointer(trainY)
+
+	validate := func(name string, model LinearRegression, weight []float64, bias float64) {
+
+		for f := range weight {
+			if decrypted := utils.Decrypt(model.Weight[f]); math.Abs(decrypted[0]-weight[f]) > 1e-3 || math.Abs(decrypted[len(trainY)]-weight[f]) > 1e-3 {
+				t.Errorf("%s: weight %d expected %f in every slot but got %f and %f", name, f, weight[f], decrypted[0], decrypted[len(trainY)])
+			}
+		}
+
+		if decrypted := utils.Decrypt(model.Bias)[0]; math.Abs(decrypted-bias) > 1e-3 {
+			t.Errorf("%s: bias expected %f but got %f", name, bias, decrypted)
+		}
+
+	}
+
+	fullBatch := NewLinearRegression(utils, 2)
+	fullBatch.Train(x, y, 0.5, len(trainY), 2)
+
+	weight, bias := plainGradientDescent(len(trainY), 2, 0.5, 0, 0, math.Tanh)
+	validate("full batch", fullBatch, weight, bias)
+
+	// Last batch holds the remaining 2 data
+	loader := dataset.NewStandardLoader(map[string]*rlwe.Ciphertext{"x1": x[0], "x2": x[1]}, []string{"x1", "x2"}, []*rlwe.Ciphertext{y}, utils, len(trainY))
+
+	miniBatch := NewLinearRegression(utils, 2)
+	miniBatch.SetL1(0.1, 2)
+	miniBatch.L2 = 0.05
+	miniBatch.TrainMiniBatch(loader, 4, 0.5, 1)
+
+	weight, bias = plainGradientDescent(4, 1, 0.5, 0.1, 0.05, miniBatch.sign.EvaluatePlain)
+	validate("mini-batch with penalties", miniBatch, weight, bias)
+
+}
+
+func TestLinearRegressionEvaluate(t *testing.T) {
+
+	utils := utilitytest.NewUtils(utilitytest.InsecureParameters(), math.Pow(2, 40), (*utility.RotationPlanner).AddSumElements)
+
+	weight := []float64{0.7, -0.3}
+	bias := 0.1
+
+	model := NewLinearRegression(utils, 2)
+	for i := range weight {
+		model.Weight[i] = utils.EncryptToPointer(utils.GenerateFilledArray(weight[i]))
+	}
+	model.Bias = utils.EncryptToPointer(utils.GenerateFilledArray(bias))
+
+	// Labels after the holdout data aren't part of the evaluation
+	labels := append(append([]float64{}, trainY...), 5, -5, 5)
+
+	evaluation := model.Evaluate([]*rlwe.Ciphertext{utils.EncryptToPointer(trainX[0]), utils.EncryptToPointer(trainX[1])}, utils.EncryptToPointer(labels), len(trainY))
+	mse, r2, err := evaluation.Decrypt(utils)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	squaredError, mean, total := 0.0, 0.0, 0.0
+	for j := range trainY {
+		err := trainY[j] - weight[0]*trainX[0][j] - weight[1]*trainX[1][j] - bias
+		squaredError += err * err
+		mean += trainY[j] / float64(len(trainY))
+	}
+	for j := range trainY {
+		total += (trainY[j] - mean) * (trainY[j] - mean)
+	}
+
+	if expected := squaredError / float64(len(trainY)); math.Abs(mse-expected) > 1e-3 {
+		t.Errorf("Expected MSE %f but got %f", expected, mse)
+	}
+
+	if expected := 1 - squaredError/total; math.Abs(r2-expected) > 1e-3 {
+		t.Errorf("Expected R² %f but got %f", expected, r2)
+	}
+
+	// R² is undefined when every label is the same
+	constant := model.Evaluate([]*rlwe.Ciphertext{utils.EncryptToPointer(trainX[0]), utils.EncryptToPointer(trainX[1])}, utils.EncryptToPointer(utils.GenerateFilledArraySize(0.5, len(trainY))), len(trainY))
+	if _, _, err = constant.Decrypt(utils); err == nil {
+		t.Error("Decrypting R² of constant labels should return error")
+	}
+
+}
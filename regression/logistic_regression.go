@@ -10,9 +10,11 @@ import (
 )
 
 type LogisticRegression struct {
-	utils  utility.Utils
-	Weight []*rlwe.Ciphertext
-	Bias   *rlwe.Ciphertext
+	utils       utility.Utils
+	Weight      []*rlwe.Ciphertext
+	Bias        *rlwe.Ciphertext
+	PlainWeight []float64 // Used by Predict instead of Weight and Bias when set
+	PlainBias   float64
 }
 
 type LogisticRegressionGradient struct {
@@ -35,10 +37,15 @@ func NewLogisticRegression(u utility.Utils, numOfFeatures int) LogisticRegressio
 		w[i] = u.EncryptToPointer(value)
 	}
 
-	return LogisticRegression{u, w, b}
+	return LogisticRegression{utils: u, Weight: w, Bias: b}
 
 }
 
+// Create model for encrypted inference from plaintext weights trained elsewhere
+func NewLogisticRegressionFromWeights(u utility.Utils, weight []float64, bias float64) LogisticRegression {
+	return LogisticRegression{utils: u, PlainWeight: weight, PlainBias: bias}
+}
+
 func (model LogisticRegression) Forward(data Data) *rlwe.Ciphertext {
 
 	//prediction(yhat) = sigmoid(w1*x1+w2*x2+...+b)
@@ -60,6 +67,29 @@ func (model LogisticRegression) Forward(data Data) *rlwe.Ciphertext {
 
 }
 
+// Predict encrypted probability of x for inference only. Unlike Forward, input isn't copied, the weighted sum is rescaled
+// once and it is only bootstrapped if it doesn't have enough level left for the sigmoid approximation.
+// Plaintext weights are used if the model was created with NewLogisticRegressionFromWeights
+func (model LogisticRegression) Predict(x []*rlwe.Ciphertext, dataLength int) *rlwe.Ciphertext {
+
+	var result *rlwe.Ciphertext
+	sigmoid := activations.Sigmoid{U: model.utils}
+
+	if model.PlainWeight != nil {
+		result = model.utils.PlainDotProduct(x, model.PlainWeight)
+		model.utils.Evaluator.AddConst(result, model.PlainBias, result)
+	} else {
+		result = model.utils.InterDotProduct(x, model.Weight, true, false, nil)
+		model.utils.Add(result, model.Bias, result)
+	}
+
+	linear := []*rlwe.Ciphertext{result}
+	model.utils.Bootstrap1dIfBelow(linear, sigmoid.GetForwardLevelConsumption())
+
+	return sigmoid.Forward(linear, dataLength)[0]
+
+}
+
 func (model LogisticRegression) Backward(data Data, predict *rlwe.Ciphertext, lr float64) LogisticRegressionGradient {
 
 	dw := make([]*rlwe.Ciphertext, len(model.Weight))
@@ -7,8 +7,10 @@ import (
 	"testing"
 	"time"
 
+	"github.com/tuneinsight/lattigo/v4/ckks"
 	"github.com/tuneinsight/lattigo/v4/rlwe"
 	"github.com/perm-ai/go-cerebrum/array"
+	"github.com/perm-ai/go-cerebrum/internal/utilitytest"
 	"github.com/perm-ai/go-cerebrum/key"
 	"github.com/perm-ai/go-cerebrum/logger"
 	"github.com/perm-ai/go-cerebrum/utility"
@@ -216,3 +218,37 @@ func AddArrays(input1 []float64, input2 []float64) []float64 {
 	}
 	return output
 }
+
+func TestLogisticRegressionPredict(t *testing.T) {
+
+	utils := utilitytest.NewUtils(key.NewParameters(ckks.PN13QP218, nil), math.Pow(2, 30))
+
+	x := [][]float64{{0.5, -1, 0.25}, {1, 0.5, -0.5}}
+	weight := []float64{1.5, -2}
+	bias := 0.5
+
+	input := []*rlwe.Ciphertext{utils.EncryptToPointer(x[0]), utils.EncryptToPointer(x[1])}
+
+	plainModel := NewLogisticRegressionFromWeights(utils, weight, bias)
+
+	encryptedModel := NewLogisticRegression(utils, 2)
+	for i := range weight {
+		encryptedModel.Weight[i] = utils.EncryptToPointer(utils.GenerateFilledArray(weight[i]))
+	}
+	encryptedModel.Bias = utils.EncryptToPointer(utils.GenerateFilledArray(bias))
+
+	for name, model := range map[string]LogisticRegression{"plaintext": plainModel, "encrypted": encryptedModel} {
+
+		result := utils.Decrypt(model.Predict(input, len(x[0])))
+
+		for i := range x[0] {
+			linear := weight[0]*x[0][i] + weight[1]*x[1][i] + bias
+			expected := 0.5 + 0.197*linear - 0.004*math.Pow(linear, 3)
+			if math.Abs(result[i]-expected) > 1e-3 {
+				t.Errorf("Prediction with %s weights of data %d expected %f but got %f", name, i, expected, result[i])
+			}
+		}
+
+	}
+
+}
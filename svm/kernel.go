@@ -2,6 +2,7 @@ package svm
 
 import (
 	"errors"
+	"math"
 
 	"github.com/tuneinsight/lattigo/v4/rlwe"
 	"github.com/perm-ai/go-cerebrum/utility"
@@ -17,6 +18,10 @@ type Linear struct {
 	u			utility.Utils
 }
 
+func NewLinear(u utility.Utils) Linear {
+	return Linear{u}
+}
+
 func (l Linear) Calculate(xi []*rlwe.Ciphertext, xj []*rlwe.Ciphertext) (*rlwe.Ciphertext, error) {
 
 	if len(xi) != len(xj){
@@ -84,4 +89,77 @@ func (r RBF) Calculate(xi []*rlwe.Ciphertext, xj []*rlwe.Ciphertext) (*rlwe.Ciph
 
 func (r RBF) Type() string {
 	return "rbf"
-}
\ No newline at end of file
+}
+
+// Polynomial kernel (Gamma * xi·xj + Coef0) ^ Degree
+type Polynomial struct {
+	u      utility.Utils
+	Gamma  float64
+	Coef0  float64
+	Degree int
+	poly   utility.Polynomial
+}
+
+func NewPolynomial(u utility.Utils, gamma float64, coef0 float64, degree int) Polynomial {
+
+	// Binomial expansion of (gamma * t + coef0) ^ degree
+	coeffs := make([]float64, degree+1)
+	binomial := 1.0
+	for k := 0; k <= degree; k++ {
+		coeffs[k] = binomial * math.Pow(gamma, float64(k)) * math.Pow(coef0, float64(degree-k))
+		binomial = binomial * float64(degree-k) / float64(k+1)
+	}
+
+	return Polynomial{u, gamma, coef0, degree, utility.NewPolynomial(coeffs, u)}
+
+}
+
+func (p Polynomial) Calculate(xi []*rlwe.Ciphertext, xj []*rlwe.Ciphertext) (*rlwe.Ciphertext, error) {
+
+	dot, err := Linear{p.u}.Calculate(xi, xj)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.poly.Evaluate(dot, p.u.Params.Slots()), nil
+
+}
+
+func (p Polynomial) Type() string {
+	return "polynomial"
+}
+
+// Degree of Chebyshev approximation of tanh used by sigmoid kernel
+const sigmoidKernelDegree = 15
+
+// Sigmoid kernel tanh(Gamma * xi·xj + Coef0) where tanh is approximated over xi·xj in [-Bound, Bound]
+type Sigmoid struct {
+	u     utility.Utils
+	Gamma float64
+	Coef0 float64
+	Bound float64
+	poly  utility.Polynomial
+}
+
+func NewSigmoid(u utility.Utils, gamma float64, coef0 float64, bound float64) Sigmoid {
+
+	poly := utility.FitChebyshev(func(t float64) float64 { return math.Tanh(gamma*t + coef0) }, -bound, bound, sigmoidKernelDegree, u)
+
+	return Sigmoid{u, gamma, coef0, bound, poly}
+
+}
+
+func (s Sigmoid) Calculate(xi []*rlwe.Ciphertext, xj []*rlwe.Ciphertext) (*rlwe.Ciphertext, error) {
+
+	dot, err := Linear{s.u}.Calculate(xi, xj)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.poly.Evaluate(dot, s.u.Params.Slots()), nil
+
+}
+
+func (s Sigmoid) Type() string {
+	return "sigmoid"
+}
@@ -0,0 +1,286 @@
+package svm
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/perm-ai/go-cerebrum/utility"
+	"github.com/tuneinsight/lattigo/v4/rlwe"
+)
+
+// MultiClassSVM trains one binary SVM per class against the rest. Alphas of the classes are packed in blocks of
+// a power of two slots holding the training data, so classes sharing a ciphertext are trained together
+type MultiClassSVM struct {
+	u                *utility.Utils
+	Classes          int
+	Features         int
+	Models           []SVM                    // Binary model of each class against the rest, set by Fit
+	Alphas           []*rlwe.Ciphertext       // Packed alphas, class c is in block c % Blocks of ciphertext c / Blocks
+	BlockSize        int                      // Slots of each block, set by Fit
+	Blocks           int                      // Classes packed in each ciphertext, set by Fit
+	Comparison       utility.ComparisonParams // Same as SVM.Comparison, set by Fit when Bound is 0
+	ArgmaxComparison utility.ComparisonParams // Decision values must lie in [-Bound/2, Bound/2], set by Fit when Bound is 0
+	kernel           Kernel
+}
+
+func NewMultiClassSVM(u utility.Utils, classes int, feature int, kernel Kernel) MultiClassSVM {
+	return MultiClassSVM{u: &u, Classes: classes, Features: feature, kernel: kernel}
+}
+
+// Size of each block and number of blocks in a ciphertext of slots for dataLength training data
+func (model MultiClassSVM) layout(dataLength int, slots int) (int, int) {
+
+	blockSize := 1
+	for blockSize < dataLength {
+		blockSize *= 2
+	}
+
+	blocks := slots / blockSize
+	if blocks > model.Classes {
+		blocks = model.Classes
+	}
+
+	return blockSize, blocks
+
+}
+
+// Rotations performed by Fit with dataLength training data
+func (model MultiClassSVM) PlanRotations(planner *utility.RotationPlanner, dataLength int) {
+
+	planner.AddSumElements()
+
+	blockSize, blocks := model.layout(dataLength, planner.Slots())
+
+	for k := 1; k < blockSize; k *= 2 {
+		planner.AddRotations([]int{k, -k})
+	}
+
+	for b := 1; b < blocks; b++ {
+		planner.AddRotations([]int{b * blockSize, -b * blockSize})
+	}
+
+}
+
+// Train a model of each class against the rest with Pegasos like SVM.Fit. x holds a ciphertext per feature and
+// y holds one-hot labels as a ciphertext per class where the first dataLength slots hold the training data
+func (model *MultiClassSVM) Fit(x []*rlwe.Ciphertext, y []*rlwe.Ciphertext, dataLength int, iterations int, lambda float64) error {
+
+	if len(x) != model.Features {
+		return fmt.Errorf("INVALID INPUT: expected %d features but got %d", model.Features, len(x))
+	}
+
+	if len(y) != model.Classes {
+		return fmt.Errorf("INVALID INPUT: expected labels of %d classes but got %d", model.Classes, len(y))
+	}
+
+	if dataLength <= 0 || dataLength > model.u.Params.Slots() {
+		return fmt.Errorf("INVALID INPUT: data length must be in [1, %d] but got %d", model.u.Params.Slots(), dataLength)
+	}
+
+	if model.Comparison.Bound == 0 {
+		model.Comparison = utility.DefaultComparisonParams(1 + 2/lambda)
+	}
+
+	if model.ArgmaxComparison.Bound == 0 {
+		// Decision values are at most (|K| + 1) / lambda, assuming kernel values lie in [-1, 1]
+		model.ArgmaxComparison = utility.DefaultComparisonParams(4 / lambda)
+	}
+
+	model.BlockSize, model.Blocks = model.layout(dataLength, model.u.Params.Slots())
+	groups := (model.Classes + model.Blocks - 1) / model.Blocks
+
+	// Training data repeated in every block
+	packedX := make([]*rlwe.Ciphertext, model.Features)
+	for feature := range x {
+		copies := make([]*rlwe.Ciphertext, model.Blocks)
+		for b := range copies {
+			copies[b] = x[feature]
+		}
+		packedX[feature] = model.pack(copies)
+	}
+
+	// Labels of each class against the rest, 1 for the class and -1 for the others
+	negativeOnes := make([]float64, model.u.Params.Slots())
+	for i := 0; i < dataLength; i++ {
+		negativeOnes[i] = -1
+	}
+
+	packedY := make([]*rlwe.Ciphertext, groups)
+	model.Alphas = make([]*rlwe.Ciphertext, groups)
+
+	for g := range packedY {
+
+		labels := []*rlwe.Ciphertext{}
+		for class := g * model.Blocks; class < model.Classes && class < (g+1)*model.Blocks; class++ {
+			label := model.u.MultiplyConstNew(y[class], 2, false, false)
+			model.u.AddPlain(label, model.u.EncodePlaintextFromArrayScale(negativeOnes, label.Scale.Float64()), label)
+			labels = append(labels, label)
+		}
+
+		packedY[g] = model.pack(labels)
+		model.Alphas[g] = model.u.EncryptToPointer(model.u.GenerateFilledArray(0))
+
+	}
+
+	// Start of each block, used to fill blocks with their sums
+	blockStarts := make([]float64, model.u.Params.Slots())
+	for b := 0; b < model.Blocks; b++ {
+		blockStarts[b*model.BlockSize] = 1
+	}
+	encodedBlockStarts := model.u.EncodePlaintextFromArray(blockStarts)
+
+	// Binary model running the shared steps of Pegasos on packed data
+	binary := SVM{u: model.u, Features: model.Features, Comparison: model.Comparison, kernel: model.kernel}
+
+	// Bootstrap decision of each block before filling it since filling consumes a level
+	sum := func(decision *rlwe.Ciphertext) {
+		model.u.Bootstrap1dIfBelow([]*rlwe.Ciphertext{decision}, 2)
+		model.fillBlocks(decision, encodedBlockStarts)
+	}
+
+	for t := 1; t <= iterations; t++ {
+
+		// Get random index, shared by every class
+		rand.Seed(time.Now().UnixNano())
+		it := rand.Intn(dataLength)
+
+		// Kernel of data at random index with every training data, the same in every block
+		kernel, kernelErr := binary.sampleKernel(x, packedX, it)
+		if kernelErr != nil {
+			return kernelErr
+		}
+
+		for g := range model.Alphas {
+
+			// Random index of every class in the group
+			groupFilter := make([]float64, model.u.Params.Slots())
+			for b := 0; b < model.Blocks && g*model.Blocks+b < model.Classes; b++ {
+				groupFilter[b*model.BlockSize+it] = 1
+			}
+
+			binary.pegasosStep(model.Alphas[g], packedY[g], kernel, sum, groupFilter, t, lambda)
+
+		}
+
+	}
+
+	// Unpack coefficients of each class into its binary model
+	model.Models = make([]SVM, model.Classes)
+
+	for g := range model.Alphas {
+
+		model.u.Bootstrap1dIfBelow([]*rlwe.Ciphertext{model.Alphas[g]}, 3)
+		coefficients := model.u.MultiplyNew(model.Alphas[g], packedY[g], true, false)
+		model.u.MultiplyConst(coefficients, 1.0/(lambda*float64(iterations)), coefficients, true, false)
+
+		for b := 0; b < model.Blocks && g*model.Blocks+b < model.Classes; b++ {
+
+			class := g*model.Blocks + b
+
+			block := make([]float64, model.u.Params.Slots())
+			for i := 0; i < dataLength; i++ {
+				block[b*model.BlockSize+i] = 1
+			}
+
+			classCoefficients := model.u.MultiplyPlainNew(coefficients, model.u.EncodePlaintextFromArray(block), true, false)
+			model.u.Rotate(classCoefficients, b*model.BlockSize)
+
+			model.Models[class] = NewSVM(*model.u, model.Features, model.kernel)
+			model.Models[class].Comparison = model.Comparison
+			model.Models[class].setCoefficients(classCoefficients, x, dataLength)
+
+		}
+
+	}
+
+	return nil
+
+}
+
+// Place cts[b] in block b of a new ciphertext. Slots of each ciphertext after its block size must be 0
+func (model MultiClassSVM) pack(cts []*rlwe.Ciphertext) *rlwe.Ciphertext {
+
+	packed := cts[0].CopyNew()
+
+	for b := 1; b < len(cts); b++ {
+		rotated := model.u.RotateNew(cts[b], -b*model.BlockSize)
+		model.u.Add(packed, &rotated, packed)
+	}
+
+	return packed
+
+}
+
+// Sum slots of each block and fill the block with its sum, consuming 1 level
+func (model MultiClassSVM) fillBlocks(ct *rlwe.Ciphertext, blockStarts *rlwe.Plaintext) {
+
+	// Start of each block holds the sum of the block
+	for k := 1; k < model.BlockSize; k *= 2 {
+		rotated := model.u.RotateNew(ct, k)
+		model.u.Add(ct, &rotated, ct)
+	}
+
+	model.u.MultiplyPlain(ct, blockStarts, ct, true, false)
+
+	for k := 1; k < model.BlockSize; k *= 2 {
+		rotated := model.u.RotateNew(ct, -k)
+		model.u.Add(ct, &rotated, ct)
+	}
+
+}
+
+// Decision value of every data in x for each class, computed concurrently. Scores can be passed to
+// utils.ArgmaxAcross with ArgmaxComparison to find the predicted class
+func (model MultiClassSVM) Scores(x []*rlwe.Ciphertext) ([]*rlwe.Ciphertext, error) {
+
+	if len(model.Models) != model.Classes {
+		return nil, fmt.Errorf("expected a model for each of %d classes but got %d", model.Classes, len(model.Models))
+	}
+
+	scores := make([]*rlwe.Ciphertext, model.Classes)
+	errs := make([]error, model.Classes)
+
+	var wg sync.WaitGroup
+
+	for class := range model.Models {
+
+		wg.Add(1)
+
+		go func(class int, utils utility.Utils) {
+
+			defer wg.Done()
+
+			classModel := model.Models[class]
+			classModel.u = &utils
+
+			scores[class], errs[class] = classModel.Predict(x)
+
+		}(class, model.u.CopyWithClonedEval())
+
+	}
+
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return scores, nil
+
+}
+
+// Encrypted index of the class with the highest decision value for every data in x
+func (model MultiClassSVM) Predict(x []*rlwe.Ciphertext) (*rlwe.Ciphertext, error) {
+
+	scores, err := model.Scores(x)
+	if err != nil {
+		return nil, err
+	}
+
+	return model.u.ArgmaxAcross(scores, model.ArgmaxComparison), nil
+
+}
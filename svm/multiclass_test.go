@@ -0,0 +1,91 @@
+package svm
+
+import (
+	"math"
+	"testing"
+
+	"github.com/perm-ai/go-cerebrum/internal/utilitytest"
+	"github.com/perm-ai/go-cerebrum/utility"
+	"github.com/tuneinsight/lattigo/v4/rlwe"
+)
+
+func TestMultiClassFitAndScores(t *testing.T) {
+
+	utils := utilitytest.NewUtils(utilitytest.InsecureParameters(), math.Pow(2, 40), (*utility.RotationPlanner).AddSumElements, func(planner *utility.RotationPlanner) {
+		MultiClassSVM{Classes: 3}.PlanRotations(planner, 2)
+	})
+
+	// Two identical data of class 0, so each class model behaves like the binary model in TestFitAndPredict
+	x := []*rlwe.Ciphertext{utils.EncryptToPointer([]float64{1, 1})}
+	y := []*rlwe.Ciphertext{
+		utils.EncryptToPointer([]float64{1, 1}),
+		utils.EncryptToPointer([]float64{0, 0}),
+		utils.EncryptToPointer([]float64{0, 0}),
+	}
+
+	model := NewMultiClassSVM(utils, 3, 1, NewLinear(utils))
+	model.Comparison = utility.ComparisonParams{Bound: 5, GIteration: 1, FIteration: 1}
+
+	if err := model.Fit(x, y[:2], 2, 2, 0.25); err == nil {
+		t.Error("Labels of fewer classes should return an error")
+	}
+
+	if err := model.Fit(x, y, 2, 2, 0.25); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(model.Alphas) != 1 || model.BlockSize != 2 {
+		t.Fatalf("Expected every class in one ciphertext with blocks of 2 slots but got %d ciphertexts of block size %d", len(model.Alphas), model.BlockSize)
+	}
+
+	alphas := utils.Decrypt(model.Alphas[0])
+	for class := 0; class < 3; class++ {
+		if sum := alphas[2*class] + alphas[2*class+1]; math.Abs(sum-1) > 0.05 {
+			t.Errorf("Only the first iteration should update alpha of class %d but got alphas %v", class, alphas[2*class:2*class+2])
+		}
+	}
+
+	scores, err := model.Scores([]*rlwe.Ciphertext{utils.EncryptToPointer([]float64{1, -1})})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Class 0 has w = b = 2 and the other classes w = b = -2
+	expected := [][]float64{{4, 0}, {-4, 0}, {-4, 0}}
+	for class := range scores {
+		decrypted := utils.Decrypt(scores[class])
+		if math.Abs(decrypted[0]-expected[class][0]) > 0.2 || math.Abs(decrypted[1]-expected[class][1]) > 0.2 {
+			t.Errorf("Class %d expected decision values %v but got %v", class, expected[class], decrypted[0:2])
+		}
+	}
+
+}
+
+func TestMultiClassPredict(t *testing.T) {
+
+	utils := utilitytest.NewUtils(utilitytest.InsecureParameters(), math.Pow(2, 40), (*utility.RotationPlanner).AddSumElements)
+
+	// Weights of one feature that pick class 0 for negative data, class 1 around 0 and class 2 for positive data
+	model := NewMultiClassSVM(utils, 3, 1, NewLinear(utils))
+	model.ArgmaxComparison = utility.ComparisonParams{Bound: 4, GIteration: 1, FIteration: 2}
+	model.Models = []SVM{NewSVMFromWeights(utils, []float64{-1}), NewSVMFromWeights(utils, []float64{0}), NewSVMFromWeights(utils, []float64{1})}
+	model.Models[1].PlainBias = 0.5
+
+	predicted, err := model.Predict([]*rlwe.Ciphertext{utils.EncryptToPointer([]float64{-1, 0, 1})})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decrypted := utils.Decrypt(predicted)
+	for i, class := range []float64{0, 1, 2} {
+		if math.Abs(decrypted[i]-class) > 0.1 {
+			t.Errorf("Data %d expected class %f but got %f", i, class, decrypted[i])
+		}
+	}
+
+	model.Models = model.Models[:2]
+	if _, err := model.Predict([]*rlwe.Ciphertext{utils.EncryptToPointer([]float64{0})}); err == nil {
+		t.Error("Model without a binary model for every class should return an error")
+	}
+
+}
@@ -1,19 +1,28 @@
 package svm
 
 import (
+	"errors"
+	"fmt"
 	"math/rand"
 	"time"
 
-	"github.com/tuneinsight/lattigo/v4/rlwe"
 	"github.com/perm-ai/go-cerebrum/utility"
+	"github.com/tuneinsight/lattigo/v4/rlwe"
 )
 
 type SVM struct {
-	u      		*utility.Utils
-	Features	int
-	Weights 	[]*rlwe.Ciphertext
-	Alphas		*rlwe.Ciphertext
-	kernel 		Kernel
+	u              *utility.Utils
+	Features       int
+	Weights        []*rlwe.Ciphertext
+	Bias           *rlwe.Ciphertext
+	Alphas         *rlwe.Ciphertext
+	Coefficients   *rlwe.Ciphertext   // alpha * y / (lambda * iterations) of each training data, set by Fit
+	SupportVectors []*rlwe.Ciphertext // Training data kept by Fit for prediction with non linear kernel
+	SupportLength  int
+	Comparison     utility.ComparisonParams // 1 - margin of training data must lie in [-Bound, Bound], set by Fit when Bound is 0
+	kernel         Kernel
+	PlainWeights   []float64 // Used by Predict instead of Weights and Bias when set
+	PlainBias      float64
 }
 
 func NewSVM(u utility.Utils, feature int, kernel Kernel) SVM {
@@ -26,83 +35,221 @@ func NewSVM(u utility.Utils, feature int, kernel Kernel) SVM {
 		encryptedWeight[i] = u.EncryptToPointer(weightPlain)
 	}
 
-	return SVM{&u, feature, encryptedWeight, &rlwe.Ciphertext{}, kernel}
+	return SVM{u: &u, Features: feature, Weights: encryptedWeight, Alphas: &rlwe.Ciphertext{}, kernel: kernel}
 
 }
 
-// Optimize the alpha value of an SVM model, if kernel is linear compute weight.
-// This optimization problem implements a Pegasos method to optimize primal SVM with kernel function
-func (model *SVM) Fit(x []*rlwe.Ciphertext, y *rlwe.Ciphertext, dataLength int, iterations int, lambda float64){
+// Create linear SVM for encrypted inference from plaintext weights trained elsewhere. PlainBias can be set afterward
+func NewSVMFromWeights(u utility.Utils, weights []float64) SVM {
+	return SVM{u: &u, Features: len(weights), kernel: Linear{u}, PlainWeights: weights}
+}
 
-	model.Alphas = model.u.EncryptToPointer(model.u.GenerateFilledArray(0))
+// Calculate encrypted decision value of each data in x for inference only. The sign of the decision value is the predicted class.
+// Each ciphertext in x represents a feature of the data. Linear model uses w·x + b while other kernels compute
+// sum of alpha_j * y_j * (K(x_j, x) + 1) / (lambda * iterations) over support data kept by Fit
+func (model SVM) Predict(x []*rlwe.Ciphertext) (*rlwe.Ciphertext, error) {
 
-	for t := 1; t <= iterations; t++{
+	if len(x) != model.Features {
+		return nil, fmt.Errorf("INVALID INPUT: expected %d features but got %d", model.Features, len(x))
+	}
 
-		// Get random index
-		rand.Seed(time.Now().UnixNano())
-		it := rand.Intn(dataLength)
+	if model.kernel.Type() != "linear" {
+		return model.kernelDecision(x)
+	}
+
+	if model.PlainWeights != nil {
+		result := model.u.PlainDotProduct(x, model.PlainWeights)
+		model.u.Evaluator.AddConst(result, model.PlainBias, result)
+		return result, nil
+	}
+
+	result := model.u.InterDotProduct(x, model.Weights, true, false, nil)
+	if model.Bias != nil {
+		model.u.Add(result, model.Bias, result)
+	}
+
+	return result, nil
+
+}
+
+// Decision value of every data in x computed from support data one at a time
+func (model SVM) kernelDecision(x []*rlwe.Ciphertext) (*rlwe.Ciphertext, error) {
+
+	if model.Coefficients == nil {
+		return nil, errors.New("model must be fitted before predicting with non linear kernel")
+	}
+
+	var result *rlwe.Ciphertext
 
-		// Create an array of ciphertext to store each feature of data with random index
-		xi := make([]*rlwe.Ciphertext, model.Features)
+	for j := 0; j < model.SupportLength; j++ {
 
-		// Create filter to filter out data at random index
 		filter := make([]float64, model.u.Params.Slots())
-		filter[it] = 1
+		filter[j] = 1
 		encodedFilter := model.u.EncodePlaintextFromArray(filter)
 
-		// Loop through training data set and apply filter to extract data from that random index out
-		// then use sum element in place to make that ciphertext filled with that randomed index
-		for feature := range x{
-			xi[feature] = model.u.MultiplyPlainNew(x[feature], encodedFilter, true, true)
-			model.u.SumElementsInPlace(xi[feature])
+		xj := make([]*rlwe.Ciphertext, model.Features)
+		for feature := range xj {
+			xj[feature] = model.extractData(model.SupportVectors[feature], encodedFilter)
+		}
+
+		kernel, kernelErr := model.kernel.Calculate(xj, x)
+		if kernelErr != nil {
+			return nil, kernelErr
+		}
+
+		// Constant feature of 1 in kernel space acts as bias
+		model.u.Evaluator.AddConst(kernel, 1, kernel)
+
+		term := model.u.MultiplyNew(kernel, model.extractData(model.Coefficients, encodedFilter), true, false)
+
+		if result == nil {
+			result = term
+		} else {
+			model.u.Add(result, term, result)
 		}
 
-		// Calculate alpha * y
-		scalar := model.u.MultiplyNew(model.Alphas, y, true, false)
+	}
+
+	return result, nil
+
+}
+
+// Filter out data selected by filter and fill every slot with it
+func (model SVM) extractData(ct *rlwe.Ciphertext, filter *rlwe.Plaintext) *rlwe.Ciphertext {
+
+	data := model.u.MultiplyPlainNew(ct, filter, true, true)
+	model.u.SumElementsInPlace(data)
+
+	return data
+
+}
+
+// Optimize the alpha value of an SVM model, if kernel is linear compute weight.
+// This optimization problem implements a Pegasos method to optimize primal SVM with kernel function.
+// Bias is learned as the weight of a constant feature of 1 added to the kernel
+func (model *SVM) Fit(x []*rlwe.Ciphertext, y *rlwe.Ciphertext, dataLength int, iterations int, lambda float64) {
+
+	if model.Comparison.Bound == 0 {
+		// Margin is at most (|K| + 1) / lambda, assuming kernel values lie in [-1, 1]
+		model.Comparison = utility.DefaultComparisonParams(1 + 2/lambda)
+	}
+
+	model.Alphas = model.u.EncryptToPointer(model.u.GenerateFilledArray(0))
 
-		// Calculate kernel
-		decision, kernelErr := model.kernel.Calculate(xi, x)
+	for t := 1; t <= iterations; t++ {
+
+		// Get random index
+		rand.Seed(time.Now().UnixNano())
+		it := rand.Intn(dataLength)
+
+		kernel, kernelErr := model.sampleKernel(x, x, it)
 
 		// Catch kernel error
 		if kernelErr != nil {
 			panic(kernelErr)
 		}
 
-		// Calculate decision
-		model.u.Multiply(decision, scalar, decision, true, false)
-		model.u.SumElementsInPlace(decision)
+		filter := make([]float64, model.u.Params.Slots())
+		filter[it] = 1
 
-		// Calculate and encode eta
-		etaArray := make([]float64, model.u.Params.Slots())
-		etaArray[it] = 1.0 / (lambda * float64(t))
-		eta := model.u.EncodePlaintextFromArray(etaArray)
+		model.pegasosStep(model.Alphas, y, kernel, model.u.SumElementsInPlace, filter, t, lambda)
 
-		// Apply eta
-		model.u.MultiplyPlain(decision, eta, decision, true, false)
+	}
 
-		// Pass through decision function turning number <1 into 1 and >1 into 0
-		// TODO: Add decision function
+	coefficients := model.u.MultiplyNew(model.Alphas, y, true, false)
+	model.u.MultiplyConst(coefficients, 1.0/(lambda*float64(iterations)), coefficients, true, false)
+	model.setCoefficients(coefficients, x, dataLength)
 
-		// Add decision to alpha
-		model.u.Add(decision, model.Alphas, model.Alphas)
-	}
+}
+
+// Keep coefficients alpha * y / (lambda * iterations) with support data, then compute bias and weight of linear kernel from them
+func (model *SVM) setCoefficients(coefficients *rlwe.Ciphertext, x []*rlwe.Ciphertext, dataLength int) {
+
+	model.Coefficients = coefficients
+	model.SupportVectors = x
+	model.SupportLength = dataLength
+
+	// Bias is the weight of the constant feature
+	model.Bias = model.u.SumElementsNew(*model.Coefficients)
 
 	if model.kernel.Type() == "linear" {
 
 		// Calculate weight if it is a linear kernel
-		for feature := range model.Weights{
+		model.u.Bootstrap1dIfBelow([]*rlwe.Ciphertext{model.Coefficients}, 1)
 
-			model.Weights[feature] = model.u.MultiplyNew(model.Alphas, y, true, false)
-			model.u.Multiply(x[feature], model.Weights[feature], model.Weights[feature], true, false)
+		for feature := range model.Weights {
+			model.Weights[feature] = model.u.MultiplyNew(model.Coefficients, x[feature], true, false)
 			model.u.SumElementsInPlace(model.Weights[feature])
-			
-			regularizationConst := 1.0 / (lambda * float64(iterations))
-			regularization := model.u.EncodePlaintextFromArray(model.u.GenerateFilledArray(regularizationConst))
+		}
 
-			model.u.MultiplyPlain(model.Weights[feature], regularization, model.Weights[feature], true, false)
+	}
 
-		}
+}
+
+// Kernel of the data at index it of x with every data in trainingX, plus a constant feature of 1 that acts as bias
+func (model SVM) sampleKernel(x []*rlwe.Ciphertext, trainingX []*rlwe.Ciphertext, it int) (*rlwe.Ciphertext, error) {
 
+	// Create filter to filter out data at random index
+	filter := make([]float64, model.u.Params.Slots())
+	filter[it] = 1
+	encodedFilter := model.u.EncodePlaintextFromArray(filter)
+
+	// Extract data at random index and fill every slot with it
+	xi := make([]*rlwe.Ciphertext, model.Features)
+	for feature := range x {
+		xi[feature] = model.extractData(x[feature], encodedFilter)
+	}
+
+	kernel, err := model.kernel.Calculate(xi, trainingX)
+	if err != nil {
+		return nil, err
 	}
 
-}
\ No newline at end of file
+	// Constant feature of 1 in kernel space acts as bias
+	model.u.Evaluator.AddConst(kernel, 1, kernel)
+	model.u.Bootstrap1dIfBelow([]*rlwe.Ciphertext{kernel}, 2)
+
+	return kernel, nil
+
+}
+
+// Pegasos step at iteration t adding 1 to alphas in the slots of filter where the sampled data violates the margin.
+// sum fills the slot of the sampled data with its decision value summed over the kernel times alpha * y
+func (model SVM) pegasosStep(alphas *rlwe.Ciphertext, y *rlwe.Ciphertext, kernel *rlwe.Ciphertext, sum func(*rlwe.Ciphertext), filter []float64, t int, lambda float64) {
+
+	// Calculate alpha * y
+	model.u.Bootstrap1dIfBelow([]*rlwe.Ciphertext{alphas}, 3)
+	scalar := model.u.MultiplyNew(alphas, y, true, false)
+
+	// Calculate decision
+	decision := model.u.MultiplyNew(kernel, scalar, true, false)
+	sum(decision)
+
+	// Calculate margin y_i * decision / (lambda * t), only the slots at random index are used
+	scaledY := model.u.MultiplyConstNew(y, 1.0/(lambda*float64(t)), true, false)
+	model.u.Multiply(decision, scaledY, decision, true, false)
+
+	// Pass through decision function turning margin < 1 into 1 and > 1 into 0
+	violation := model.u.Evaluator.NegNew(decision)
+	model.u.Evaluator.AddConst(violation, 1, violation)
+	update := model.u.Step(violation, model.Comparison)
+
+	// Add decision to alpha of data at random index
+	model.u.Bootstrap1dIfBelow([]*rlwe.Ciphertext{update}, 1)
+	update = model.maskToScale(update, filter, alphas.Scale)
+	model.u.Add(update, alphas, alphas)
+
+}
+
+// Multiply ct by filter encoded so the product has the given scale after rescaling, consuming 1 level.
+// Output of step approximation has a slightly different scale from alpha and can only be added to it without error this way
+func (model SVM) maskToScale(ct *rlwe.Ciphertext, filter []float64, scale rlwe.Scale) *rlwe.Ciphertext {
+
+	filterScale := scale.Float64() * model.u.Params.QiFloat64(ct.Level()) / ct.Scale.Float64()
+
+	result := model.u.MultiplyPlainNew(ct, model.u.EncodePlaintextFromArrayScale(filter, filterScale), true, false)
+	result.Scale = scale
+
+	return result
+
+}
@@ -0,0 +1,137 @@
+package svm
+
+import (
+	"math"
+	"testing"
+
+	"github.com/perm-ai/go-cerebrum/internal/utilitytest"
+	"github.com/perm-ai/go-cerebrum/key"
+	"github.com/perm-ai/go-cerebrum/utility"
+	"github.com/tuneinsight/lattigo/v4/ckks"
+	"github.com/tuneinsight/lattigo/v4/rlwe"
+)
+
+func TestKernels(t *testing.T) {
+
+	utils := utilitytest.NewUtils(key.NewParameters(ckks.PN14QP438, nil), math.Pow(2, 34))
+
+	xi := [][]float64{{0.5, -1, 0.2}, {0.3, 0.4, -0.6}}
+	xj := [][]float64{{-0.2, 0.8, 0.9}, {0.7, 0.1, -0.4}}
+
+	encrypt := func(data [][]float64) []*rlwe.Ciphertext {
+		encrypted := make([]*rlwe.Ciphertext, len(data))
+		for i := range data {
+			encrypted[i] = utils.EncryptToPointer(data[i])
+		}
+		return encrypted
+	}
+
+	kernels := []Kernel{NewPolynomial(utils, 0.5, 1, 3), NewSigmoid(utils, 0.8, -0.1, 2)}
+	expectations := []func(dot float64) float64{
+		func(dot float64) float64 { return math.Pow(0.5*dot+1, 3) },
+		func(dot float64) float64 { return math.Tanh(0.8*dot - 0.1) },
+	}
+
+	for k, kernel := range kernels {
+
+		expected := expectations[k]
+
+		result, err := kernel.Calculate(encrypt(xi), encrypt(xj))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		decrypted := utils.Decrypt(result)
+		for i := range xi[0] {
+			dot := xi[0][i]*xj[0][i] + xi[1][i]*xj[1][i]
+			if math.Abs(decrypted[i]-expected(dot)) > 1e-3 {
+				t.Errorf("%s kernel of data %d expected %f but got %f", kernel.Type(), i, expected(dot), decrypted[i])
+			}
+		}
+
+		if _, err := kernel.Calculate(encrypt(xi), encrypt(xj[:1])); err == nil {
+			t.Errorf("%s kernel of data with different number of features should return an error", kernel.Type())
+		}
+
+	}
+
+}
+
+func TestFitAndPredict(t *testing.T) {
+
+	utils := utilitytest.NewUtils(utilitytest.InsecureParameters(), math.Pow(2, 40), (*utility.RotationPlanner).AddSumElements)
+
+	// Two identical positive data. First update gives the sampled data a margin of 4 so the second iteration
+	// must not update alpha whichever data is sampled
+	x := []*rlwe.Ciphertext{utils.EncryptToPointer([]float64{1, 1})}
+	y := utils.EncryptToPointer([]float64{1, 1})
+
+	// Violations 1 - margin are 1 and -3, so a tight bound keeps them distinguishable with fewer sign iterations
+	model := NewSVM(utils, 1, NewLinear(utils))
+	model.Comparison = utility.ComparisonParams{Bound: 5, GIteration: 1, FIteration: 1}
+	model.Fit(x, y, 2, 2, 0.25)
+
+	alphas := utils.Decrypt(model.Alphas)
+	if math.Abs(alphas[0]+alphas[1]-1) > 0.05 {
+		t.Errorf("Only the first iteration should update alpha but got alphas %v", alphas[0:2])
+	}
+
+	// w = b = sum of alpha * y / (lambda * iterations) = 2
+	if bias := utils.Decrypt(model.Bias)[0]; math.Abs(bias-2) > 0.1 {
+		t.Errorf("Expected bias 2 but got %f", bias)
+	}
+
+	decision, err := model.Predict([]*rlwe.Ciphertext{utils.EncryptToPointer([]float64{1, -1})})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if decrypted := utils.Decrypt(decision); math.Abs(decrypted[0]-4) > 0.2 || math.Abs(decrypted[1]) > 0.2 {
+		t.Errorf("Expected decision values 4 and 0 but got %v", decrypted[0:2])
+	}
+
+	if _, err := model.Predict([]*rlwe.Ciphertext{}); err == nil {
+		t.Error("Data with wrong number of features should return an error")
+	}
+
+}
+
+func TestPredictKernel(t *testing.T) {
+
+	utils := utilitytest.NewUtils(utilitytest.InsecureParameters(), math.Pow(2, 40), (*utility.RotationPlanner).AddSumElements)
+
+	support := [][]float64{{0.5, -0.5, 1}, {0.2, 0.6, -0.3}}
+	coefficients := []float64{0.4, -0.3, 0.2}
+	data := [][]float64{{0.1, -0.8}, {0.9, 0.3}}
+
+	model := NewSVM(utils, 2, NewPolynomial(utils, 1, 1, 2))
+
+	if _, err := model.Predict([]*rlwe.Ciphertext{utils.EncryptToPointer(data[0]), utils.EncryptToPointer(data[1])}); err == nil {
+		t.Error("Kernel prediction before fitting should return an error")
+	}
+
+	model.SupportVectors = []*rlwe.Ciphertext{utils.EncryptToPointer(support[0]), utils.EncryptToPointer(support[1])}
+	model.Coefficients = utils.EncryptToPointer(coefficients)
+	model.SupportLength = len(coefficients)
+
+	decision, err := model.Predict([]*rlwe.Ciphertext{utils.EncryptToPointer(data[0]), utils.EncryptToPointer(data[1])})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decrypted := utils.Decrypt(decision)
+	for i := range data[0] {
+
+		expected := 0.0
+		for j := range coefficients {
+			dot := support[0][j]*data[0][i] + support[1][j]*data[1][i]
+			expected += coefficients[j] * (math.Pow(dot+1, 2) + 1)
+		}
+
+		if math.Abs(decrypted[i]-expected) > 1e-3 {
+			t.Errorf("Decision value of data %d expected %f but got %f", i, expected, decrypted[i])
+		}
+
+	}
+
+}
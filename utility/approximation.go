@@ -3,7 +3,6 @@ package utility
 import (
 	"math"
 
-	"github.com/tuneinsight/lattigo/v4/ckks"
 	"github.com/tuneinsight/lattigo/v4/rlwe"
 )
 
@@ -22,7 +21,7 @@ func (u Utils) ExpNew(ciphertext *rlwe.Ciphertext, size int) *rlwe.Ciphertext {
 	}
 
 	poly := NewPolynomial(coeffs, u)
-	return poly.EvaluateDegree7(ciphertext, size)
+	return poly.Evaluate(ciphertext, size)
 
 }
 
@@ -47,7 +46,7 @@ func (u Utils) InverseApproxNew(ciphertext *rlwe.Ciphertext, stretchScale float6
 	}
 
 	poly := NewPolynomial(coeff, u)
-	return poly.EvaluateDegree7(ciphertext, size)
+	return poly.Evaluate(ciphertext, size)
 
 }
 
@@ -79,14 +78,9 @@ func (u Utils) SqrtApprox(ct *rlwe.Ciphertext, degree int, bound float64, scaleB
 
 	originalBound := 1.75
 
-	coeffs := map[int][]complex128{}
+	coeffs := map[int][]float64{}
 
-	coeffs[3] = []complex128{
-		complex(0.3125, 0),
-		complex((15.0 / 16.0), 0),
-		complex((-5.0 / 16.0), 0),
-		complex((1.0 / 16.0), 0),
-	}
+	coeffs[3] = []float64{0.3125, 15.0 / 16.0, -5.0 / 16.0, 1.0 / 16.0}
 
 	// TODO: IMPLEMENT DEG 7 APPROXIMATION
 	// coeffs[7] = []complex128{
@@ -108,19 +102,12 @@ func (u Utils) SqrtApprox(ct *rlwe.Ciphertext, degree int, bound float64, scaleB
 		m = (originalBound / bound)
 
 		for i, coeff := range coeffs[degree] {
-			coeffs[degree][i] = complex(real(coeff)*math.Pow(m, float64(i)), 0)
+			coeffs[degree][i] = coeff * math.Pow(m, float64(i))
 		}
 
 	}
 
-	poly := ckks.NewPoly(coeffs[degree])
-
-	var err error
-	var result *rlwe.Ciphertext
-
-	if result, err = u.Evaluator.EvaluatePoly(ct, poly, rlwe.NewScale(math.Pow(2, 35))); err != nil {
-		panic(err)
-	}
+	result := NewPolynomial(coeffs[degree], u).Evaluate(ct, u.Params.Slots())
 
 	if scaleBoundBack && bound > originalBound {
 		scalingBackFactor := (1 / math.Sqrt(m))
@@ -130,3 +117,19 @@ func (u Utils) SqrtApprox(ct *rlwe.Ciphertext, degree int, bound float64, scaleB
 	return result
 
 }
+
+// Chebyshev approximation of natural logarithm of values in [lower, upper]. Values outside the interval aren't
+// approximated correctly. Consumes FitChebyshev(math.Log, lower, upper, degree, u).GetLevelConsumption() levels
+func (u Utils) LogApprox(ct *rlwe.Ciphertext, lower float64, upper float64, degree int) *rlwe.Ciphertext {
+
+	return u.ChebyshevApprox(ct, math.Log, lower, upper, degree)
+
+}
+
+// Chebyshev approximation of function evaluated on values in [lower, upper]. Values outside the interval aren't
+// approximated correctly. Consumes FitChebyshev(function, lower, upper, degree, u).GetLevelConsumption() levels
+func (u Utils) ChebyshevApprox(ct *rlwe.Ciphertext, function func(float64) float64, lower float64, upper float64, degree int) *rlwe.Ciphertext {
+
+	return FitChebyshev(function, lower, upper, degree, u).Evaluate(ct, u.Params.Slots())
+
+}
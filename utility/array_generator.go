@@ -5,18 +5,49 @@ import (
 	"math/rand"
 	"time"
 
+	"github.com/perm-ai/go-cerebrum/key"
 	"github.com/tuneinsight/lattigo/v4/rlwe"
 	"github.com/tuneinsight/lattigo/v4/ckks"
 
 
 )
 
+// Get evaluator with every power of 2 rotation key. When keys are loaded lazily every available power of 2 key is loaded
 func (u Utils) Get2PowRotationEvaluator() ckks.Evaluator {
 
+	if u.KeyChain.RotationKeyLoader != nil {
+
+		ks := []int{}
+		for _, k := range key.GetPow2K(u.Params.LogSlots()) {
+			if u.HasRotationKey(k) {
+				ks = append(ks, k)
+			}
+		}
+
+		return u.getRotationEvaluator(ks...)
+
+	}
+
 	return u.Evaluator.WithKey(rlwe.EvaluationKey{Rlk: u.KeyChain.RelinKey, Rtks: u.KeyChain.GaloisKey})
 
 }
 
+// Get evaluator able to rotate by each of ks. Only those keys are loaded when keys are loaded lazily
+func (u Utils) getRotationEvaluator(ks ...int) ckks.Evaluator {
+
+	if u.KeyChain.RotationKeyLoader == nil {
+		return u.Evaluator.WithKey(rlwe.EvaluationKey{Rlk: u.KeyChain.RelinKey, Rtks: u.KeyChain.GaloisKey})
+	}
+
+	galEls := make([]uint64, len(ks))
+	for i, k := range ks {
+		galEls[i] = u.Params.GaloisElementForColumnRotationBy(k)
+	}
+
+	return u.Evaluator.WithKey(rlwe.EvaluationKey{Rlk: u.KeyChain.RelinKey, Rtks: u.KeyChain.RotationKeyLoader.RotationKeySet(galEls)})
+
+}
+
 func (u Utils) Float64ToComplex128(value []float64) []complex128 {
 
 	cmplx := make([]complex128, len(value))
@@ -1,6 +1,8 @@
 package utility
 
 import (
+	"fmt"
+
 	"github.com/tuneinsight/lattigo/v4/rlwe"
 	"github.com/tuneinsight/lattigo/v4/ckks/bootstrapping"
 )
@@ -51,6 +53,31 @@ func (u Utils) Bootstrap1dInPlace(ct []*rlwe.Ciphertext, concurrent bool) {
 
 }
 
+// Bootstrap ciphertexts only if their level is lower than minLevel. Returns whether bootstrapping was performed
+func (u Utils) Bootstrap1dIfBelow(ct []*rlwe.Ciphertext, minLevel int) bool {
+
+	toBootstrap := []*rlwe.Ciphertext{}
+
+	for i := range ct {
+		if ct[i].Level() < minLevel {
+			toBootstrap = append(toBootstrap, ct[i])
+		}
+	}
+
+	if len(toBootstrap) == 0 {
+		return false
+	}
+
+	if u.Bootstrapper == nil {
+		panic(fmt.Sprintf("Ciphertext level is lower than %d but bootstrapping is not enabled", minLevel))
+	}
+
+	u.Bootstrap1dInPlace(toBootstrap, true)
+
+	return true
+
+}
+
 func bootstrapGoRoutine (ciphertext *rlwe.Ciphertext, btp bootstrapping.Bootstrapper, c chan rlwe.Ciphertext){
 
 	c <- *btp.Bootstrap(ciphertext)
@@ -0,0 +1,291 @@
+package utility
+
+import (
+	"sync"
+
+	"github.com/tuneinsight/lattigo/v4/rlwe"
+)
+
+//=================================================
+//					COMPARISON
+//=================================================
+
+// Composite polynomials from Cheon et al. "Efficient Homomorphic Comparison Methods with Optimal Complexity".
+// g3 quickly pushes values away from 0 and f3 pushes values of (0, 1] towards 1 and [-1, 0) towards -1
+var signG3 = []float64{0, 4589.0 / 1024, 0, -16577.0 / 1024, 0, 25614.0 / 1024, 0, -12860.0 / 1024}
+var signF3 = []float64{0, 35.0 / 16, 0, -35.0 / 16, 0, 21.0 / 16, 0, -5.0 / 16}
+
+// Levels consumed by each iteration of degree 7 composite polynomial
+const signIterationLevel = 3
+
+// Precision and depth of sign approximation. Inputs of Sign must lie in [-Bound, Bound]. With 2 f iterations,
+// inputs further than about 0.4 * Bound / 4.5^GIteration from 0 are mapped within 1e-2 of ±1
+type ComparisonParams struct {
+	Bound      float64
+	GIteration int // Iterations of g3, each one shrinks the interval around 0 that can't be distinguished
+	FIteration int // Iterations of f3, at least 1 is required
+}
+
+// Distinguish values further than 2% of bound from 0 with 12 levels
+func DefaultComparisonParams(bound float64) ComparisonParams {
+	return ComparisonParams{Bound: bound, GIteration: 2, FIteration: 2}
+}
+
+// Levels consumed by Sign, Step and Compare. Max and Min consume 1 more
+func (p ComparisonParams) GetLevelConsumption() int {
+	return constantLevel(1/p.Bound) + signIterationLevel*(p.GIteration+p.FIteration)
+}
+
+// Approximate sign of x: close to 1 where x > 0, -1 where x < 0 and 0 where x = 0.
+// Ciphertext is bootstrapped between iterations when its level is too low
+func (u Utils) Sign(x *rlwe.Ciphertext, params ComparisonParams) *rlwe.Ciphertext {
+	return u.compositeSign(x, params, NewPolynomial(signF3, u))
+}
+
+// Approximate x > 0: close to 1 where x > 0, 0 where x < 0 and 0.5 where x = 0. x must lie in [-Bound, Bound]
+func (u Utils) Step(x *rlwe.Ciphertext, params ComparisonParams) *rlwe.Ciphertext {
+
+	// Last iteration evaluates (f3(x) + 1) / 2 so mapping sign to step doesn't consume a level
+	step := make([]float64, len(signF3))
+	step[0] = 0.5
+	for i := 1; i < len(signF3); i++ {
+		step[i] = signF3[i] / 2
+	}
+
+	return u.compositeSign(x, params, NewPolynomial(step, u))
+
+}
+
+// Approximate a > b: close to 1 where a > b, 0 where a < b and 0.5 where a = b. a - b must lie in [-Bound, Bound]
+func (u Utils) Compare(a *rlwe.Ciphertext, b *rlwe.Ciphertext, params ComparisonParams) *rlwe.Ciphertext {
+	return u.Step(u.SubNew(a, b), params)
+}
+
+// Evaluate g3 GIteration times, f3 FIteration - 1 times and last once
+func (u Utils) compositeSign(x *rlwe.Ciphertext, params ComparisonParams, last Polynomial) *rlwe.Ciphertext {
+
+	if params.FIteration < 1 {
+		panic("Sign approximation requires at least one f iteration")
+	}
+
+	result := x.CopyNew()
+
+	if params.Bound != 1 {
+		u.Bootstrap1dIfBelow([]*rlwe.Ciphertext{result}, constantLevel(1/params.Bound))
+		u.MultiplyConst(result, 1/params.Bound, result, true, false)
+	}
+
+	polys := []Polynomial{}
+	for i := 0; i < params.GIteration; i++ {
+		polys = append(polys, NewPolynomial(signG3, u))
+	}
+	for i := 1; i < params.FIteration; i++ {
+		polys = append(polys, NewPolynomial(signF3, u))
+	}
+	polys = append(polys, last)
+
+	for _, poly := range polys {
+		u.Bootstrap1dIfBelow([]*rlwe.Ciphertext{result}, signIterationLevel)
+		result = poly.Evaluate(result, u.Params.Slots())
+	}
+
+	return result
+
+}
+
+// Compute c * a + (1 - c) * b which consumes 1 level. Both products have the same scale when a and b do, so they are
+// added without error even after scale of c drifted during sign approximation. Ciphertexts are bootstrapped in place if their level is too low
+func (u Utils) SelectNew(c *rlwe.Ciphertext, a *rlwe.Ciphertext, b *rlwe.Ciphertext) *rlwe.Ciphertext {
+
+	u.Bootstrap1dIfBelow([]*rlwe.Ciphertext{c, a, b}, 1)
+
+	notC := u.Evaluator.NegNew(c)
+	u.Evaluator.AddConst(notC, 1, notC)
+
+	result := u.MultiplyNew(c, a, true, false)
+	u.Add(result, u.MultiplyNew(notC, b, true, false), result)
+
+	return result
+
+}
+
+// Approximate element wise maximum of a and b. a - b must lie in [-Bound, Bound] and a and b should have the same scale
+func (u Utils) Max(a *rlwe.Ciphertext, b *rlwe.Ciphertext, params ComparisonParams) *rlwe.Ciphertext {
+	return u.SelectNew(u.Compare(a, b, params), a, b)
+}
+
+// Approximate element wise minimum of a and b. Same requirements as Max
+func (u Utils) Min(a *rlwe.Ciphertext, b *rlwe.Ciphertext, params ComparisonParams) *rlwe.Ciphertext {
+	return u.SelectNew(u.Compare(a, b, params), b, a)
+}
+
+//=================================================
+//				COMPARISON OVER SLOTS
+//=================================================
+
+// Maximum of the first size slots of x, stored in slot 0. Values must lie in [-Bound/2, Bound/2] and slots after size must be 0.
+// Performs ceil(log2(size)) comparisons, each consuming params.GetLevelConsumption() + 1 levels
+func (u Utils) MaxSlots(x *rlwe.Ciphertext, size int, params ComparisonParams) *rlwe.Ciphertext {
+	value, _ := u.slotTournament(x, size, params, true, false)
+	return value
+}
+
+// Minimum of the first size slots of x, stored in slot 0. Same requirements as MaxSlots
+func (u Utils) MinSlots(x *rlwe.Ciphertext, size int, params ComparisonParams) *rlwe.Ciphertext {
+	value, _ := u.slotTournament(x, size, params, false, false)
+	return value
+}
+
+// Index of the maximum of the first size slots of x, stored in slot 0. Same requirements as MaxSlots.
+// Index of tied values is a weighted average of their indices
+func (u Utils) ArgmaxSlots(x *rlwe.Ciphertext, size int, params ComparisonParams) *rlwe.Ciphertext {
+	_, index := u.slotTournament(x, size, params, true, true)
+	return index
+}
+
+// Compare slot i with slot i + step and keep the winner in slot i, halving step until the winner of every slot is in slot 0
+func (u Utils) slotTournament(x *rlwe.Ciphertext, size int, params ComparisonParams, max bool, withIndex bool) (value *rlwe.Ciphertext, index *rlwe.Ciphertext) {
+
+	length := 1
+	for length < size {
+		length *= 2
+	}
+
+	// Pad slots up to the next power of two with values that never win
+	value = x.CopyNew()
+	if length > size {
+
+		padValue := -params.Bound / 2
+		if !max {
+			padValue = params.Bound / 2
+		}
+
+		pad := make([]float64, u.Params.Slots())
+		for i := size; i < length; i++ {
+			pad[i] = padValue
+		}
+
+		u.AddPlain(value, u.EncodePlaintextFromArrayScale(pad, value.Scale.Float64()), value)
+
+	}
+
+	if withIndex {
+
+		indices := make([]float64, u.Params.Slots())
+		for i := range indices {
+			indices[i] = float64(i)
+		}
+
+		index = u.MultiplyConstNew(value, 0, false, false)
+		u.AddPlain(index, u.EncodePlaintextFromArrayScale(indices, index.Scale.Float64()), index)
+
+	}
+
+	for step := length / 2; step >= 1; step /= 2 {
+
+		rotated := u.RotateNew(value, step)
+		c := u.Compare(value, &rotated, params)
+
+		if withIndex {
+			rotatedIndex := u.RotateNew(index, step)
+			index = u.selectWinner(c, index, &rotatedIndex, max)
+		}
+
+		value = u.selectWinner(c, value, &rotated, max)
+
+	}
+
+	return value, index
+
+}
+
+// Select a where c is 1 if max is true, otherwise select a where c is 0
+func (u Utils) selectWinner(c *rlwe.Ciphertext, a *rlwe.Ciphertext, b *rlwe.Ciphertext, max bool) *rlwe.Ciphertext {
+
+	if max {
+		return u.SelectNew(c, a, b)
+	}
+
+	return u.SelectNew(c, b, a)
+
+}
+
+//=================================================
+//			COMPARISON ACROSS CIPHERTEXTS
+//=================================================
+
+// Element wise maximum of cts. Values must lie in [-Bound/2, Bound/2].
+// Performs ceil(log2(len(cts))) rounds of comparisons, each consuming params.GetLevelConsumption() + 1 levels
+func (u Utils) MaxAcross(cts []*rlwe.Ciphertext, params ComparisonParams) *rlwe.Ciphertext {
+	value, _ := u.acrossTournament(cts, params, true, false)
+	return value
+}
+
+// Element wise minimum of cts. Same requirements as MaxAcross
+func (u Utils) MinAcross(cts []*rlwe.Ciphertext, params ComparisonParams) *rlwe.Ciphertext {
+	value, _ := u.acrossTournament(cts, params, false, false)
+	return value
+}
+
+// Index of the ciphertext with the maximum value in each slot. Same requirements as MaxAcross
+func (u Utils) ArgmaxAcross(cts []*rlwe.Ciphertext, params ComparisonParams) *rlwe.Ciphertext {
+	_, index := u.acrossTournament(cts, params, true, true)
+	return index
+}
+
+// Compare ciphertexts in pairs concurrently and keep the winners until one ciphertext is left
+func (u Utils) acrossTournament(cts []*rlwe.Ciphertext, params ComparisonParams, max bool, withIndex bool) (*rlwe.Ciphertext, *rlwe.Ciphertext) {
+
+	values := make([]*rlwe.Ciphertext, len(cts))
+	indices := make([]*rlwe.Ciphertext, len(cts))
+
+	for i := range cts {
+		values[i] = cts[i].CopyNew()
+		if withIndex {
+			indices[i] = u.MultiplyConstNew(cts[i], 0, false, false)
+			u.Evaluator.AddConst(indices[i], float64(i), indices[i])
+		}
+	}
+
+	for len(values) > 1 {
+
+		winners := make([]*rlwe.Ciphertext, (len(values)+1)/2)
+		winnerIndices := make([]*rlwe.Ciphertext, len(winners))
+
+		// Odd ciphertext out advances to the next round
+		if len(values)%2 == 1 {
+			winners[len(winners)-1] = values[len(values)-1]
+			winnerIndices[len(winners)-1] = indices[len(values)-1]
+		}
+
+		var wg sync.WaitGroup
+
+		for i := 0; i+1 < len(values); i += 2 {
+
+			wg.Add(1)
+
+			go func(i int, utils Utils) {
+
+				defer wg.Done()
+
+				c := utils.Compare(values[i], values[i+1], params)
+
+				if withIndex {
+					winnerIndices[i/2] = utils.selectWinner(c, indices[i], indices[i+1], max)
+				}
+
+				winners[i/2] = utils.selectWinner(c, values[i], values[i+1], max)
+
+			}(i, u.CopyWithClonedEval())
+
+		}
+
+		wg.Wait()
+
+		values, indices = winners, winnerIndices
+
+	}
+
+	return values[0], indices[0]
+
+}
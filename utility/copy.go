@@ -112,13 +112,19 @@ func (u Utils) CopyWithClonedEncoder() Utils {
 
 func (u Utils) ShallowCopy() Utils {
 
+	// Utils without bootstrapping keys doesn't have a bootstrapper to copy
+	bootstrapper := u.Bootstrapper
+	if bootstrapper != nil {
+		bootstrapper = bootstrapper.ShallowCopy()
+	}
+
 	return Utils{
 		hasSecretKey:        u.hasSecretKey,
 		bootstrapEnabled:    u.bootstrapEnabled,
 		BootstrappingParams: u.BootstrappingParams,
 		Params:              u.Params,
 		KeyChain:            u.KeyChain,
-		Bootstrapper:        u.Bootstrapper.ShallowCopy(),
+		Bootstrapper:        bootstrapper,
 		Encoder:             u.Encoder,
 		Evaluator:           u.Evaluator.ShallowCopy(),
 		Encryptor:           u.Encryptor,
@@ -9,6 +9,11 @@ func (u Utils) rotateAndAdd(ct *rlwe.Ciphertext, size float64, evaluator *ckks.E
 
 	midpoint := size / 2
 
+	if u.KeyChain.RotationKeyLoader != nil {
+		rotationEvaluator := u.getRotationEvaluator(int(midpoint))
+		evaluator = &rotationEvaluator
+	}
+
 	rotated := (*evaluator).RotateNew(ct, int(midpoint))
 	u.Add(ct, rotated, ct)
 
@@ -22,14 +27,14 @@ func (u Utils) rotateAndAdd(ct *rlwe.Ciphertext, size float64, evaluator *ckks.E
 
 func (u Utils) SumElementsInPlace(ct *rlwe.Ciphertext) {
 
-	rotationEvaluator := u.Get2PowRotationEvaluator()
+	rotationEvaluator := u.getRotationEvaluator()
 	u.rotateAndAdd(ct, float64(u.Params.Slots()), &rotationEvaluator)
 
 }
 
 func (u Utils) SumElementsNew(ct rlwe.Ciphertext) *rlwe.Ciphertext {
 
-	rotationEvaluator := u.Get2PowRotationEvaluator()
+	rotationEvaluator := u.getRotationEvaluator()
 	newCt := ct.CopyNew()
 	return u.rotateAndAdd(newCt, float64(u.Params.Slots()), &rotationEvaluator)
 
@@ -70,6 +70,60 @@ func (u Utils) InterDotProduct(a []*rlwe.Ciphertext, b []*rlwe.Ciphertext, resca
 
 }
 
+// Calculate dot product of ciphertexts a with plaintext scalars b. See InterPlainDotProduct
+func (u Utils) PlainDotProduct(a []*rlwe.Ciphertext, b []float64) *rlwe.Ciphertext {
+
+	if len(a) != len(b) {
+		panic("Unequal length")
+	}
+
+	encoded := make([]*rlwe.Plaintext, len(b))
+
+	for i := range b {
+		encoded[i] = u.Encoder.EncodeNew(u.Float64ToComplex128(u.GenerateFilledArray(b[i])), a[i].Level(), u.Params.DefaultScale(), u.Params.LogSlots())
+	}
+
+	return u.InterPlainDotProduct(a, encoded, nil)
+
+}
+
+// Calculate dot product of ciphertexts a with plaintexts b. Products don't need relinearization and are summed
+// before a single rescale, so the result consumes one level and costs one rescale instead of one per element
+func (u Utils) InterPlainDotProduct(a []*rlwe.Ciphertext, b []*rlwe.Plaintext, counter *logger.OperationsCounter) *rlwe.Ciphertext {
+
+	if len(a) != len(b) {
+		panic("Unequal length")
+	}
+
+	var sum *rlwe.Ciphertext
+
+	for i := range a {
+
+		u.ReEncodeAsNTT(b[i])
+		product := u.Evaluator.MulNew(a[i], b[i])
+
+		if sum == nil {
+			sum = product
+		} else {
+			u.Evaluator.Add(sum, product, sum)
+		}
+
+		if counter != nil {
+			counter.Increment()
+		}
+
+	}
+
+	if sum == nil {
+		return nil
+	}
+
+	u.Evaluator.Rescale(sum, rlwe.NewScale(u.Scale), sum)
+
+	return sum
+
+}
+
 func (u Utils) InterOuter(a []*rlwe.Ciphertext, b []*rlwe.Ciphertext, concurrent bool) [][]*rlwe.Ciphertext {
 
 	output := make([][]*rlwe.Ciphertext, len(a))
@@ -65,7 +65,6 @@ func (u Utils) Transpose(ciphertexts []rlwe.Ciphertext, column int) []rlwe.Ciphe
 func (u Utils) Outer(a *rlwe.Ciphertext, b *rlwe.Ciphertext, aSize int, bSize int, filterBy float64) []*rlwe.Ciphertext {
 
 	// Need to cover rotation in range [0, aSize)
-	pow2rotationEvaluator := u.Get2PowRotationEvaluator()
 
 	outerProduct := make([]*rlwe.Ciphertext, aSize)
 
@@ -101,6 +100,7 @@ func (u Utils) Outer(a *rlwe.Ciphertext, b *rlwe.Ciphertext, aSize int, bSize in
 
 			for j := 1; j < bSize; j *= 2 {
 				// Rotate and add to double the amount of data each iteration
+				pow2rotationEvaluator := u.getRotationEvaluator(-j)
 				rotated := pow2rotationEvaluator.RotateNew(filtered, -j)
 				u.Add(filtered, rotated, filtered)
 			}
@@ -1,9 +1,25 @@
 package utility
 
 import (
+	"math"
+	"math/bits"
+	"sync"
+
+	"github.com/tuneinsight/lattigo/v4/ckks"
 	"github.com/tuneinsight/lattigo/v4/rlwe"
 )
 
+// Basis of polynomial coefficients
+type Basis int
+
+const (
+	PowerBasis     Basis = iota // c0 + c1x + c2x^2 + ...
+	ChebyshevBasis              // c0 + c1T1(y) + c2T2(y) + ... where y maps [Lower, Upper] to [-1, 1]
+)
+
+// Coefficients smaller than this are treated as zero and skipped during evaluation
+const negligibleCoefficient = 1e-14
+
 type Coefficient struct {
 	Degree  int
 	Value   float64
@@ -11,140 +27,490 @@ type Coefficient struct {
 }
 
 type Polynomial struct {
-	u     Utils
-	Coeff []Coefficient
+	u          Utils
+	Coeff      []Coefficient
+	Basis      Basis
+	Lower      float64 // Interval of Chebyshev basis
+	Upper      float64
+	encodeLock *sync.Mutex
 }
 
-// Create new polynomial struct
+// Create new polynomial struct in power basis
 // index of coeff is the degree that that coeff serve (e.g. coeff[0] is for degree 0)
 func NewPolynomial(coeff []float64, utils Utils) Polynomial {
 
 	coeffs := make([]Coefficient, len(coeff))
 
 	for i := range coeff {
+		coeffs[i] = Coefficient{Degree: i, Value: coeff[i], Encoded: make(map[int]*rlwe.Plaintext)}
+	}
+
+	return Polynomial{u: utils, Coeff: coeffs, Basis: PowerBasis, encodeLock: &sync.Mutex{}}
+
+}
+
+// Create new polynomial struct in Chebyshev basis over [lower, upper]
+// index of coeff is the degree of Chebyshev polynomial that that coeff serve
+func NewChebyshevPolynomial(coeff []float64, lower float64, upper float64, utils Utils) Polynomial {
+
+	poly := NewPolynomial(coeff, utils)
+	poly.Basis = ChebyshevBasis
+	poly.Lower = lower
+	poly.Upper = upper
+
+	return poly
+
+}
+
+// Fit function with Chebyshev interpolant of degree over [lower, upper] in plaintext
+func FitChebyshev(function func(float64) float64, lower float64, upper float64, degree int, utils Utils) Polynomial {
+
+	approximated := ckks.Approximate(function, lower, upper, degree)
+
+	return NewChebyshevPolynomial(utils.Complex128ToFloat64(approximated.Coeffs), lower, upper, utils)
+
+}
+
+// Values of coefficients with negligible ones set to zero
+func (poly Polynomial) values() []float64 {
+
+	values := make([]float64, poly.Degree()+1)
+
+	for i := range values {
+		if math.Abs(poly.Coeff[i].Value) > negligibleCoefficient {
+			values[i] = poly.Coeff[i].Value
+		}
+	}
+
+	return values
+
+}
+
+// Highest degree with non negligible coefficient
+func (poly Polynomial) Degree() int {
+
+	for i := len(poly.Coeff) - 1; i > 0; i-- {
+		if math.Abs(poly.Coeff[i].Value) > negligibleCoefficient {
+			return i
+		}
+	}
+
+	return 0
+
+}
+
+// Derivative of polynomial in the same basis
+func (poly Polynomial) Derivative() Polynomial {
+
+	values := poly.values()
+
+	if len(values) == 1 {
+		return Polynomial{u: poly.u, Coeff: []Coefficient{{Encoded: make(map[int]*rlwe.Plaintext)}}, Basis: poly.Basis, Lower: poly.Lower, Upper: poly.Upper, encodeLock: &sync.Mutex{}}
+	}
+
+	n := len(values) - 1
+	derivative := make([]float64, n+2)
 
-		encoded := make(map[int]*rlwe.Plaintext)
+	if poly.Basis == PowerBasis {
 
-		if i != 0 {
-			pt := utils.EncodePlaintextFromArray(utils.GenerateFilledArray(coeff[i]))
-			encoded[utils.Params.Slots()] = pt
+		for i := 1; i <= n; i++ {
+			derivative[i-1] = float64(i) * values[i]
 		}
 
-		coeffs[i] = Coefficient{Degree: i, Value: coeff[i], Encoded: encoded}
+		return NewPolynomial(derivative[:n], poly.u)
 
 	}
 
-	return Polynomial{u: utils, Coeff: coeffs}
+	// c'(k-1) = c'(k+1) + 2k * c(k), scaled by 2 / (Upper - Lower) from mapping of [Lower, Upper] to [-1, 1]
+	for k := n; k > 0; k-- {
+		derivative[k-1] = derivative[k+1] + 2*float64(k)*values[k]
+	}
+	derivative[0] /= 2
+
+	for k := range derivative {
+		derivative[k] *= 2 / (poly.Upper - poly.Lower)
+	}
+
+	return NewChebyshevPolynomial(derivative[:n], poly.Lower, poly.Upper, poly.u)
 
 }
 
-func (poly Polynomial) EvaluateDegree7(x *rlwe.Ciphertext, size int) *rlwe.Ciphertext {
+// Evaluate polynomial on plaintext value
+func (poly Polynomial) EvaluatePlain(x float64) float64 {
+
+	values := poly.values()
+	result := 0.0
+
+	if poly.Basis == PowerBasis {
+		for i := len(values) - 1; i >= 0; i-- {
+			result = result*x + values[i]
+		}
+		return result
+	}
+
+	// Clenshaw recurrence
+	y := (2*x - poly.Upper - poly.Lower) / (poly.Upper - poly.Lower)
+	next, nextNext := 0.0, 0.0
+	for i := len(values) - 1; i > 0; i-- {
+		next, nextNext = 2*y*next-nextNext+values[i], next
+	}
+
+	return y*next - nextNext + values[0]
+
+}
+
+//=================================================
+//					SCHEDULING
+//=================================================
+
+// Level consumed by basis element i, which is computed from basis elements 2^(ceil(log2(i)) - 1) and the rest
+func basisLevel(i int) int {
+	return bits.Len(uint(i - 1))
+}
+
+// Multiplying by integer constant doesn't require rescaling, 1 doesn't require multiplication
+func constantLevel(c float64) int {
+	if c == math.Trunc(c) && math.Abs(c) < math.MaxInt64 {
+		return 0
+	}
+	return 1
+}
+
+// Highest non zero index of values, -1 if every value is zero
+func highestDegree(values []float64) int {
+	for i := len(values) - 1; i >= 0; i-- {
+		if values[i] != 0 {
+			return i
+		}
+	}
+	return -1
+}
+
+// Polynomial of degree at least babyStep is split into q * X^giant + r where giant is the largest power of two
+// not greater than its degree. q and r are split recursively until their degree is smaller than babyStep and
+// are evaluated as linear combination of basis elements. In Chebyshev basis X^giant is T(giant) and
+// T(giant + j) = 2T(giant)T(j) - T(giant - j) is used instead
+func split(values []float64, babyStep int, basis Basis) (q []float64, r []float64, giant int, ok bool) {
+
+	degree := highestDegree(values)
+	if degree < babyStep {
+		return nil, nil, 0, false
+	}
+
+	giant = 1 << (bits.Len(uint(degree)) - 1)
+
+	if basis == PowerBasis {
+		return values[giant : degree+1], values[:giant], giant, true
+	}
+
+	q = make([]float64, degree-giant+1)
+	r = make([]float64, giant)
+	copy(r, values[:giant])
+
+	q[0] = values[giant]
+	for j := 1; j < len(q); j++ {
+		q[j] = 2 * values[giant+j]
+		r[giant-j] -= values[giant+j]
+	}
+
+	return q, r, giant, true
+
+}
+
+// Levels and ciphertext multiplications needed to evaluate values from the basis. Constant is true when values
+// only have degree 0 so nothing is evaluated
+func simulate(values []float64, babyStep int, basis Basis) (levels int, multiplications int, constant bool) {
+
+	q, r, giant, ok := split(values, babyStep, basis)
+
+	if !ok {
+		constant = true
+		for i := 1; i < len(values); i++ {
+			if values[i] != 0 {
+				constant = false
+				levels = int(math.Max(float64(levels), float64(basisLevel(i)+constantLevel(values[i]))))
+			}
+		}
+		return levels, 0, constant
+	}
+
+	qLevels, qMultiplications, qConstant := simulate(q, babyStep, basis)
+	rLevels, rMultiplications, _ := simulate(r, babyStep, basis)
+
+	if qConstant {
+		levels = basisLevel(giant) + constantLevel(q[0])
+	} else {
+		levels = int(math.Max(float64(qLevels), float64(basisLevel(giant)))) + 1
+		multiplications++
+	}
+
+	return int(math.Max(float64(levels), float64(rLevels))), multiplications + qMultiplications + rMultiplications, false
+
+}
+
+// Basis elements needed to evaluate polynomial of degree with babyStep: every element below babyStep and powers of two above it
+func basisIndices(degree int, babyStep int) []int {
 
-	channels := make([]chan *rlwe.Ciphertext, 8)
-	slots := poly.u.Params.Slots()
+	indices := []int{}
 
-	x2 := poly.u.MultiplyNew(x.CopyNew(), x.CopyNew(), true, false)
-	x4 := poly.u.MultiplyNew(x2.CopyNew(), x2.CopyNew(), true, false)
+	for i := 1; i <= degree; i++ {
+		if i < babyStep || i&(i-1) == 0 {
+			indices = append(indices, i)
+		}
+	}
+
+	return indices
+
+}
+
+// Choose baby step which evaluates the polynomial with the least levels, and the least ciphertext multiplications
+// among them
+func (poly Polynomial) schedule() (babyStep int, levels int) {
 
-	// Calc degree 7
-	if poly.Coeff[7].Value != 0 {
-		channels[7] = make(chan *rlwe.Ciphertext)
-		go func(u Utils, c chan *rlwe.Ciphertext) {
+	values := poly.values()
+	degree := len(values) - 1
+
+	bestMultiplications := 0
+
+	for logBabyStep := 1; logBabyStep <= bits.Len(uint(degree)); logBabyStep++ {
+
+		step := 1 << logBabyStep
+		stepLevels, multiplications, _ := simulate(values, step, poly.Basis)
+
+		for _, i := range basisIndices(degree, step) {
+			if i > 1 {
+				multiplications++
+			}
+		}
 
-			c7x1 := u.MultiplyPlainNew(x.CopyNew(), poly.Coeff[7].Encoded[slots], true, false)
-			c7x3 := u.MultiplyNew(c7x1, x2.CopyNew(), true, false)
-			c7x7 := u.MultiplyNew(c7x3, x4.CopyNew(), true, false)
-			c <- c7x7
+		if babyStep == 0 || stepLevels < levels || (stepLevels == levels && multiplications < bestMultiplications) {
+			babyStep, levels, bestMultiplications = step, stepLevels, multiplications
+		}
 
-		}(poly.u.CopyWithClonedEval(), channels[7])
 	}
 
-	// Calc degree 6
-	if poly.Coeff[6].Value != 0 {
-		channels[6] = make(chan *rlwe.Ciphertext)
-		go func(u Utils, c chan *rlwe.Ciphertext) {
+	return babyStep, levels
+
+}
 
-			c6x2 := u.MultiplyPlainNew(x2.CopyNew(), poly.Coeff[6].Encoded[slots], true, false)
-			c6x6 := u.MultiplyNew(c6x2, x4.CopyNew(), true, false)
-			c <- c6x6
+// Levels consumed by mapping [Lower, Upper] to [-1, 1] before evaluation in Chebyshev basis
+func (poly Polynomial) mappingLevel() int {
 
-		}(poly.u.CopyWithClonedEval(), channels[6])
+	if poly.Basis != ChebyshevBasis || poly.Degree() == 0 {
+		return 0
 	}
 
-	// Calc degree 5
-	if poly.Coeff[5].Value != 0 {
-		channels[5] = make(chan *rlwe.Ciphertext)
-		go func(u Utils, c chan *rlwe.Ciphertext) {
+	return constantLevel(2 / (poly.Upper - poly.Lower))
+
+}
 
-			c5x1 := u.MultiplyPlainNew(x.CopyNew(), poly.Coeff[5].Encoded[slots], true, false)
-			c5x5 := u.MultiplyNew(c5x1, x4.CopyNew(), true, false)
-			c <- c5x5
+// Levels consumed by Evaluate
+func (poly Polynomial) GetLevelConsumption() int {
 
-		}(poly.u.CopyWithClonedEval(), channels[5])
+	if poly.Degree() == 0 {
+		return 0
 	}
 
-	// Calc degree 4
-	if poly.Coeff[4].Value != 0 {
-		channels[4] = make(chan *rlwe.Ciphertext)
-		go func(u Utils, c chan *rlwe.Ciphertext) {
+	_, levels := poly.schedule()
+
+	return poly.mappingLevel() + levels
+
+}
+
+//=================================================
+//					EVALUATION
+//=================================================
+
+// Evaluate polynomial on ciphertext with baby-step giant-step. Basis elements and independent parts of the polynomial
+// are evaluated concurrently. The constant term is only added to the first size slots. Safe to call concurrently
+func (poly Polynomial) Evaluate(x *rlwe.Ciphertext, size int) *rlwe.Ciphertext {
 
-			c4x2 := u.MultiplyPlainNew(x2.CopyNew(), poly.Coeff[4].Encoded[slots], true, false)
-			c4x4 := u.MultiplyNew(c4x2, x2.CopyNew(), true, false)
-			c <- c4x4
+	u := poly.u.CopyWithClonedEval()
+	values := poly.values()
+	degree := len(values) - 1
 
-		}(poly.u.CopyWithClonedEval(), channels[4])
+	var result *rlwe.Ciphertext
+
+	if degree == 0 {
+
+		result = u.MultiplyConstNew(x, 0, false, false)
+
+	} else {
+
+		input := x.CopyNew()
+
+		if poly.Basis == ChebyshevBasis {
+			if scale := 2 / (poly.Upper - poly.Lower); scale != 1 {
+				u.MultiplyConst(input, scale, input, true, false)
+			}
+			u.Evaluator.AddConst(input, -(poly.Upper+poly.Lower)/(poly.Upper-poly.Lower), input)
+		}
+
+		babyStep, _ := poly.schedule()
+		basis := poly.computeBasis(input, basisIndices(degree, babyStep))
+		result = poly.evaluateFromBasis(values, basis, babyStep, u)
+
+	}
+
+	// Add constant term
+	if values[0] != 0 {
+		if size >= u.Params.Slots() {
+			u.Evaluator.AddConst(result, values[0], result)
+		} else {
+			u.AddPlain(result, poly.encodedConstant(size), result)
+		}
 	}
 
-	// Calc degree 3
-	if poly.Coeff[3].Value != 0 {
-		channels[3] = make(chan *rlwe.Ciphertext)
-		go func(u Utils, c chan *rlwe.Ciphertext) {
+	return result
+
+}
+
+// Constant term encoded in the first size slots, cached in Coeff[0].Encoded
+func (poly Polynomial) encodedConstant(size int) *rlwe.Plaintext {
 
-			c3x1 := u.MultiplyPlainNew(x.CopyNew(), poly.Coeff[3].Encoded[slots], true, false)
-			c3x3 := u.MultiplyNew(c3x1, x2.CopyNew(), true, false)
-			c <- c3x3
+	poly.encodeLock.Lock()
+	defer poly.encodeLock.Unlock()
 
-		}(poly.u.CopyWithClonedEval(), channels[3])
+	if _, ok := poly.Coeff[0].Encoded[size]; !ok {
+		poly.Coeff[0].Encoded[size] = poly.u.EncodePlaintextFromArray(poly.u.GenerateFilledArraySize(poly.Coeff[0].Value, size))
 	}
 
-	// Calc degree 2
-	if poly.Coeff[2].Value != 0 {
-		channels[2] = make(chan *rlwe.Ciphertext)
-		go func(u Utils, c chan *rlwe.Ciphertext) {
+	return poly.Coeff[0].Encoded[size]
+
+}
+
+// Compute basis elements of indices. Elements in (2^(t-1), 2^t] only depend on lower elements so they are computed concurrently
+func (poly Polynomial) computeBasis(x *rlwe.Ciphertext, indices []int) []*rlwe.Ciphertext {
+
+	basis := make([]*rlwe.Ciphertext, indices[len(indices)-1]+1)
+	basis[1] = x
+
+	// indices[0] is x itself
+	for start := 1; start < len(indices); {
+
+		tier := basisLevel(indices[start])
+		end := start
+		for end < len(indices) && basisLevel(indices[end]) == tier {
+			end++
+		}
+
+		var wg sync.WaitGroup
+
+		for _, index := range indices[start:end] {
+
+			wg.Add(1)
+
+			go func(i int, u Utils) {
+
+				defer wg.Done()
+
+				a := 1 << (tier - 1)
+				b := i - a
+				product := u.MultiplyNew(basis[a], basis[b], true, false)
+
+				// T(a + b) = 2T(a)T(b) - T(a - b)
+				if poly.Basis == ChebyshevBasis {
+					u.Add(product, product, product)
+					if a == b {
+						u.Evaluator.AddConst(product, -1, product)
+					} else {
+						u.Evaluator.Sub(product, basis[a-b], product)
+					}
+				}
+
+				basis[i] = product
+
+			}(index, poly.u.CopyWithClonedEval())
+
+		}
 
-			c2x2 := u.MultiplyPlainNew(x2.CopyNew(), poly.Coeff[2].Encoded[slots], true, false)
-			c <- c2x2
+		wg.Wait()
+		start = end
 
-		}(poly.u.CopyWithClonedEval(), channels[2])
 	}
 
-	// Calc degree 1
-	if poly.Coeff[1].Value != 0 {
-		channels[1] = make(chan *rlwe.Ciphertext)
-		go func(u Utils, c chan *rlwe.Ciphertext) {
+	return basis
+
+}
+
+// Evaluate values without constant term from basis. q of each split is evaluated concurrently with r
+func (poly Polynomial) evaluateFromBasis(values []float64, basis []*rlwe.Ciphertext, babyStep int, u Utils) *rlwe.Ciphertext {
+
+	q, r, giant, ok := split(values, babyStep, poly.Basis)
+
+	if !ok {
+
+		var result *rlwe.Ciphertext
+
+		for i := 1; i < len(values); i++ {
+
+			if values[i] == 0 {
+				continue
+			}
+
+			term := multiplyConstant(u, basis[i], values[i])
+
+			if result == nil {
+				result = term
+			} else {
+				u.Add(result, term, result)
+			}
+
+		}
 
-			c1x1 := u.MultiplyPlainNew(x.CopyNew(), poly.Coeff[1].Encoded[slots], true, false)
-			c <- c1x1
+		return result
 
-		}(poly.u.CopyWithClonedEval(), channels[1])
 	}
 
-	sum := poly.u.EncryptToPointer(poly.u.GenerateFilledArray(0))
-	for c := range poly.Coeff {
-		if poly.Coeff[c].Value != 0 && c != 0 {
-			result := <-channels[c]
-			poly.u.Add(result, sum, sum)
-		} else if c == 0 {
-			if _, ok := poly.Coeff[c].Encoded[size]; !ok {
+	var result *rlwe.Ciphertext
 
-				encoded := poly.u.EncodePlaintextFromArray(poly.u.GenerateFilledArraySize(poly.Coeff[c].Value, size))
-				poly.Coeff[c].Encoded[size] = encoded
+	if highestDegree(q) == 0 {
 
+		result = multiplyConstant(u, basis[giant], q[0])
+
+	} else {
+
+		qChannel := make(chan *rlwe.Ciphertext)
+		go func(qUtils Utils) {
+			qResult := poly.evaluateFromBasis(q, basis, babyStep, qUtils)
+			if q[0] != 0 {
+				qUtils.Evaluator.AddConst(qResult, q[0], qResult)
 			}
-			poly.u.AddPlain(sum, poly.Coeff[c].Encoded[size], sum)
+			qChannel <- qUtils.MultiplyNew(qResult, basis[giant], true, false)
+		}(u.CopyWithClonedEval())
+
+		rResult := poly.evaluateFromBasis(r, basis, babyStep, u)
+		result = <-qChannel
+
+		if rResult != nil {
+			u.Add(result, rResult, result)
 		}
+
+		return result
+
+	}
+
+	if rResult := poly.evaluateFromBasis(r, basis, babyStep, u); rResult != nil {
+		u.Add(result, rResult, result)
 	}
 
-	return sum
+	return result
+
+}
+
+func multiplyConstant(u Utils, ct *rlwe.Ciphertext, c float64) *rlwe.Ciphertext {
+
+	if c == 1 {
+		return ct.CopyNew()
+	}
+
+	return u.MultiplyConstNew(ct, c, true, false)
+
+}
+
+// Evaluate polynomial of degree 7 or less. Kept for compatibility, same as Evaluate
+func (poly Polynomial) EvaluateDegree7(x *rlwe.Ciphertext, size int) *rlwe.Ciphertext {
+
+	return poly.Evaluate(x, size)
 
 }
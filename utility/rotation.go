@@ -1,15 +1,28 @@
 package utility
 
 import (
+	"fmt"
 	"math"
 	"sort"
 
+	"github.com/perm-ai/go-cerebrum/key"
 	"github.com/tuneinsight/lattigo/v4/rlwe"
 )
 
+// Rotate ct by k slots. Use the galois key for k directly when the keychain has one,
+// otherwise decompose k into power of 2 rotations. Panics with *key.MissingKeyError before rotating
+// when the keychain lacks the key of a step, which RotationPlanner.AddRotation(k) avoids
 func (u Utils) Rotate(ct *rlwe.Ciphertext, k int) {
 
-	evaluator := u.Get2PowRotationEvaluator()
+	if k%u.Params.Slots() == 0 {
+		return
+	}
+
+	if u.HasRotationKey(k) {
+		evaluator := u.getRotationEvaluator(k)
+		evaluator.Rotate(ct, k, ct)
+		return
+	}
 
 	availableSteps := []int{}
 
@@ -23,12 +36,37 @@ func (u Utils) Rotate(ct *rlwe.Ciphertext, k int) {
 
 	steps := findStep(k, 0, []int{}, availableSteps)
 
+	for _, step := range steps {
+		if !u.HasRotationKey(step) {
+			panic(&key.MissingKeyError{Key: fmt.Sprintf("rotation key for %d slots (rotation by %d decomposed into %v)", step, k, steps), Location: "keychain"})
+		}
+	}
+
+	evaluator := u.getRotationEvaluator(steps...)
+
 	for _, step := range steps {
 		evaluator.Rotate(ct, step, ct)
 	}
 
 }
 
+// Check if keychain has the galois key to rotate by k slots in one step
+func (u Utils) HasRotationKey(k int) bool {
+
+	if u.KeyChain.RotationKeyLoader != nil {
+		return u.KeyChain.RotationKeyLoader.Has(u.Params.GaloisElementForColumnRotationBy(k))
+	}
+
+	if u.KeyChain.GaloisKey == nil {
+		return false
+	}
+
+	_, exist := u.KeyChain.GaloisKey.GetRotationKey(u.Params.GaloisElementForColumnRotationBy(k))
+
+	return exist
+
+}
+
 func (u Utils) RotateNew(ct *rlwe.Ciphertext, k int) rlwe.Ciphertext {
 
 	newCt := ct.CopyNew()
@@ -78,7 +116,6 @@ func findStep(target int, stepSum int, steps []int, availableSteps []int) []int
 // This function will rotate and add ciphertext to fill the ciphertext to a certain slot with number at index 1
 func (u Utils) FillCiphertextInPlace(ct *rlwe.Ciphertext, slots int) {
 
-	evaluator := u.Get2PowRotationEvaluator()
 	current := 0
 	cache := make([]*rlwe.Ciphertext, u.Params.LogSlots())
 	cache[0] = ct
@@ -88,7 +125,9 @@ func (u Utils) FillCiphertextInPlace(ct *rlwe.Ciphertext, slots int) {
 		for rot := 0; int(math.Pow(2, float64(rot)))+current <= slots; rot++ {
 			if cache[rot] == nil && rot != 0 {
 
-				tmp := evaluator.RotateNew(cache[rot-1], -1*int(math.Pow(2, float64(rot-1))))
+				step := -1 * int(math.Pow(2, float64(rot-1)))
+				evaluator := u.getRotationEvaluator(step)
+				tmp := evaluator.RotateNew(cache[rot-1], step)
 				u.Add(tmp, cache[rot-1], tmp)
 				cache[rot] = tmp
 
@@ -0,0 +1,172 @@
+package utility
+
+import (
+	"math"
+	"sort"
+
+	"github.com/tuneinsight/lattigo/v4/ckks"
+)
+
+//=================================================
+//				ROTATION PLANNER
+//=================================================
+
+// RotationPlanner collects the exact rotations a sequence of operations performs so that
+// only the galois keys for those rotations have to be generated
+type RotationPlanner struct {
+	params    ckks.Parameters
+	rotations map[uint64]int
+}
+
+func NewRotationPlanner(params ckks.Parameters) *RotationPlanner {
+	return &RotationPlanner{params: params, rotations: make(map[uint64]int)}
+}
+
+// Slots of the parameters rotations are planned for
+func (p *RotationPlanner) Slots() int {
+	return p.params.Slots()
+}
+
+// Add rotation by k slots. Rotations that map to the same galois element are only counted once
+func (p *RotationPlanner) AddRotation(k int) {
+
+	if k%p.params.Slots() == 0 {
+		return
+	}
+
+	galEl := p.params.GaloisElementForColumnRotationBy(k)
+
+	if _, exist := p.rotations[galEl]; !exist {
+		p.rotations[galEl] = k
+	}
+
+}
+
+func (p *RotationPlanner) AddRotations(ks []int) {
+	for _, k := range ks {
+		p.AddRotation(k)
+	}
+}
+
+// Rotations performed by SumElementsInPlace, SumElementsNew, DotProduct and DotProductNew
+func (p *RotationPlanner) AddSumElements() {
+	for midpoint := p.params.Slots() / 2; midpoint >= 1; midpoint /= 2 {
+		p.AddRotation(midpoint)
+	}
+}
+
+// Rotations performed by FillCiphertextInPlace(ct, slots)
+func (p *RotationPlanner) AddFillCiphertext(slots int) {
+
+	current := 0
+	cached := make([]bool, p.params.LogSlots()+1)
+	hasResult := false
+
+	for current != slots {
+		for rot := 0; int(math.Pow(2, float64(rot)))+current <= slots; rot++ {
+			if !cached[rot] && rot != 0 {
+				p.AddRotation(-1 * int(math.Pow(2, float64(rot-1))))
+				cached[rot] = true
+			}
+			if int(math.Pow(2, float64(rot+1)))+current > slots {
+				if !hasResult {
+					hasResult = true
+				} else {
+					p.AddRotation(-1 * current)
+				}
+				current += int(math.Pow(2, float64(rot)))
+			}
+		}
+	}
+
+}
+
+// Rotations performed by Transpose on row ciphertexts with column elements each
+func (p *RotationPlanner) AddTranspose(row int, column int) {
+
+	for i := 1; i < row; i++ {
+		p.AddRotation(-i)
+	}
+
+	for c := 1; c < column; c++ {
+		p.AddRotation(c)
+	}
+
+}
+
+// Rotations performed by Outer(a, b, aSize, bSize, filterBy)
+func (p *RotationPlanner) AddOuter(aSize int, bSize int) {
+
+	if bSize > p.params.Slots()/4 {
+		p.AddSumElements()
+		return
+	}
+
+	for i := 1; i < aSize; i++ {
+		p.AddRotation(i)
+	}
+
+	for j := 1; j < bSize; j *= 2 {
+		p.AddRotation(-j)
+	}
+
+}
+
+// Rotations performed by NewCiphertextGroup when packing ciphertexts of the given lengths
+func (p *RotationPlanner) AddCiphertextGroup(lengths []int) {
+
+	start := 0
+
+	for _, length := range lengths {
+
+		pow2Ceil := 1
+		for pow2Ceil < length {
+			pow2Ceil *= 2
+		}
+
+		if start+pow2Ceil > p.params.Slots() {
+			start = 0
+		} else if start != 0 {
+			p.AddRotation(-1 * pow2Ceil)
+		}
+
+		start += pow2Ceil
+
+	}
+
+}
+
+// Rotations performed by MaxSlots, MinSlots and ArgmaxSlots on size slots
+func (p *RotationPlanner) AddSlotComparison(size int) {
+	for step := 1; step < size; step *= 2 {
+		p.AddRotation(step)
+	}
+}
+
+// Get planned rotations sorted in ascending order
+func (p *RotationPlanner) Rotations() []int {
+
+	ks := make([]int, 0, len(p.rotations))
+	for _, k := range p.rotations {
+		ks = append(ks, k)
+	}
+
+	sort.Ints(ks)
+
+	return ks
+
+}
+
+// Get galois elements of planned rotations sorted in ascending order
+func (p *RotationPlanner) GaloisElements() []uint64 {
+
+	galEls := make([]uint64, 0, len(p.rotations))
+	for galEl := range p.rotations {
+		galEls = append(galEls, galEl)
+	}
+
+	sort.Slice(galEls, func(i, j int) bool { return galEls[i] < galEls[j] })
+
+	return galEls
+
+}
@@ -1,6 +1,7 @@
 package utility
 
 import (
+	"errors"
 	"fmt"
 	"math"
 	"math/rand"
@@ -35,19 +36,50 @@ type Utils struct {
 
 func NewUtils(keyChain key.KeyChain, scale float64, filtersAmount int, logEnabled bool) Utils {
 
+	u, err := TryNewUtils(keyChain, scale, filtersAmount, logEnabled)
 
-	if keyChain.RelinKey == nil || keyChain.GaloisKey == nil {
-		panic("Missing keys must have both relinearlize keys and galois keys in keychain to generate new utils")
+	if err != nil {
+		panic(err)
+	}
+
+	return u
+
+}
+
+// Error returning variant of NewUtils. Missing keys are reported as *key.MissingKeyError
+func TryNewUtils(keyChain key.KeyChain, scale float64, filtersAmount int, logEnabled bool) (Utils, error) {
+
+	if keyChain.RelinKey == nil {
+		return Utils{}, &key.MissingKeyError{Key: "relinearlize key", Location: "keychain"}
+	}
+
+	if keyChain.GaloisKey == nil && keyChain.RotationKeyLoader == nil {
+		return Utils{}, &key.MissingKeyError{Key: "galois keys", Location: "keychain"}
 	}
 
 	bootstrapEnabled := keyChain.BtspGalKey != nil
 	log := logger.NewLogger(logEnabled)
 
-	paramSet := bootstrapping.DefaultParametersSparse[keyChain.ParamsIndex]
-	ckksParams := paramSet.SchemeParams
+	paramSet := keyChain.GetParameters()
+
+	if bootstrapEnabled && !paramSet.HasBootstrapping() {
+		return Utils{}, errors.New("keychain has bootstrapping keys but its parameters doesn't contain bootstrapping parameters")
+	}
+
+	if bootstrapEnabled && keyChain.SecretKey == nil {
+		return Utils{}, &key.MissingKeyError{Key: "secret key (required for bootstrapping)", Location: "keychain"}
+	}
+
+	params, err := paramSet.CKKSParameters()
 
-	bootstrappingParams := paramSet.BootstrappingParams
-	params, _ := ckks.NewParametersFromLiteral(ckksParams)
+	if err != nil {
+		return Utils{}, err
+	}
+
+	var bootstrappingParams bootstrapping.Parameters
+	if paramSet.HasBootstrapping() {
+		bootstrappingParams = *paramSet.BootstrappingParams
+	}
 
 	log.Log("Util Initialization: Generating encoder, evaluator, encryptor, decryptor")
 	encoder := ckks.NewEncoder(params)
@@ -76,14 +108,13 @@ func NewUtils(keyChain key.KeyChain, scale float64, filtersAmount int, logEnable
 
 		swkDtS, swkStD := bootstrappingParams.GenEncapsulationSwitchingKeys(params, keyChain.SecretKey)
 		evalKeys := rlwe.EvaluationKey{Rlk: keyChain.RelinKey, Rtks: keyChain.BtspGalKey};
-		bootstrappingKey := bootstrapping.EvaluationKeys{evalKeys, swkDtS, swkStD}
+		bootstrappingKey := bootstrapping.EvaluationKeys{EvaluationKey: evalKeys, SwkDtS: swkDtS, SwkStD: swkStD}
 
-		var err error
 		log.Log("Util Initialization: Generating bootstrapper")
 		bootstrapper, err = bootstrapping.NewBootstrapper(params, bootstrappingParams, bootstrappingKey)
 
 		if err != nil {
-			panic("BOOTSTRAPPER GENERATION ERROR")
+			return Utils{}, fmt.Errorf("bootstrapper generation error: %w", err)
 		}
 	}
 
@@ -101,20 +132,49 @@ func NewUtils(keyChain key.KeyChain, scale float64, filtersAmount int, logEnable
 		filters,
 		scale,
 		log,
-	}
+	}, nil
 
 }
 
 func NewDecryptionUtils(keyChain key.KeyChain, scale float64, logEnabled bool) Utils {
+
+	u, err := TryNewDecryptionUtils(keyChain, scale, logEnabled)
+
+	if err != nil {
+		panic(err)
+	}
+
+	return u
+
+}
+
+// Error returning variant of NewDecryptionUtils
+func TryNewDecryptionUtils(keyChain key.KeyChain, scale float64, logEnabled bool) (Utils, error) {
 	log := logger.NewLogger(logEnabled)
 
-	paramSet := bootstrapping.DefaultParametersSparse[keyChain.ParamsIndex]
-	ckksParams := paramSet.SchemeParams
+	if keyChain.SecretKey == nil {
+		return Utils{}, &key.MissingKeyError{Key: "secret key", Location: "keychain"}
+	}
+
+	paramSet := keyChain.GetParameters()
+	params, err := paramSet.CKKSParameters()
 
-	bootstrappingParams := paramSet.BootstrappingParams
-	params, _ := ckks.NewParametersFromLiteral(ckksParams)
+	if err != nil {
+		return Utils{}, err
+	}
+
+	var bootstrappingParams bootstrapping.Parameters
+	if paramSet.HasBootstrapping() {
+		bootstrappingParams = *paramSet.BootstrappingParams
+	}
 	encoder := ckks.NewEncoder(params)
-	encryptor := ckks.NewEncryptor(params, keyChain.PublicKey)
+
+	var encryptor rlwe.Encryptor
+	if keyChain.PublicKey != nil {
+		encryptor = ckks.NewEncryptor(params, keyChain.PublicKey)
+	} else {
+		encryptor = ckks.NewEncryptor(params, keyChain.SecretKey)
+	}
 	decryptor := ckks.NewDecryptor(params, keyChain.SecretKey)
 
 	return Utils{
@@ -131,19 +191,38 @@ func NewDecryptionUtils(keyChain key.KeyChain, scale float64, logEnabled bool) U
 		nil,
 		scale,
 		log,
-	}
+	}, nil
 
 }
 
 func NewEncryptionUtils(keyChain key.KeyChain, scale float64, logEnabled bool) Utils{
 
+	u, err := TryNewEncryptionUtils(keyChain, scale, logEnabled)
+
+	if err != nil {
+		panic(err)
+	}
+
+	return u
+
+}
+
+// Error returning variant of NewEncryptionUtils
+func TryNewEncryptionUtils(keyChain key.KeyChain, scale float64, logEnabled bool) (Utils, error) {
+
 	log := logger.NewLogger(logEnabled)
 
-	paramSet := bootstrapping.DefaultParametersSparse[keyChain.ParamsIndex]
-	ckksParams := paramSet.SchemeParams
+	paramSet := keyChain.GetParameters()
+	params, err := paramSet.CKKSParameters()
 
-	bootstrappingParams := paramSet.BootstrappingParams
-	params, _ := ckks.NewParametersFromLiteral(ckksParams)
+	if err != nil {
+		return Utils{}, err
+	}
+
+	var bootstrappingParams bootstrapping.Parameters
+	if paramSet.HasBootstrapping() {
+		bootstrappingParams = *paramSet.BootstrappingParams
+	}
 	encoder := ckks.NewEncoder(params)
 
 	var encryptor rlwe.Encryptor
@@ -152,7 +231,7 @@ func NewEncryptionUtils(keyChain key.KeyChain, scale float64, logEnabled bool) U
 	} else if keyChain.SecretKey != nil{
 		encryptor = ckks.NewEncryptor(params, keyChain.SecretKey)
 	} else {
-		panic("Secret or Public key must be provided")
+		return Utils{}, &key.MissingKeyError{Key: "secret or public key", Location: "keychain"}
 	}
 	
 
@@ -170,7 +249,7 @@ func NewEncryptionUtils(keyChain key.KeyChain, scale float64, logEnabled bool) U
 		nil,
 		scale,
 		log,
-	}
+	}, nil
 
 }
 
@@ -327,6 +406,11 @@ func (u Utils) EncryptToLevelScale(value []float64, level int, scale float64) *r
 
 }
 
+// Check if utils holds a decryptor so Decrypt can be used
+func (u Utils) HasDecryptor() bool {
+	return u.Decryptor != nil
+}
+
 func (u Utils) Decrypt(ciphertext *rlwe.Ciphertext) []float64 {
 
 	if u.Decryptor == nil {
@@ -1,6 +1,7 @@
 package utility
 
 import (
+	"errors"
 	"fmt"
 	"math"
 	"math/rand"
@@ -400,6 +401,139 @@ func TestSquareRootPoly(t *testing.T) {
 
 }
 
+func TestPolynomial(t *testing.T) {
+
+	testCase := utils.GenerateRandomFloatArray(100, -1, 1)
+	relu := func(x float64) float64 { return math.Max(0, x) }
+
+	testPolys := []struct {
+		name  string
+		poly  Polynomial
+		level int
+	}{
+		{"Power basis degree 7", NewPolynomial([]float64{1, 1, 0.5, 1.0 / 6, 1.0 / 24, 1.0 / 120, 1.0 / 720, 1.0 / 5040}, utils), 3},
+		{"Power basis degree 15", NewPolynomial([]float64{0.5, 0.3, 0, -0.2, 0, 0.1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0.01}, utils), 4},
+		{"Chebyshev degree 31", NewChebyshevPolynomial(utils.GenerateRandomFloatArray(32, -0.1, 0.1)[0:32], -1, 1, utils), 5},
+		{"Chebyshev fitted ReLU", FitChebyshev(relu, -4, 4, 31, utils), 6},
+		{"Chebyshev derivative", FitChebyshev(relu, -4, 4, 31, utils).Derivative(), 6},
+	}
+
+	for _, test := range testPolys {
+
+		if level := test.poly.GetLevelConsumption(); level != test.level {
+			t.Errorf("%s: expected level consumption of %d but got %d", test.name, test.level, level)
+		}
+
+		expect := make([]float64, 100)
+		for i := range expect {
+			expect[i] = test.poly.EvaluatePlain(testCase[i])
+		}
+
+		ct := utils.EncryptToLevel(testCase, 9)
+		result := test.poly.Evaluate(ct, len(testCase))
+
+		if consumed := ct.Level() - result.Level(); consumed != test.poly.GetLevelConsumption() {
+			t.Errorf("%s: reported level consumption of %d but consumed %d", test.name, test.poly.GetLevelConsumption(), consumed)
+		}
+
+		if !ValidateResult(utils.Decrypt(result)[0:100], expect, false, 2, log) {
+			t.Errorf("%s: polynomial wasn't evaluated correctly", test.name)
+		}
+
+	}
+
+	// Derivative of sine interpolant should be close to cosine
+	derivative := FitChebyshev(math.Sin, -1, 1, 31, utils).Derivative()
+	for _, x := range testCase[0:100] {
+		if math.Abs(derivative.EvaluatePlain(x)-math.Cos(x)) > 1e-6 {
+			t.Errorf("Derivative at %f expected %f but got %f", x, math.Cos(x), derivative.EvaluatePlain(x))
+		}
+	}
+
+}
+
+func TestComparison(t *testing.T) {
+
+	params := DefaultComparisonParams(10)
+
+	a := utils.GenerateRandomFloatArray(100, 0, 5)
+	b := utils.GenerateRandomFloatArray(100, 0, 5)
+	for i := 0; i < 100; i++ {
+		if math.Abs(a[i]-b[i]) < 0.3 {
+			b[i] = a[i] - 0.3
+		}
+	}
+
+	expectSign := make([]float64, 100)
+	expectCompare := make([]float64, 100)
+	expectMax := make([]float64, 100)
+	expectMin := make([]float64, 100)
+	for i := range expectSign {
+		expectSign[i] = math.Copysign(1, a[i]-b[i])
+		expectCompare[i] = (expectSign[i] + 1) / 2
+		expectMax[i] = math.Max(a[i], b[i])
+		expectMin[i] = math.Min(a[i], b[i])
+	}
+
+	ctA := utils.EncryptToLevel(a, 9)
+	ctB := utils.EncryptToLevel(b, 9)
+
+	if !ValidateResult(utils.Decrypt(utils.Sign(utils.SubNew(ctA, ctB), params))[0:100], expectSign, false, 1, log) {
+		t.Error("Sign wasn't correctly approximated")
+	}
+
+	if !ValidateResult(utils.Decrypt(utils.Compare(ctA, ctB, params))[0:100], expectCompare, false, 1, log) {
+		t.Error("Ciphertexts weren't correctly compared")
+	}
+
+	if !ValidateResult(utils.Decrypt(utils.Max(ctA, ctB, params))[0:100], expectMax, false, 1, log) {
+		t.Error("Max wasn't correctly calculated")
+	}
+
+	if !ValidateResult(utils.Decrypt(utils.Min(ctA, ctB, params))[0:100], expectMin, false, 1, log) {
+		t.Error("Min wasn't correctly calculated")
+	}
+
+	// Values must lie in [-Bound/2, Bound/2] to be compared over slots and across ciphertexts
+	slotParams := DefaultComparisonParams(1)
+	values := utils.GenerateFilledArray(0)
+	copy(values, []float64{-0.1, 0.2, 0.45, -0.4, 0.05})
+	ct := utils.EncryptToLevel(values, 9)
+
+	slotResults := map[string][]float64{
+		"MaxSlots":    {0.45, utils.Decrypt(utils.MaxSlots(ct, 5, slotParams))[0]},
+		"MinSlots":    {-0.4, utils.Decrypt(utils.MinSlots(ct, 5, slotParams))[0]},
+		"ArgmaxSlots": {2, utils.Decrypt(utils.ArgmaxSlots(ct, 5, slotParams))[0]},
+	}
+
+	for name, result := range slotResults {
+		if math.Abs(result[0]-result[1]) > 0.05 {
+			t.Errorf("%s expected %f but got %f", name, result[0], result[1])
+		}
+	}
+
+	across := [][]float64{{0.1, -0.4, 0.4}, {-0.4, 0.45, 0.0}, {0.5, 0.05, -0.4}}
+	cts := make([]*rlwe.Ciphertext, len(across))
+	for i := range across {
+		cts[i] = utils.EncryptToLevel(across[i], 9)
+	}
+
+	acrossResults := map[string][][]float64{
+		"MaxAcross":    {{0.5, 0.45, 0.4}, utils.Decrypt(utils.MaxAcross(cts, slotParams))[0:3]},
+		"MinAcross":    {{-0.4, -0.4, -0.4}, utils.Decrypt(utils.MinAcross(cts, slotParams))[0:3]},
+		"ArgmaxAcross": {{2, 1, 0}, utils.Decrypt(utils.ArgmaxAcross(cts, slotParams))[0:3]},
+	}
+
+	for name, result := range acrossResults {
+		for i := range result[0] {
+			if math.Abs(result[0][i]-result[1][i]) > 0.05 {
+				t.Errorf("%s at slot %d expected %f but got %f", name, i, result[0][i], result[1][i])
+			}
+		}
+	}
+
+}
+
 func TestDotProduct(t *testing.T) {
 
 	testCases := GenerateTestCases(utils)
@@ -780,4 +914,138 @@ func TestSumElementZero(t *testing.T){
 
 	}
 
-}
\ No newline at end of file
+}
+func TestRotationPlanner(t *testing.T) {
+
+	paramSet := key.NewParameters(ckks.PN12QP109, nil)
+	keyPair := key.GenerateKeyPairWithParameters(paramSet)
+	params, _ := paramSet.CKKSParameters()
+
+	planner := NewRotationPlanner(params)
+	planner.AddSumElements()
+	planner.AddFillCiphertext(5)
+	planner.AddRotation(3)
+	planner.AddRotation(3 - params.Slots())
+
+	if len(planner.Rotations()) != params.LogSlots()+4 {
+		t.Errorf("Expected %d planned rotations but got %d", params.LogSlots()+4, len(planner.Rotations()))
+	}
+
+	plannedKeys := key.GenerateKeysForRotationsWithParameters(paramSet, keyPair.SecretKey, keyPair.PublicKey, planner.Rotations(), false, false)
+
+	if len(plannedKeys.GaloisKey.Keys) != len(planner.Rotations()) {
+		t.Error("Generated galois keys doesn't match planned rotations")
+	}
+
+	plannedUtils := NewUtils(plannedKeys, math.Pow(2, 30), 0, false)
+
+	data := make([]float64, params.Slots())
+	for i := range data {
+		data[i] = float64(i%10) / 10
+	}
+
+	rotated := plannedUtils.EncryptToPointer(data)
+	plannedUtils.Rotate(rotated, 3)
+
+	rotatedExpected := make([]float64, params.Slots())
+	for i := range rotatedExpected {
+		rotatedExpected[i] = data[(i+3)%params.Slots()]
+	}
+
+	if !ValidateResult(plannedUtils.Decrypt(rotated), rotatedExpected, false, 1, log) {
+		t.Error("Incorrect rotation with planned key")
+	}
+
+	sum := plannedUtils.EncryptToPointer(plannedUtils.GenerateFilledArray(0.01))
+	plannedUtils.SumElementsInPlace(sum)
+
+	if !ValidateResult(plannedUtils.Decrypt(sum), plannedUtils.GenerateFilledArray(0.01*float64(params.Slots())), false, 1, log) {
+		t.Error("Incorrect sum with planned keys")
+	}
+
+	fillData := make([]float64, params.Slots())
+	fillData[0] = 1.23
+	fill := plannedUtils.EncryptToPointer(fillData)
+	plannedUtils.FillCiphertextInPlace(fill, 5)
+
+	if !ValidateResult(plannedUtils.Decrypt(fill), plannedUtils.GenerateFilledArraySize(1.23, 5), false, 1, log) {
+		t.Error("Incorrect fill with planned keys")
+	}
+
+	// Rotation by 24 isn't planned and falls back to 32 and -8, which has no key either
+	func() {
+
+		defer func() {
+			var missing *key.MissingKeyError
+			if err, ok := recover().(error); !ok || !errors.As(err, &missing) {
+				t.Errorf("Unplanned rotation should panic with MissingKeyError but got %v", err)
+			}
+		}()
+
+		plannedUtils.Rotate(plannedUtils.EncryptToPointer(data), 24)
+
+	}()
+
+}
+
+func TestLazyRotationKeys(t *testing.T) {
+
+	paramSet := key.NewParameters(ckks.PN12QP109, nil)
+	keyPair := key.GenerateKeyPairWithParameters(paramSet)
+	params, _ := paramSet.CKKSParameters()
+
+	planner := NewRotationPlanner(params)
+	planner.AddSumElements()
+	planner.AddRotation(3)
+
+	plannedKeys := key.GenerateKeysForRotationsWithParameters(paramSet, keyPair.SecretKey, keyPair.PublicKey, planner.Rotations(), false, false)
+
+	store := key.NewMemoryKeyStore()
+	if err := plannedKeys.DumpRotationKeysToStore(store); err != nil {
+		t.Fatal(err)
+	}
+
+	loader, err := key.NewRotationKeyLoaderFromStore(store, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lazyKeys := plannedKeys
+	lazyKeys.GaloisKey = nil
+	lazyKeys.RotationKeyLoader = loader
+
+	lazyUtils := NewUtils(lazyKeys, math.Pow(2, 30), 0, false)
+
+	if loader.Resident() != 0 {
+		t.Error("Rotation keys shouldn't be loaded before first use")
+	}
+
+	data := make([]float64, params.Slots())
+	for i := range data {
+		data[i] = float64(i%10) / 10
+	}
+
+	rotated := lazyUtils.EncryptToPointer(data)
+	lazyUtils.CopyWithClonedEval().Rotate(rotated, 3)
+
+	rotatedExpected := make([]float64, params.Slots())
+	for i := range rotatedExpected {
+		rotatedExpected[i] = data[(i+3)%params.Slots()]
+	}
+
+	if !ValidateResult(lazyUtils.Decrypt(rotated), rotatedExpected, false, 1, log) {
+		t.Error("Incorrect rotation with lazily loaded key")
+	}
+
+	sum := lazyUtils.EncryptToPointer(lazyUtils.GenerateFilledArray(0.01))
+	lazyUtils.SumElementsInPlace(sum)
+
+	if !ValidateResult(lazyUtils.Decrypt(sum), lazyUtils.GenerateFilledArray(0.01*float64(params.Slots())), false, 1, log) {
+		t.Error("Incorrect sum with lazily loaded keys")
+	}
+
+	if loader.Resident() > 2 {
+		t.Errorf("Expected at most 2 resident keys but got %d", loader.Resident())
+	}
+
+}